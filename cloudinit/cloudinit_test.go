@@ -55,6 +55,13 @@ var ctests = []struct {
 			cfg.SetAptMirror("http://foo.com")
 		},
 	},
+	{
+		"AptProxy",
+		"apt_proxy: http://proxy.foo.com:3142\n",
+		func(cfg *cloudinit.Config) {
+			cfg.SetAptProxy("http://proxy.foo.com:3142")
+		},
+	},
 	{
 		"AptPreserveSourcesList",
 		"apt_mirror: true\n",
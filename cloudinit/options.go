@@ -41,6 +41,12 @@ func (cfg *Config) SetAptMirror(url string) {
 	cfg.set("apt_mirror", url != "", url)
 }
 
+// SetAptProxy sets the URL of the HTTP proxy to be used by apt.
+// If not set, apt connects directly.
+func (cfg *Config) SetAptProxy(url string) {
+	cfg.set("apt_proxy", url != "", url)
+}
+
 // SetAptPreserveSourcesList sets whether /etc/apt/sources.list
 // is overwritten by the mirror. If true, SetAptMirror above
 // will have no effect.
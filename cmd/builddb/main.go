@@ -14,6 +14,7 @@ import (
 	"launchpad.net/juju-core/charm"
 	"launchpad.net/juju-core/environs"
 	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 )
 
@@ -53,7 +54,7 @@ func build() error {
 	if err := service.SetExposed(); err != nil {
 		return err
 	}
-	units, err := conn.AddUnits(service, 1, "")
+	units, err := conn.AddUnits(service, 1, state.AssignNew, "")
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,8 @@ import (
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/log"
@@ -24,14 +26,27 @@ type AddMachineCommand struct {
 	Constraints   constraints.Value
 	MachineId     string
 	ContainerType instance.ContainerType
+	// If specified, an existing host, reachable over SSH as
+	// [user@]host, to add to the environment as a manual machine.
+	SSHHost string
 }
 
+const addMachineDoc = `
+Machines are created in a clean state and ready to have units deployed.
+
+If the argument is ssh:[user@]host, no new machine is started; instead the
+existing host is added to the environment, and its machine agent started,
+over SSH. The host must accept SSH connections authenticated by key, its
+host key must already be in known_hosts, and the user must be able to run
+sudo without a password.
+`
+
 func (c *AddMachineCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "add-machine",
-		Args:    "[<machine>/<container> | /<container>]",
+		Args:    "[<machine>/<container> | /<container> | ssh:[user@]host]",
 		Purpose: "start a new, empty machine and optionally a container, or add a container to a machine",
-		Doc:     "Machines are created in a clean state and ready to have units deployed.",
+		Doc:     addMachineDoc,
 	}
 }
 
@@ -39,6 +54,8 @@ func (c *AddMachineCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
 	f.StringVar(&c.Series, "series", "", "the charm series")
 	f.Var(constraints.ConstraintsValue{&c.Constraints}, "constraints", "additional machine constraints")
+	c.AddFormatFlag(f)
+	c.AddTimeoutFlag(f)
 }
 
 func (c *AddMachineCommand) Init(args []string) error {
@@ -49,41 +66,106 @@ func (c *AddMachineCommand) Init(args []string) error {
 	if err != nil {
 		return err
 	}
-	if containerSpec == "" {
-		return nil
+	if strings.HasPrefix(containerSpec, manualHostPrefix) {
+		c.SSHHost = containerSpec[len(manualHostPrefix):]
+		if c.SSHHost == "" || strings.HasSuffix(c.SSHHost, "@") {
+			return fmt.Errorf("malformed ssh host argument %q", containerSpec)
+		}
+	} else if containerSpec != "" {
+		// container arg can either be 'machine/type' or '/type'
+		sep := strings.LastIndex(containerSpec, "/")
+		if sep < 0 {
+			return fmt.Errorf("malformed container argument %q", containerSpec)
+		}
+		c.MachineId = containerSpec[:sep]
+		if c.MachineId != "" && !state.IsMachineId(c.MachineId) {
+			return fmt.Errorf("malformed container argument %q", containerSpec)
+		}
+		c.ContainerType, err = instance.ParseSupportedContainerType(containerSpec[sep+1:])
+		if err != nil {
+			return err
+		}
 	}
-	// container arg can either be 'machine/type' or '/type'
-	sep := strings.Index(containerSpec, "/")
-	if sep < 0 {
-		return fmt.Errorf("malformed container argument %q", containerSpec)
+	// The environment's default series is not known until the
+	// command runs, but any series will do for validation.
+	series := c.Series
+	if series == "" {
+		series = config.DefaultSeries
 	}
-	c.MachineId = containerSpec[:sep]
-	c.ContainerType, err = instance.ParseSupportedContainerType(containerSpec[sep+1:])
-	return err
+	params := c.machineParams(series)
+	return params.Validate()
 }
 
-func (c *AddMachineCommand) Run(_ *cmd.Context) error {
+// machineParams returns the parameters for adding the machine
+// described by the command, running the given series.
+func (c *AddMachineCommand) machineParams(series string) state.AddMachineParams {
+	return state.AddMachineParams{
+		ParentId:      c.MachineId,
+		ContainerType: c.ContainerType,
+		Series:        series,
+		Constraints:   c.Constraints,
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	}
+}
+
+// checkContainerType returns an error if containers of the given type
+// cannot be created in an environment. It is a variable so that tests
+// can replace it.
+var checkContainerType = environs.CheckContainerType
+
+func (c *AddMachineCommand) Run(ctx *cmd.Context) error {
+	var m *state.Machine
+	err := c.RunWithTimeout(func() (err error) {
+		m, err = c.addMachine()
+		return err
+	})
+	if err != nil {
+		return c.Report(ctx, nil, err)
+	}
+	result := map[string]string{"machine": m.Id()}
+	if c.ContainerType != "" && c.MachineId == "" {
+		// A new host machine was created for the container
+		// in the same transaction; report it too.
+		if hostId, ok := m.ParentId(); ok {
+			result["host"] = hostId
+		}
+	}
+	return c.Report(ctx, result, nil)
+}
+
+func (c *AddMachineCommand) addMachine() (*state.Machine, error) {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer conn.Close()
 
+	if c.ContainerType != "" {
+		if err := checkContainerType(conn.Environ, c.ContainerType); err != nil {
+			return nil, err
+		}
+	}
 	series := c.Series
 	if series == "" {
 		conf, err := conn.State.EnvironConfig()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		series = conf.DefaultSeries()
 	}
-	params := state.AddMachineParams{
-		ParentId:      c.MachineId,
-		ContainerType: c.ContainerType,
-		Series:        series,
-		Constraints:   c.Constraints,
-		Jobs:          []state.MachineJob{state.JobHostUnits},
+	if c.SSHHost != "" {
+		params := state.AddMachineParams{
+			Series:      series,
+			Constraints: c.Constraints,
+			Jobs:        []state.MachineJob{state.JobHostUnits},
+		}
+		m, err := addManualMachine(conn, c.SSHHost, params)
+		if err == nil {
+			log.Infof("created machine %v on %q", m, c.SSHHost)
+		}
+		return m, err
 	}
+	params := c.machineParams(series)
 	m, err := conn.State.AddMachineWithConstraints(&params)
 	if err == nil {
 		if c.ContainerType == "" {
@@ -92,5 +174,5 @@ func (c *AddMachineCommand) Run(_ *cmd.Context) error {
 			log.Infof("created %q container on machine %v", c.ContainerType, m)
 		}
 	}
-	return err
+	return m, err
 }
@@ -4,14 +4,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	. "launchpad.net/gocheck"
+	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/instance"
 	jujutesting "launchpad.net/juju-core/juju/testing"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/testing"
+	"launchpad.net/juju-core/testing/checkers"
 	"strconv"
+	"time"
 )
 
 type AddMachineSuite struct {
@@ -57,6 +63,23 @@ func (s *AddMachineSuite) TestAddMachineWithConstraints(c *C) {
 	c.Assert(mcons, DeepEquals, expectedCons)
 }
 
+func (s *AddMachineSuite) TestAddMachineWithTimeout(c *C) {
+	err := runAddMachine(c, "--timeout", "1m")
+	c.Assert(err, IsNil)
+	_, err = s.State.Machine("0")
+	c.Assert(err, IsNil)
+}
+
+func (s *AddMachineSuite) TestInitTimeout(c *C) {
+	com := &AddMachineCommand{}
+	err := testing.InitCommand(com, []string{"--timeout", "90s"})
+	c.Assert(err, IsNil)
+	c.Assert(com.Timeout, Equals, 90*time.Second)
+
+	err = testing.InitCommand(&AddMachineCommand{}, []string{"--timeout", "soon"})
+	c.Assert(err, ErrorMatches, `invalid value "soon" for flag .*timeout.*`)
+}
+
 func (s *AddMachineSuite) _assertAddContainer(c *C, parentId, containerId string, ctype instance.ContainerType) {
 	m, err := s.State.Machine(parentId)
 	c.Assert(err, IsNil)
@@ -97,3 +120,180 @@ func (s *AddMachineSuite) TestAddMachineErrors(c *C) {
 	err = runAddMachine(c, "/lxc", "--constraints", "container=lxc")
 	c.Assert(err, ErrorMatches, `container constraint "lxc" not allowed when adding a machine`)
 }
+
+func (s *AddMachineSuite) TestAddContainerUnsupportedType(c *C) {
+	types := []instance.ContainerType(nil)
+	defer patchSupportedContainerTypes(func(environs.Environ) []instance.ContainerType {
+		return types
+	})()
+	err := runAddMachine(c, "/lxc")
+	c.Assert(err, ErrorMatches, "provider does not support containers")
+
+	types = []instance.ContainerType{"kvm"}
+	err = runAddMachine(c, "/lxc")
+	c.Assert(err, ErrorMatches, `provider does not support "lxc" containers, expected one of "kvm"`)
+
+	_, err = s.State.Machine("0")
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
+func (s *AddMachineSuite) TestAddMachineIgnoresContainerSupport(c *C) {
+	defer patchSupportedContainerTypes(func(environs.Environ) []instance.ContainerType {
+		return nil
+	})()
+	err := runAddMachine(c)
+	c.Assert(err, IsNil)
+	_, err = s.State.Machine("0")
+	c.Assert(err, IsNil)
+}
+
+func (s *AddMachineSuite) TestInitValidatesParams(c *C) {
+	com := &AddMachineCommand{}
+	err := testing.InitCommand(com, []string{"--series", "raring", "0/lxc/1/lxc"})
+	c.Assert(err, IsNil)
+	c.Assert(com.MachineId, Equals, "0/lxc/1")
+
+	err = testing.InitCommand(&AddMachineCommand{}, []string{"foo/lxc"})
+	c.Assert(err, ErrorMatches, `malformed container argument "foo/lxc"`)
+	err = testing.InitCommand(&AddMachineCommand{}, []string{"0/lxc/lxc"})
+	c.Assert(err, ErrorMatches, `malformed container argument "0/lxc/lxc"`)
+}
+
+func (s *AddMachineSuite) TestInitUnsupportedContainerType(c *C) {
+	err := testing.InitCommand(&AddMachineCommand{}, []string{"/kvm"})
+	c.Assert(err, ErrorMatches, `invalid container type "kvm", expected one of "lxc"`)
+}
+
+func (s *AddMachineSuite) TestInitSSHHost(c *C) {
+	for i, t := range []struct {
+		arg  string
+		host string
+		err  string
+	}{{
+		arg:  "ssh:10.0.0.1",
+		host: "10.0.0.1",
+	}, {
+		arg:  "ssh:ubuntu@example.com",
+		host: "ubuntu@example.com",
+	}, {
+		arg: "ssh:",
+		err: `malformed ssh host argument "ssh:"`,
+	}, {
+		arg: "ssh:ubuntu@",
+		err: `malformed ssh host argument "ssh:ubuntu@"`,
+	}} {
+		c.Logf("test %d: %q", i, t.arg)
+		com := &AddMachineCommand{}
+		err := testing.InitCommand(com, []string{t.arg})
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err)
+			continue
+		}
+		c.Check(err, IsNil)
+		c.Check(com.SSHHost, Equals, t.host)
+		c.Check(com.MachineId, Equals, "")
+		c.Check(com.ContainerType, Equals, instance.ContainerType(""))
+	}
+}
+
+func patchProvisionManualHost(f func(host string, userData []byte) error) (restore func()) {
+	old := provisionManualHost
+	provisionManualHost = f
+	return func() { provisionManualHost = old }
+}
+
+// containerEnviron overrides the container types supported by an
+// environment.
+type containerEnviron struct {
+	environs.Environ
+	types func(environs.Environ) []instance.ContainerType
+}
+
+func (env *containerEnviron) SupportedContainerTypes() []instance.ContainerType {
+	return env.types(env.Environ)
+}
+
+func patchSupportedContainerTypes(f func(environs.Environ) []instance.ContainerType) (restore func()) {
+	old := checkContainerType
+	checkContainerType = func(env environs.Environ, ctype instance.ContainerType) error {
+		return old(&containerEnviron{env, f}, ctype)
+	}
+	return func() { checkContainerType = old }
+}
+
+func (s *AddMachineSuite) TestAddManualMachine(c *C) {
+	var gotHost string
+	var gotUserData []byte
+	defer patchProvisionManualHost(func(host string, userData []byte) error {
+		gotHost, gotUserData = host, userData
+		return nil
+	})()
+	err := runAddMachine(c, "ssh:ubuntu@10.0.0.1")
+	c.Assert(err, IsNil)
+	c.Assert(gotHost, Equals, "ubuntu@10.0.0.1")
+	c.Assert(gotUserData, Not(HasLen), 0)
+
+	m, err := s.State.Machine("0")
+	c.Assert(err, IsNil)
+	c.Assert(m.Series(), Equals, "precise")
+	instId, err := m.InstanceId()
+	c.Assert(err, IsNil)
+	c.Assert(instId, Equals, instance.Id("ssh:10.0.0.1"))
+}
+
+func (s *AddMachineSuite) TestAddManualMachineFailure(c *C) {
+	defer patchProvisionManualHost(func(host string, userData []byte) error {
+		return fmt.Errorf("connection refused")
+	})()
+	err := runAddMachine(c, "ssh:10.0.0.1")
+	c.Assert(err, ErrorMatches, `cannot provision "10.0.0.1": connection refused`)
+	_, err = s.State.Machine("0")
+	c.Assert(err, ErrorMatches, "machine 0 not found")
+}
+
+func (s *AddMachineSuite) TestAddMachineFormatJSON(c *C) {
+	ctx, err := testing.RunCommand(c, &AddMachineCommand{}, []string{"--format", "json"})
+	c.Assert(err, IsNil)
+	var result map[string]string
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]string{"machine": "0"})
+}
+
+func (s *AddMachineSuite) TestAddContainerCreatesHost(c *C) {
+	ctx, err := testing.RunCommand(c, &AddMachineCommand{}, []string{"--format", "json", "/lxc"})
+	c.Assert(err, IsNil)
+	var result map[string]string
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]string{"machine": "0/lxc/0", "host": "0"})
+
+	host, err := s.State.Machine("0")
+	c.Assert(err, IsNil)
+	c.Assert(host.ContainerType(), Equals, instance.ContainerType(""))
+	c.Assert(host.Jobs(), DeepEquals, []state.MachineJob{state.JobHostUnits})
+	container, err := s.State.Machine("0/lxc/0")
+	c.Assert(err, IsNil)
+	parentId, ok := container.ParentId()
+	c.Assert(ok, Equals, true)
+	c.Assert(parentId, Equals, "0")
+	s._assertAddContainer(c, "0", "0/lxc/0", instance.LXC)
+
+	// Adding a container to an existing machine reports no host.
+	ctx, err = testing.RunCommand(c, &AddMachineCommand{}, []string{"--format", "json", "0/lxc"})
+	c.Assert(err, IsNil)
+	result = nil
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]string{"machine": "0/lxc/1"})
+}
+
+func (s *AddMachineSuite) TestAddMachineFormatJSONError(c *C) {
+	ctx, err := testing.RunCommand(c, &AddMachineCommand{}, []string{"--format", "json", "42/lxc"})
+	c.Assert(err, Equals, cmd.ErrSilent)
+	var result map[string]map[string]string
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result["error"]["message"], Matches, "cannot add a new container: .*")
+	c.Assert(result["error"], HasLen, 2)
+}
@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state/api/params"
@@ -25,6 +26,11 @@ func (c *AddRelationCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *AddRelationCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *AddRelationCommand) Init(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("a relation must involve two services")
@@ -33,7 +39,11 @@ func (c *AddRelationCommand) Init(args []string) error {
 	return nil
 }
 
-func (c *AddRelationCommand) Run(_ *cmd.Context) error {
+func (c *AddRelationCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *AddRelationCommand) run(_ *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
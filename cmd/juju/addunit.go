@@ -5,31 +5,54 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
-	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/instance"
+	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/statecmd"
+	"strings"
 )
 
+const addUnitDoc = `
+Adding units to a service places them on new machines by default. The
+--to option places a single unit on an existing machine or container
+("3" or "3/lxc/0"), or on a new container, either on an existing machine
+("3/lxc") or on a new machine ("/lxc").
+
+The --constraints option sets the constraints used when provisioning
+machines for the new units, overriding those of the service; it cannot
+be used with --to, nor for subordinate services.
+`
+
 // AddUnitCommand is responsible adding additional units to a service.
 type AddUnitCommand struct {
 	EnvCommandBase
-	ServiceName string
-	NumUnits    int
+	ServiceName   string
+	NumUnits      int
+	ToMachineSpec string
+	Constraints   constraints.Value
 }
 
 func (c *AddUnitCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "add-unit",
+		Args:    "<service name>",
 		Purpose: "add a service unit",
+		Doc:     addUnitDoc,
 	}
 }
 
 func (c *AddUnitCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	f.IntVar(&c.NumUnits, "n", 1, "number of service units to add")
 	f.IntVar(&c.NumUnits, "num-units", 1, "")
+	f.StringVar(&c.ToMachineSpec, "to", "", "the machine or container to deploy the unit in, bypasses constraints")
+	f.Var(constraints.ConstraintsValue{&c.Constraints}, "constraints", "constraints for the new units' machines, overriding the service constraints")
+	c.AddFormatFlag(f)
 }
 
 func (c *AddUnitCommand) Init(args []string) error {
@@ -44,22 +67,62 @@ func (c *AddUnitCommand) Init(args []string) error {
 	if c.NumUnits < 1 {
 		return errors.New("must add at least one unit")
 	}
+	if c.ToMachineSpec != "" {
+		if c.Constraints != (constraints.Value{}) {
+			return errors.New("cannot use --constraints with --to")
+		}
+		if c.NumUnits > 1 {
+			return fmt.Errorf("cannot add multiple units of service %q to a single machine", c.ServiceName)
+		}
+		if err := checkMachineSpec(c.ToMachineSpec); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// checkMachineSpec returns an error if spec does not name a machine
+// or container ("1" or "1/lxc/0"), or describe a new container on an
+// existing or new machine ("1/lxc" or "/lxc").
+func checkMachineSpec(spec string) error {
+	if state.IsMachineId(spec) {
+		return nil
+	}
+	sep := strings.LastIndex(spec, "/")
+	if sep < 0 || sep > 0 && !state.IsMachineId(spec[:sep]) {
+		return fmt.Errorf("invalid machine specification %q", spec)
+	}
+	_, err := instance.ParseSupportedContainerType(spec[sep+1:])
+	return err
+}
+
 // Run connects to the environment specified on the command line
 // and calls conn.AddUnits.
-func (c *AddUnitCommand) Run(_ *cmd.Context) error {
-	conn, err := juju.NewConnFromName(c.EnvName)
-	if err != nil {
+func (c *AddUnitCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *AddUnitCommand) run(ctx *cmd.Context) error {
+	var names []string
+	addUnits := func(st *state.State, serviceName string) error {
+		params := params.AddServiceUnits{
+			ServiceName:   serviceName,
+			NumUnits:      c.NumUnits,
+			ToMachineSpec: c.ToMachineSpec,
+			Constraints:   c.Constraints,
+		}
+		units, err := statecmd.AddServiceUnits(st, params)
+		for _, unit := range units {
+			names = append(names, unit.Name())
+		}
 		return err
 	}
-	defer conn.Close()
-
-	params := params.AddServiceUnits{
-		ServiceName: c.ServiceName,
-		NumUnits:    c.NumUnits,
+	results, err := statecmd.Bulk(c.EnvName, []string{c.ServiceName}, addUnits)
+	if err == nil {
+		err = statecmd.BulkError(results)
 	}
-	_, err = statecmd.AddServiceUnits(conn.State, params)
-	return err
+	if err != nil {
+		return c.Report(ctx, nil, err)
+	}
+	return c.Report(ctx, map[string][]string{"units": names}, nil)
 }
@@ -4,9 +4,13 @@
 package main
 
 import (
+	"encoding/json"
 	. "launchpad.net/gocheck"
 	"launchpad.net/juju-core/charm"
+	"launchpad.net/juju-core/cmd"
+	"launchpad.net/juju-core/constraints"
 	jujutesting "launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/testing"
 )
 
@@ -36,3 +40,149 @@ func (s *AddUnitSuite) TestAddUnit(c *C) {
 	c.Assert(err, IsNil)
 	s.AssertService(c, "some-service-name", curl, 4, 0)
 }
+
+func (s *AddUnitSuite) TestAddUnitTo(c *C) {
+	testing.Charms.BundlePath(s.SeriesPath, "dummy")
+	err := runDeploy(c, "local:dummy", "some-service-name")
+	c.Assert(err, IsNil)
+	m, err := s.State.AddMachine("precise", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	err = runAddUnit(c, "--to", m.Id(), "some-service-name")
+	c.Assert(err, IsNil)
+	s.assertUnitMachine(c, "some-service-name/1", m.Id())
+
+	err = runAddUnit(c, "--to", m.Id()+"/lxc", "some-service-name")
+	c.Assert(err, IsNil)
+	s.assertUnitMachine(c, "some-service-name/2", m.Id()+"/lxc/0")
+}
+
+func (s *AddUnitSuite) assertUnitMachine(c *C, unitName, machineId string) {
+	unit, err := s.State.Unit(unitName)
+	c.Assert(err, IsNil)
+	id, err := unit.AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, machineId)
+}
+
+func (s *AddUnitSuite) TestInitTo(c *C) {
+	for i, t := range []struct {
+		args []string
+		err  string
+	}{{
+		args: []string{"--to", "1", "svc"},
+	}, {
+		args: []string{"--to", "1/lxc/0", "svc"},
+	}, {
+		args: []string{"--to", "1/lxc", "svc"},
+	}, {
+		args: []string{"--to", "/lxc", "svc"},
+	}, {
+		args: []string{"--to", "1", "-n", "2", "svc"},
+		err:  `cannot add multiple units of service "svc" to a single machine`,
+	}, {
+		args: []string{"--to", "foo", "svc"},
+		err:  `invalid machine specification "foo"`,
+	}, {
+		args: []string{"--to", "foo/lxc", "svc"},
+		err:  `invalid machine specification "foo/lxc"`,
+	}, {
+		args: []string{"--to", "1/bogus", "svc"},
+		err:  `invalid container type "bogus", expected one of "lxc"`,
+	}} {
+		c.Logf("test %d: %v", i, t.args)
+		com := &AddUnitCommand{}
+		err := testing.InitCommand(com, t.args)
+		if t.err != "" {
+			c.Assert(err, ErrorMatches, t.err)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(com.ToMachineSpec, Equals, t.args[1])
+	}
+}
+
+func (s *AddUnitSuite) TestAddUnitWithConstraints(c *C) {
+	testing.Charms.BundlePath(s.SeriesPath, "dummy")
+	err := runDeploy(c, "local:dummy", "some-service-name", "--constraints", "cpu-cores=2 mem=1G")
+	c.Assert(err, IsNil)
+
+	err = runAddUnit(c, "--constraints", "mem=4G", "-n", "2", "some-service-name")
+	c.Assert(err, IsNil)
+	expect := constraints.MustParse("cpu-cores=2 mem=4G")
+	for _, name := range []string{"some-service-name/1", "some-service-name/2"} {
+		unit, err := s.State.Unit(name)
+		c.Assert(err, IsNil)
+		id, err := unit.AssignedMachineId()
+		c.Assert(err, IsNil)
+		m, err := s.State.Machine(id)
+		c.Assert(err, IsNil)
+		mcons, err := m.Constraints()
+		c.Assert(err, IsNil)
+		c.Assert(mcons, DeepEquals, expect)
+	}
+}
+
+func (s *AddUnitSuite) TestAddUnitWithConstraintsSubordinate(c *C) {
+	testing.Charms.BundlePath(s.SeriesPath, "logging")
+	err := runDeploy(c, "local:logging")
+	c.Assert(err, IsNil)
+
+	err = runAddUnit(c, "--constraints", "mem=4G", "logging")
+	c.Assert(err, ErrorMatches, "constraints do not apply to subordinate services")
+}
+
+func (s *AddUnitSuite) TestInitConstraints(c *C) {
+	com := &AddUnitCommand{}
+	err := testing.InitCommand(com, []string{"--constraints", "mem=4G arch=i386", "svc"})
+	c.Assert(err, IsNil)
+	c.Assert(com.Constraints, DeepEquals, constraints.MustParse("mem=4G arch=i386"))
+
+	err = testing.InitCommand(&AddUnitCommand{}, []string{"--constraints", "mem=lots", "svc"})
+	c.Assert(err, ErrorMatches, `invalid value "mem=lots" for flag .*constraints.*`)
+
+	err = testing.InitCommand(&AddUnitCommand{}, []string{"--constraints", "mem=1G mem=2G", "svc"})
+	c.Assert(err, ErrorMatches, `invalid value "mem=1G mem=2G" for flag .*constraints.*`)
+
+	err = testing.InitCommand(&AddUnitCommand{}, []string{"--constraints", "mem=4G", "--to", "1", "svc"})
+	c.Assert(err, ErrorMatches, "cannot use --constraints with --to")
+}
+
+func (s *AddUnitSuite) TestAddUnitFormatJSON(c *C) {
+	testing.Charms.BundlePath(s.SeriesPath, "dummy")
+	err := runDeploy(c, "local:dummy", "some-service-name")
+	c.Assert(err, IsNil)
+
+	ctx, err := testing.RunCommand(c, &AddUnitCommand{}, []string{"--format", "json", "-n", "2", "some-service-name"})
+	c.Assert(err, IsNil)
+	var result map[string][]string
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string][]string{
+		"units": {"some-service-name/1", "some-service-name/2"},
+	})
+}
+
+func (s *AddUnitSuite) TestAddUnitFormatJSONError(c *C) {
+	ctx, err := testing.RunCommand(c, &AddUnitCommand{}, []string{"--format", "json", "no-such-service"})
+	c.Assert(err, Equals, cmd.ErrSilent)
+	var result map[string]map[string]string
+	err = json.Unmarshal([]byte(testing.Stdout(ctx)), &result)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string]map[string]string{
+		"error": {
+			"message": `service "no-such-service" not found`,
+			"code":    "not found",
+		},
+	})
+}
+
+func (s *AddUnitSuite) TestInitFormat(c *C) {
+	com := &AddUnitCommand{}
+	err := testing.InitCommand(com, []string{"some-service-name"})
+	c.Assert(err, IsNil)
+	c.Assert(com.Format, Equals, "text")
+
+	err = testing.InitCommand(&AddUnitCommand{}, []string{"--format", "yaml", "some-service-name"})
+	c.Assert(err, ErrorMatches, `invalid value "yaml" for flag .*format.*unknown format "yaml"`)
+}
@@ -12,6 +12,7 @@ import (
 	"launchpad.net/juju-core/charm"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
 	coretesting "launchpad.net/juju-core/testing"
 )
 
@@ -153,6 +154,21 @@ func (s *ConfigSuite) TestSetConfig(c *C) {
 	}
 }
 
+func (s *ConfigSuite) TestSetConfigRecordsHistory(c *C) {
+	sch := s.AddTestingCharm(c, "dummy")
+	svc, err := s.State.AddService("dummy-service", sch)
+	c.Assert(err, IsNil)
+	code := cmd.Main(&SetCommand{}, coretesting.Context(c), []string{"dummy-service", "username=hello"})
+	c.Assert(code, Equals, 0)
+	history, err := svc.ConfigHistory(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Assert(history[0].ActorTag, Equals, "user-admin")
+	c.Assert(history[0].Changes, DeepEquals, []state.ItemChange{
+		{state.ItemAdded, "username", nil, "hello"},
+	})
+}
+
 func setupConfigfile(c *C, dir string) string {
 	ctx := coretesting.ContextForDir(c, dir)
 	path := ctx.AbsPath("testconfig.yaml")
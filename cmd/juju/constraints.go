@@ -8,10 +8,12 @@ import (
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/statecmd"
+	"strings"
 )
 
 // GetConstraintsCommand shows the constraints for a service or environment.
@@ -35,6 +37,7 @@ func formatConstraints(value interface{}) ([]byte, error) {
 
 func (c *GetConstraintsCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	c.out.AddFlags(f, "constraints", map[string]cmd.Formatter{
 		"constraints": formatConstraints,
 		"yaml":        cmd.FormatYaml,
@@ -53,6 +56,10 @@ func (c *GetConstraintsCommand) Init(args []string) error {
 }
 
 func (c *GetConstraintsCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *GetConstraintsCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
@@ -76,7 +83,18 @@ func (c *GetConstraintsCommand) Run(ctx *cmd.Context) error {
 	return c.out.Write(ctx, cons)
 }
 
-// SetConstraintsCommand shows the constraints for a service or environment.
+const setConstraintsDoc = `
+Replaces the constraints of the environment, or of the service named either
+as the first argument or with --service. Constraints are applied when machines
+are provisioned, so changing a service's constraints affects only units added
+after the change; existing units keep the constraints they were created with.
+
+Without a service, the environment constraints are replaced; these are
+inherited by every new machine, and combined with any service constraints.
+Constraints the environment's provider cannot honour are rejected.
+`
+
+// SetConstraintsCommand sets the constraints for a service or environment.
 type SetConstraintsCommand struct {
 	EnvCommandBase
 	ServiceName string
@@ -86,18 +104,26 @@ type SetConstraintsCommand struct {
 func (c *SetConstraintsCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "set-constraints",
-		Args:    "[key=[value] ...]",
+		Args:    "[<service>] [key=[value] ...]",
 		Purpose: "replace constraints",
+		Doc:     setConstraintsDoc,
 	}
 }
 
 func (c *SetConstraintsCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	f.StringVar(&c.ServiceName, "s", "", "set service constraints")
 	f.StringVar(&c.ServiceName, "service", "", "")
 }
 
 func (c *SetConstraintsCommand) Init(args []string) (err error) {
+	if len(args) > 0 && !strings.Contains(args[0], "=") {
+		if c.ServiceName != "" {
+			return fmt.Errorf("cannot specify service %q with --service %q", args[0], c.ServiceName)
+		}
+		c.ServiceName, args = args[0], args[1:]
+	}
 	if c.ServiceName != "" && !state.IsServiceName(c.ServiceName) {
 		return fmt.Errorf("invalid service name %q", c.ServiceName)
 	}
@@ -105,12 +131,19 @@ func (c *SetConstraintsCommand) Init(args []string) (err error) {
 	return err
 }
 
-func (c *SetConstraintsCommand) Run(_ *cmd.Context) (err error) {
+func (c *SetConstraintsCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *SetConstraintsCommand) run(_ *cmd.Context) (err error) {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
+	if err := environs.CheckConstraints(conn.Environ, c.Constraints); err != nil {
+		return err
+	}
 	if c.ServiceName == "" {
 		return conn.State.SetEnvironConstraints(c.Constraints)
 	}
@@ -8,8 +8,11 @@ import (
 	. "launchpad.net/gocheck"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs/dummy"
 	"launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
 	coretesting "launchpad.net/juju-core/testing"
+	"strings"
 )
 
 type ConstraintsCommandsSuite struct {
@@ -75,6 +78,109 @@ func (s *ConstraintsCommandsSuite) TestSetService(c *C) {
 	c.Assert(cons, DeepEquals, constraints.Value{})
 }
 
+func (s *ConstraintsCommandsSuite) TestSetServiceArg(c *C) {
+	svc, err := s.State.AddService("svc", s.AddTestingCharm(c, "dummy"))
+	c.Assert(err, IsNil)
+
+	assertSet(c, "svc", "mem=4G", "cpu-power=250")
+	cons, err := svc.Constraints()
+	c.Assert(err, IsNil)
+	c.Assert(cons, DeepEquals, constraints.Value{
+		CpuPower: uint64p(250),
+		Mem:      uint64p(4096),
+	})
+
+	assertSet(c, "svc")
+	cons, err = svc.Constraints()
+	c.Assert(err, IsNil)
+	c.Assert(cons, DeepEquals, constraints.Value{})
+}
+
+func (s *ConstraintsCommandsSuite) TestSetServiceAffectsOnlyNewUnits(c *C) {
+	svc, err := s.State.AddService("svc", s.AddTestingCharm(c, "dummy"))
+	c.Assert(err, IsNil)
+	oldUnit, err := svc.AddUnit()
+	c.Assert(err, IsNil)
+
+	assertSet(c, "svc", "mem=4G")
+	newUnit, err := svc.AddUnit()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		unit   *state.Unit
+		expect constraints.Value
+	}{
+		{oldUnit, constraints.Value{}},
+		{newUnit, constraints.Value{Mem: uint64p(4096)}},
+	} {
+		err := t.unit.AssignToNewMachine()
+		c.Assert(err, IsNil)
+		id, err := t.unit.AssignedMachineId()
+		c.Assert(err, IsNil)
+		m, err := s.State.Machine(id)
+		c.Assert(err, IsNil)
+		cons, err := m.Constraints()
+		c.Assert(err, IsNil)
+		c.Assert(cons, DeepEquals, t.expect)
+	}
+}
+
+func (s *ConstraintsCommandsSuite) TestServiceRoundTrip(c *C) {
+	svc, err := s.State.AddService("svc", s.AddTestingCharm(c, "dummy"))
+	c.Assert(err, IsNil)
+	for i, t := range []string{
+		"",
+		"mem=4G",
+		"arch=i386 cpu-cores=2 cpu-power=250 mem=512M",
+		"cpu-power= mem=",
+		"container=lxc",
+	} {
+		c.Logf("test %d: %q", i, t)
+		assertSet(c, append([]string{"svc"}, strings.Fields(t)...)...)
+		_, stdout, _ := runCmdLine(c, &GetConstraintsCommand{}, "svc")
+		got, err := constraints.Parse(strings.TrimSpace(stdout))
+		c.Assert(err, IsNil)
+		cons, err := svc.Constraints()
+		c.Assert(err, IsNil)
+		c.Assert(got, DeepEquals, cons)
+		c.Assert(cons, DeepEquals, constraints.MustParse(t))
+	}
+}
+
+func (s *ConstraintsCommandsSuite) TestEnvironRoundTrip(c *C) {
+	for i, t := range []string{
+		"",
+		"mem=4G",
+		"arch=i386 cpu-cores=2 cpu-power=250 mem=512M",
+		"cpu-power= mem=",
+		"container=lxc",
+	} {
+		c.Logf("test %d: %q", i, t)
+		assertSet(c, strings.Fields(t)...)
+		_, stdout, _ := runCmdLine(c, &GetConstraintsCommand{})
+		got, err := constraints.Parse(strings.TrimSpace(stdout))
+		c.Assert(err, IsNil)
+		cons, err := s.State.EnvironConstraints()
+		c.Assert(err, IsNil)
+		c.Assert(got, DeepEquals, cons)
+		c.Assert(cons, DeepEquals, constraints.MustParse(t))
+	}
+}
+
+func (s *ConstraintsCommandsSuite) TestSetUnsupported(c *C) {
+	_, err := s.State.AddService("svc", s.AddTestingCharm(c, "dummy"))
+	c.Assert(err, IsNil)
+	dummy.SetSupportedConstraints("arch", "mem")
+
+	assertSet(c, "arch=amd64", "mem=4G")
+	assertSetError(c, 1, `constraints not supported by environment "dummyenv": cpu-power`, "mem=2G", "cpu-power=250")
+	assertSetError(c, 1, `constraints not supported by environment "dummyenv": cpu-cores`, "svc", "cpu-cores=2")
+
+	cons, err := s.State.EnvironConstraints()
+	c.Assert(err, IsNil)
+	c.Assert(cons, DeepEquals, constraints.MustParse("arch=amd64 mem=4G"))
+}
+
 func assertSetError(c *C, code int, stderr string, args ...string) {
 	rcode, rstdout, rstderr := runCmdLine(c, &SetConstraintsCommand{}, args...)
 	c.Assert(rcode, Equals, code)
@@ -87,6 +193,9 @@ func (s *ConstraintsCommandsSuite) TestSetErrors(c *C) {
 	assertSetError(c, 2, `malformed constraint "="`, "=")
 	assertSetError(c, 2, `malformed constraint "="`, "-s", "s", "=")
 	assertSetError(c, 1, `service "missing" not found`, "-s", "missing")
+	assertSetError(c, 2, `invalid service name "badname-0"`, "badname-0")
+	assertSetError(c, 2, `cannot specify service "svc" with --service "other"`, "-s", "other", "svc")
+	assertSetError(c, 1, `service "missing" not found`, "missing", "mem=4G")
 }
 
 func assertGet(c *C, stdout string, args ...string) {
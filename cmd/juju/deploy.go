@@ -59,6 +59,7 @@ func (c *DeployCommand) Info() *cmd.Info {
 
 func (c *DeployCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	f.IntVar(&c.NumUnits, "n", 1, "number of service units to deploy for principal charms")
 	f.IntVar(&c.NumUnits, "num-units", 1, "")
 	f.StringVar(&c.ForceMachineId, "force-machine", "", "Machine to deploy initial unit, bypasses constraints")
@@ -103,11 +104,15 @@ func (c *DeployCommand) Init(args []string) error {
 }
 
 func (c *DeployCommand) Run(ctx *cmd.Context) error {
-	conn, err := juju.NewConnFromName(c.EnvName)
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *DeployCommand) run(ctx *cmd.Context) error {
+	conn, release, err := c.Connect()
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	defer release()
 	conf, err := conn.State.EnvironConfig()
 	if err != nil {
 		return err
@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state"
@@ -26,6 +27,11 @@ func (c *DestroyMachineCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *DestroyMachineCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *DestroyMachineCommand) Init(args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no machines specified")
@@ -39,7 +45,11 @@ func (c *DestroyMachineCommand) Init(args []string) error {
 	return nil
 }
 
-func (c *DestroyMachineCommand) Run(_ *cmd.Context) error {
+func (c *DestroyMachineCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *DestroyMachineCommand) run(_ *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
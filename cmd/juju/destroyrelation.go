@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state/api/params"
@@ -26,6 +27,11 @@ func (c *DestroyRelationCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *DestroyRelationCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *DestroyRelationCommand) Init(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("a relation must involve two services")
@@ -34,7 +40,11 @@ func (c *DestroyRelationCommand) Init(args []string) error {
 	return nil
 }
 
-func (c *DestroyRelationCommand) Run(_ *cmd.Context) error {
+func (c *DestroyRelationCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *DestroyRelationCommand) run(_ *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
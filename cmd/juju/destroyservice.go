@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state"
@@ -27,6 +28,11 @@ func (c *DestroyServiceCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *DestroyServiceCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *DestroyServiceCommand) Init(args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no service specified")
@@ -38,7 +44,11 @@ func (c *DestroyServiceCommand) Init(args []string) error {
 	return cmd.CheckEmpty(args)
 }
 
-func (c *DestroyServiceCommand) Run(_ *cmd.Context) error {
+func (c *DestroyServiceCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *DestroyServiceCommand) run(_ *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
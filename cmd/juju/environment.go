@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/juju"
 	"strings"
 )
@@ -42,6 +44,7 @@ func (c *GetEnvironmentCommand) Info() *cmd.Info {
 
 func (c *GetEnvironmentCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	c.out.AddFlags(f, "smart", cmd.DefaultFormatters)
 }
 
@@ -51,6 +54,10 @@ func (c *GetEnvironmentCommand) Init(args []string) (err error) {
 }
 
 func (c *GetEnvironmentCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *GetEnvironmentCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
@@ -82,27 +89,49 @@ type attributes map[string]interface{}
 // SetEnvironment
 type SetEnvironmentCommand struct {
 	EnvCommandBase
-	values attributes
+	values   attributes
+	FromFile cmd.FileVar
+	DryRun   bool
 }
 
 const setEnvHelpDoc = `
 Updates the environment of a running Juju instance.  Multiple key/value pairs
 can be passed on as command line arguments.
+
+Alternatively, --from-file names a YAML file holding a complete environment
+configuration, such as one exported from a running environment.  The file is
+compared with the current configuration, the differences are shown, and only
+the changed values are applied; values absent from the file are left as they
+are.  With --dry-run the differences are shown and checked, but not applied.
 `
 
 func (c *SetEnvironmentCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "set-environment",
-		Args:    "key=[value] ...",
+		Args:    "key=[value] ... | --from-file <path>",
 		Purpose: "replace environment values",
 		Doc:     strings.TrimSpace(setEnvHelpDoc),
 		Aliases: []string{"set-env"},
 	}
 }
 
-// SetFlags handled entirely by EnvCommandBase
+func (c *SetEnvironmentCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+	f.Var(&c.FromFile, "from-file", "path to a YAML file holding the complete environment configuration")
+	f.BoolVar(&c.DryRun, "dry-run", false, "show the changes --from-file would make without applying them")
+}
 
 func (c *SetEnvironmentCommand) Init(args []string) (err error) {
+	if c.FromFile.Path != "" {
+		if len(args) != 0 {
+			return fmt.Errorf("cannot specify key, value pairs with --from-file")
+		}
+		return nil
+	}
+	if c.DryRun {
+		return fmt.Errorf("--dry-run requires --from-file")
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("No key, value pairs specified")
 	}
@@ -127,6 +156,10 @@ func (c *SetEnvironmentCommand) Init(args []string) (err error) {
 }
 
 func (c *SetEnvironmentCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *SetEnvironmentCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
@@ -140,6 +173,14 @@ func (c *SetEnvironmentCommand) Run(ctx *cmd.Context) error {
 	if err != nil {
 		return err
 	}
+	if c.FromFile.Path != "" {
+		if c.values, err = c.readChanges(ctx, oldConfig); err != nil {
+			return err
+		}
+		if len(c.values) == 0 {
+			return nil
+		}
+	}
 	// Apply the attributes specified for the command to the state config.
 	newConfig, err := oldConfig.Apply(c.values)
 	if err != nil {
@@ -151,6 +192,52 @@ func (c *SetEnvironmentCommand) Run(ctx *cmd.Context) error {
 	if err != nil {
 		return err
 	}
+	// Warn about a default-series that new machines could not be
+	// started with, since that would otherwise only surface at
+	// deploy time.
+	if series, ok := c.values["default-series"]; ok {
+		if err := environs.CheckDefaultSeriesTools(conn.Environ, newProviderConfig); err != nil {
+			fmt.Fprintf(ctx.Stderr, "warning: no tools found for default-series %q: %v\n", series, err)
+		}
+	}
+	if c.DryRun {
+		return nil
+	}
 	// Now try to apply the new validated config.
 	return conn.State.SetEnvironConfig(newProviderConfig)
 }
+
+// readChanges reads the configuration named by --from-file, writes the
+// differences between it and the current configuration to the
+// context's stdout, and returns the changed attributes. Attributes
+// redacted in the file keep their current values.
+func (c *SetEnvironmentCommand) readChanges(ctx *cmd.Context, current *config.Config) (attributes, error) {
+	data, err := c.FromFile.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fileConfig, err := config.UnmarshalWithSecrets(data, current.AllAttrs())
+	if err != nil {
+		return nil, err
+	}
+	values := make(attributes)
+	for _, change := range current.Diff(fileConfig) {
+		if change.New == nil {
+			// Attributes cannot be removed; leave them be.
+			continue
+		}
+		if change.Name == "agent-version" {
+			return nil, fmt.Errorf("agent-version must be set via upgrade-juju")
+		}
+		if change.Old == nil {
+			fmt.Fprintf(ctx.Stdout, "%s: %v (new)\n", change.Name, change.New)
+		} else {
+			fmt.Fprintf(ctx.Stdout, "%s: %v -> %v\n", change.Name, change.Old, change.New)
+		}
+		values[change.Name] = change.New
+	}
+	if len(values) == 0 {
+		fmt.Fprintf(ctx.Stdout, "no changes\n")
+	}
+	return values, nil
+}
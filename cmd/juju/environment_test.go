@@ -5,9 +5,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	. "launchpad.net/gocheck"
 	jujutesting "launchpad.net/juju-core/juju/testing"
 	"launchpad.net/juju-core/testing"
+	"path/filepath"
 	"strings"
 )
 
@@ -82,6 +84,12 @@ var setEnvInitTests = []struct {
 	{
 		args: []string{},
 		err:  "No key, value pairs specified",
+	}, {
+		args: []string{"--dry-run", "key=value"},
+		err:  "--dry-run requires --from-file",
+	}, {
+		args: []string{"--from-file", "env.yaml", "key=value"},
+		err:  "cannot specify key, value pairs with --from-file",
 	}, {
 		args: []string{"agent-version=1.2.3"},
 		err:  `agent-version must be set via upgrade-juju`,
@@ -125,6 +133,23 @@ func (s *SetEnvironmentSuite) TestChangeDefaultSeries(c *C) {
 	c.Assert(stateConfig.DefaultSeries(), Equals, "raring")
 }
 
+func (s *SetEnvironmentSuite) TestChangeDefaultSeriesWithTools(c *C) {
+	context, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"default-series=precise"})
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stderr(context), Equals, "")
+}
+
+func (s *SetEnvironmentSuite) TestChangeDefaultSeriesWithoutTools(c *C) {
+	context, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"default-series=spartan"})
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stderr(context), Matches, `warning: no tools found for default-series "spartan": .*\n`)
+
+	// The change is still made.
+	stateConfig, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	c.Assert(stateConfig.DefaultSeries(), Equals, "spartan")
+}
+
 func (s *SetEnvironmentSuite) TestChangeMultipleValues(c *C) {
 	_, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"default-series=spartan", "broken=nope", "secret=sekrit"})
 	c.Assert(err, IsNil)
@@ -162,3 +187,93 @@ func (s *SetEnvironmentSuite) TestImmutableConfigValues(c *C) {
 		c.Assert(err, ErrorMatches, errorPattern)
 	}
 }
+
+// writeEnvFile writes the current environment configuration, changed
+// by attrs, to a file and returns its path.
+func (s *SetEnvironmentSuite) writeEnvFile(c *C, attrs map[string]interface{}) string {
+	cfg, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	cfg, err = cfg.Apply(attrs)
+	c.Assert(err, IsNil)
+	data, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	path := filepath.Join(c.MkDir(), "environment.yaml")
+	err = ioutil.WriteFile(path, data, 0644)
+	c.Assert(err, IsNil)
+	return path
+}
+
+func (s *SetEnvironmentSuite) TestFromFile(c *C) {
+	path := s.writeEnvFile(c, map[string]interface{}{
+		"default-series": "raring",
+		"new-key":        "new-value",
+	})
+	context, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path})
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stdout(context), Equals, ""+
+		"default-series: precise -> raring\n"+
+		"new-key: new-value (new)\n",
+	)
+
+	stateConfig, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	attrs := stateConfig.AllAttrs()
+	c.Assert(attrs["default-series"], Equals, "raring")
+	c.Assert(attrs["new-key"], Equals, "new-value")
+}
+
+func (s *SetEnvironmentSuite) TestFromFileDryRun(c *C) {
+	path := s.writeEnvFile(c, map[string]interface{}{
+		"default-series": "raring",
+	})
+	context, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path, "--dry-run"})
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stdout(context), Equals, "default-series: precise -> raring\n")
+
+	stateConfig, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	c.Assert(stateConfig.DefaultSeries(), Equals, "precise")
+}
+
+func (s *SetEnvironmentSuite) TestFromFileDryRunValidates(c *C) {
+	path := s.writeEnvFile(c, map[string]interface{}{
+		"firewall-mode": "global",
+	})
+	_, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path, "--dry-run"})
+	c.Assert(err, ErrorMatches, `cannot change firewall-mode from .* to "global"`)
+}
+
+func (s *SetEnvironmentSuite) TestFromFileNoChanges(c *C) {
+	before, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	path := s.writeEnvFile(c, nil)
+	context, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path})
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stdout(context), Equals, "no changes\n")
+
+	after, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	c.Assert(after.AllAttrs(), DeepEquals, before.AllAttrs())
+}
+
+func (s *SetEnvironmentSuite) TestFromFileImmutable(c *C) {
+	path := s.writeEnvFile(c, map[string]interface{}{
+		"default-series": "raring",
+		"firewall-mode":  "global",
+	})
+	_, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path})
+	c.Assert(err, ErrorMatches, `cannot change firewall-mode from .* to "global"`)
+
+	// Nothing is changed.
+	stateConfig, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	c.Assert(stateConfig.DefaultSeries(), Equals, "precise")
+}
+
+func (s *SetEnvironmentSuite) TestFromFileAgentVersion(c *C) {
+	path := s.writeEnvFile(c, map[string]interface{}{
+		"agent-version": "9.9.9",
+	})
+	_, err := testing.RunCommand(c, &SetEnvironmentCommand{}, []string{"--from-file", path})
+	c.Assert(err, ErrorMatches, "agent-version must be set via upgrade-juju")
+}
@@ -4,15 +4,19 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/environs/config"
+	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/state/api/params"
 )
 
 const CurrentEnvironmentFilename = "current-environment"
@@ -22,8 +26,23 @@ const CurrentEnvironmentFilename = "current-environment"
 type EnvCommandBase struct {
 	cmd.CommandBase
 	EnvName string
+	// Timeout holds the value of the --timeout flag added by
+	// AddTimeoutFlag.
+	Timeout time.Duration
+	// Format holds the value of the --format flag added by
+	// AddFormatFlag.
+	Format string
+
+	conn     *juju.Conn
+	connRefs int
 }
 
+// openConn and closeConn are overridden in tests.
+var (
+	openConn  = juju.NewConnFromName
+	closeConn = func(conn *juju.Conn) error { return conn.Close() }
+)
+
 func getCurrentEnvironmentFilePath() string {
 	return filepath.Join(config.JujuHome(), CurrentEnvironmentFilename)
 }
@@ -67,3 +86,121 @@ func (c *EnvCommandBase) SetFlags(f *gnuflag.FlagSet) {
 	f.StringVar(&c.EnvName, "e", defaultEnv, "juju environment to operate in")
 	f.StringVar(&c.EnvName, "environment", defaultEnv, "")
 }
+
+// AddTimeoutFlag adds a --timeout flag limiting the time taken by
+// RunWithTimeout. It is not added by SetFlags because it only has an
+// effect on commands that use RunWithTimeout.
+func (c *EnvCommandBase) AddTimeoutFlag(f *gnuflag.FlagSet) {
+	f.DurationVar(&c.Timeout, "timeout", 0, "maximum time to wait for the command (0 means no limit); an operation still in progress when the time runs out may yet complete")
+}
+
+// RunWithTimeout calls f and returns its result. If the command's
+// --timeout is set and f has not returned within that time, an error is
+// returned without waiting any longer for f; the operation in progress is
+// abandoned when the command exits.
+func (c *EnvCommandBase) RunWithTimeout(f func() error) error {
+	if c.Timeout <= 0 {
+		return f()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(c.Timeout):
+		return fmt.Errorf("command timed out after %v", c.Timeout)
+	}
+}
+
+// Connect returns a connection to the command's environment, and a
+// function that releases it. The connection is made by the first call
+// and shared by any further calls made before it is released, so that
+// commands performing several operations need only connect once. The
+// connection is closed when every caller has released it.
+func (c *EnvCommandBase) Connect() (*juju.Conn, func(), error) {
+	if c.conn == nil {
+		conn, err := openConn(c.EnvName)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.conn = conn
+	}
+	c.connRefs++
+	released := false
+	release := func() {
+		if released {
+			return
+		}
+		released = true
+		c.connRefs--
+		if c.connRefs == 0 {
+			closeConn(c.conn)
+			c.conn = nil
+		}
+	}
+	return c.conn, release, nil
+}
+
+// formatValue implements gnuflag.Value for the --format flag
+// added by AddFormatFlag.
+type formatValue struct {
+	target *string
+}
+
+func (v formatValue) Set(s string) error {
+	if s != "text" && s != "json" {
+		return fmt.Errorf("unknown format %q", s)
+	}
+	*v.target = s
+	return nil
+}
+
+func (v formatValue) String() string {
+	return *v.target
+}
+
+// AddFormatFlag adds a --format flag selecting how the command reports
+// its outcome; see Report. It is not added by SetFlags because some
+// environment commands already use --format to select their output.
+func (c *EnvCommandBase) AddFormatFlag(f *gnuflag.FlagSet) {
+	c.Format = "text"
+	f.Var(formatValue{&c.Format}, "format", `how to report the outcome: "text" or "json"`)
+}
+
+// jsonError holds the JSON form of an error reported by Report.
+type jsonError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+}
+
+// Report returns the outcome of a command, as given by result and err,
+// in the format selected with --format. In the default text format err
+// is returned unchanged and result is ignored. In json format, result
+// (or an empty object if it is nil) is written to the context's stdout
+// on success; on failure {"error": {"message": ..., "code": ...}} is
+// written instead, using the API error codes where they apply, and
+// cmd.ErrSilent is returned so that the error is not printed again.
+func (c *EnvCommandBase) Report(ctx *cmd.Context, result interface{}, err error) error {
+	if c.Format != "json" {
+		return err
+	}
+	if err != nil {
+		perr := params.ServerError(err)
+		result = map[string]jsonError{
+			"error": {Message: perr.Message, Code: perr.Code},
+		}
+	} else if result == nil {
+		result = struct{}{}
+	}
+	data, merr := json.Marshal(result)
+	if merr != nil {
+		return merr
+	}
+	fmt.Fprintf(ctx.Stdout, "%s\n", data)
+	if err != nil {
+		return cmd.ErrSilent
+	}
+	return nil
+}
@@ -4,27 +4,42 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
+	"launchpad.net/gnuflag"
 	. "launchpad.net/gocheck"
+	"launchpad.net/juju-core/cmd"
+	"launchpad.net/juju-core/errors"
+	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/testing"
 )
 
 type EnvironmentCommandSuite struct {
 	home *testing.FakeHome
+
+	oldOpenConn  func(string) (*juju.Conn, error)
+	oldCloseConn func(*juju.Conn) error
 }
 
 var _ = Suite(&EnvironmentCommandSuite{})
 
 func (s *EnvironmentCommandSuite) SetUpTest(c *C) {
 	s.home = testing.MakeEmptyFakeHome(c)
+	s.oldOpenConn, s.oldCloseConn = openConn, closeConn
 }
 
 func (s *EnvironmentCommandSuite) TearDownTest(c *C) {
+	openConn, closeConn = s.oldOpenConn, s.oldCloseConn
 	s.home.Restore()
 }
 
+func (s *EnvironmentCommandSuite) patchConn(open func(string) (*juju.Conn, error), close func(*juju.Conn) error) {
+	openConn, closeConn = open, close
+}
+
 func (s *EnvironmentCommandSuite) TestReadCurrentEnvironmentUnset(c *C) {
 	env := readCurrentEnvironment()
 	c.Assert(env, Equals, "")
@@ -77,3 +92,157 @@ func (*EnvironmentCommandSuite) TestErrorWritingFile(c *C) {
 	err := writeCurrentEnvironment("fubar")
 	c.Assert(err, ErrorMatches, "unable to write to the environment file: .*")
 }
+
+func (s *EnvironmentCommandSuite) TestConnectReusesConnection(c *C) {
+	var opened, closed []*juju.Conn
+	s.patchConn(func(envName string) (*juju.Conn, error) {
+		c.Check(envName, Equals, "myenv")
+		conn := &juju.Conn{}
+		opened = append(opened, conn)
+		return conn, nil
+	}, func(conn *juju.Conn) error {
+		closed = append(closed, conn)
+		return nil
+	})
+
+	base := &EnvCommandBase{EnvName: "myenv"}
+	conn1, release1, err := base.Connect()
+	c.Assert(err, IsNil)
+	conn2, release2, err := base.Connect()
+	c.Assert(err, IsNil)
+	c.Assert(conn2, Equals, conn1)
+	c.Assert(opened, HasLen, 1)
+
+	release2()
+	release2()
+	c.Assert(closed, HasLen, 0)
+	release1()
+	c.Assert(closed, DeepEquals, []*juju.Conn{conn1})
+
+	// Once released, a new connection is made.
+	conn3, release3, err := base.Connect()
+	c.Assert(err, IsNil)
+	c.Assert(conn3, Not(Equals), conn1)
+	release3()
+	c.Assert(opened, HasLen, 2)
+	c.Assert(closed, HasLen, 2)
+}
+
+func (s *EnvironmentCommandSuite) TestConnectError(c *C) {
+	s.patchConn(func(string) (*juju.Conn, error) {
+		return nil, fmt.Errorf("no luck")
+	}, nil)
+	base := &EnvCommandBase{}
+	conn, release, err := base.Connect()
+	c.Assert(err, ErrorMatches, "no luck")
+	c.Assert(conn, IsNil)
+	c.Assert(release, IsNil)
+}
+
+func (s *EnvironmentCommandSuite) TestTimeoutFlagNotAddedBySetFlags(c *C) {
+	err := testing.InitCommand(&StatusCommand{}, []string{"--timeout", "1m"})
+	c.Assert(err, ErrorMatches, "flag provided but not defined: --timeout")
+}
+
+func (s *EnvironmentCommandSuite) TestTimeoutFlag(c *C) {
+	for _, com := range []cmd.Command{
+		&AddMachineCommand{},
+		&AddRelationCommand{},
+		&AddUnitCommand{},
+		&DeployCommand{},
+		&DestroyMachineCommand{},
+		&DestroyRelationCommand{},
+		&DestroyServiceCommand{},
+		&DestroyUnitCommand{},
+		&ExposeCommand{},
+		&GetCommand{},
+		&GetConstraintsCommand{},
+		&GetEnvironmentCommand{},
+		&ResolvedCommand{},
+		&RetryProvisioningCommand{},
+		&SetCommand{},
+		&SetConstraintsCommand{},
+		&SetEnvironmentCommand{},
+		&UnexposeCommand{},
+		&UpgradeCharmCommand{},
+		&UpgradeJujuCommand{},
+	} {
+		c.Logf("command %s", com.Info().Name)
+		f := gnuflag.NewFlagSet(com.Info().Name, gnuflag.ContinueOnError)
+		com.SetFlags(f)
+		c.Check(f.Lookup("timeout"), NotNil)
+	}
+}
+
+func (s *EnvironmentCommandSuite) TestRunWithTimeout(c *C) {
+	base := &EnvCommandBase{Timeout: 10 * time.Millisecond}
+	unblock := make(chan struct{})
+	defer close(unblock)
+	err := base.RunWithTimeout(func() error {
+		<-unblock
+		return nil
+	})
+	c.Assert(err, ErrorMatches, "command timed out after 10ms")
+}
+
+func (s *EnvironmentCommandSuite) TestRunWithTimeoutCompletes(c *C) {
+	base := &EnvCommandBase{Timeout: testing.LongWait}
+	err := base.RunWithTimeout(func() error {
+		return fmt.Errorf("failed quickly")
+	})
+	c.Assert(err, ErrorMatches, "failed quickly")
+}
+
+func (s *EnvironmentCommandSuite) TestRunWithoutTimeout(c *C) {
+	base := &EnvCommandBase{}
+	called := false
+	err := base.RunWithTimeout(func() error {
+		called = true
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(called, Equals, true)
+}
+
+func (s *EnvironmentCommandSuite) TestReportText(c *C) {
+	base := &EnvCommandBase{Format: "text"}
+	ctx := testing.Context(c)
+	err := base.Report(ctx, map[string]string{"machine": "0"}, nil)
+	c.Assert(err, IsNil)
+	err = base.Report(ctx, nil, fmt.Errorf("boom"))
+	c.Assert(err, ErrorMatches, "boom")
+	c.Assert(testing.Stdout(ctx), Equals, "")
+}
+
+func (s *EnvironmentCommandSuite) TestReportJSON(c *C) {
+	base := &EnvCommandBase{Format: "json"}
+	ctx := testing.Context(c)
+	err := base.Report(ctx, map[string]string{"machine": "0"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stdout(ctx), Equals, `{"machine":"0"}`+"\n")
+
+	ctx = testing.Context(c)
+	err = base.Report(ctx, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(testing.Stdout(ctx), Equals, "{}\n")
+}
+
+func (s *EnvironmentCommandSuite) TestReportJSONError(c *C) {
+	base := &EnvCommandBase{Format: "json"}
+	for i, t := range []struct {
+		err    error
+		expect string
+	}{{
+		err:    fmt.Errorf("boom"),
+		expect: `{"error":{"message":"boom","code":""}}`,
+	}, {
+		err:    errors.NotFoundf("machine 42"),
+		expect: `{"error":{"message":"machine 42 not found","code":"not found"}}`,
+	}} {
+		c.Logf("test %d", i)
+		ctx := testing.Context(c)
+		err := base.Report(ctx, map[string]string{"ignored": "result"}, t.err)
+		c.Assert(err, Equals, cmd.ErrSilent)
+		c.Assert(testing.Stdout(ctx), Equals, t.expect+"\n")
+	}
+}
@@ -5,8 +5,12 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
+	"launchpad.net/juju-core/environs"
 	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/log"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/statecmd"
 )
@@ -25,6 +29,11 @@ func (c *ExposeCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *ExposeCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *ExposeCommand) Init(args []string) error {
 	if len(args) == 0 {
 		return errors.New("no service name specified")
@@ -35,15 +44,34 @@ func (c *ExposeCommand) Init(args []string) error {
 
 // Run changes the juju-managed firewall to expose any
 // ports that were also explicitly marked by units as open.
-func (c *ExposeCommand) Run(_ *cmd.Context) error {
+func (c *ExposeCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *ExposeCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
+	svc, err := conn.State.Service(c.ServiceName)
+	if err != nil {
+		return err
+	}
+	if svc.IsExposed() {
+		fmt.Fprintf(ctx.Stdout, "service %q is already exposed\n", c.ServiceName)
+		return nil
+	}
 	params := params.ServiceExpose{
 		ServiceName: c.ServiceName,
 	}
-	return statecmd.ServiceExpose(conn.State, params)
+	if err := statecmd.ServiceExpose(conn.State, params); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.Stdout, "service %q exposed\n", c.ServiceName)
+	if !environs.SupportsFirewalling(conn.Environ) {
+		log.Warningf("firewalling not supported by this provider; ports opened by %q will not be managed", c.ServiceName)
+	}
+	return nil
 }
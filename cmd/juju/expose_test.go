@@ -6,8 +6,13 @@ package main
 import (
 	. "launchpad.net/gocheck"
 	"launchpad.net/juju-core/charm"
+	"launchpad.net/juju-core/environs/dummy"
+	"launchpad.net/juju-core/instance"
 	jujutesting "launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/testing"
+	"launchpad.net/juju-core/worker/firewaller"
+	"time"
 )
 
 type ExposeSuite struct {
@@ -21,6 +26,12 @@ func runExpose(c *C, args ...string) error {
 	return err
 }
 
+func runExposeOutput(c *C, args ...string) string {
+	ctx, err := testing.RunCommand(c, &ExposeCommand{}, args)
+	c.Assert(err, IsNil)
+	return testing.Stdout(ctx)
+}
+
 func (s *ExposeSuite) assertExposed(c *C, service string) {
 	svc, err := s.State.Service(service)
 	c.Assert(err, IsNil)
@@ -42,3 +53,61 @@ func (s *ExposeSuite) TestExpose(c *C) {
 	err = runExpose(c, "nonexistent-service")
 	c.Assert(err, ErrorMatches, `service "nonexistent-service" not found`)
 }
+
+func (s *ExposeSuite) TestExposeIdempotent(c *C) {
+	_, err := s.State.AddService("wordpress", s.AddTestingCharm(c, "wordpress"))
+	c.Assert(err, IsNil)
+
+	c.Assert(runExposeOutput(c, "wordpress"), Equals, "service \"wordpress\" exposed\n")
+	s.assertExposed(c, "wordpress")
+	c.Assert(runExposeOutput(c, "wordpress"), Equals, "service \"wordpress\" is already exposed\n")
+	s.assertExposed(c, "wordpress")
+}
+
+func (s *ExposeSuite) TestExposeWithoutFirewalling(c *C) {
+	dummy.SetSupportsFirewalling(false)
+	_, err := s.State.AddService("wordpress", s.AddTestingCharm(c, "wordpress"))
+	c.Assert(err, IsNil)
+
+	c.Assert(runExposeOutput(c, "wordpress"), Equals, "service \"wordpress\" exposed\n")
+	s.assertExposed(c, "wordpress")
+	c.Assert(c.GetTestLog(), Matches, `(.|\n)*WARNING juju firewalling not supported by this provider; ports opened by "wordpress" will not be managed\n(.|\n)*`)
+}
+
+func (s *ExposeSuite) TestExposeOpensPorts(c *C) {
+	ops := make(chan dummy.Operation, 500)
+	dummy.Listen(ops)
+	fw := firewaller.NewFirewaller(s.State)
+	defer func() { c.Assert(fw.Stop(), IsNil) }()
+
+	svc, err := s.State.AddService("wordpress", s.AddTestingCharm(c, "wordpress"))
+	c.Assert(err, IsNil)
+	units, err := s.Conn.AddUnits(svc, 1, state.AssignNew, "")
+	c.Assert(err, IsNil)
+	machineId, err := units[0].AssignedMachineId()
+	c.Assert(err, IsNil)
+	m, err := s.State.Machine(machineId)
+	c.Assert(err, IsNil)
+	inst, hc := jujutesting.StartInstance(c, s.Conn.Environ, m.Id())
+	err = m.SetProvisioned(inst.Id(), "fake_nonce", hc)
+	c.Assert(err, IsNil)
+	err = units[0].OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+
+	err = runExpose(c, "wordpress")
+	c.Assert(err, IsNil)
+	s.State.StartSync()
+	for {
+		select {
+		case op := <-ops:
+			if op, ok := op.(dummy.OpOpenPorts); ok {
+				c.Assert(op.MachineId, Equals, m.Id())
+				c.Assert(op.InstanceId, Equals, inst.Id())
+				c.Assert(op.Ports, DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}})
+				return
+			}
+		case <-time.After(testing.LongWait):
+			c.Fatalf("timed out waiting for ports to be opened")
+		}
+	}
+}
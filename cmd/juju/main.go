@@ -70,6 +70,7 @@ func Main(args []string) {
 	juju.Register(&SCPCommand{})
 	juju.Register(&SSHCommand{})
 	juju.Register(&ResolvedCommand{})
+	juju.Register(&RetryProvisioningCommand{})
 	juju.Register(&DebugLogCommand{sshCmd: &SSHCommand{}})
 
 	// Configuration commands.
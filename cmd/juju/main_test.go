@@ -236,6 +236,7 @@ var commandNames = []string{
 	"remove-relation", // alias for destroy-relation
 	"remove-unit",     // alias for destroy-unit
 	"resolved",
+	"retry-provisioning",
 	"scp",
 	"set",
 	"set-constraints",
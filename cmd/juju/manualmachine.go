@@ -0,0 +1,161 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/environs/cloudinit"
+	"launchpad.net/juju-core/instance"
+	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/log"
+	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/utils"
+)
+
+// manualHostPrefix introduces an add-machine argument naming an
+// existing host to be added to the environment over SSH.
+const manualHostPrefix = "ssh:"
+
+// provisionManualHost starts the machine agent on host, an existing
+// machine reachable over SSH, by running cloud-init there with the
+// supplied user data. It is a variable so that tests can replace it.
+var provisionManualHost = sshProvisionHost
+
+// addManualMachine adds the SSH host to the environment as a new machine,
+// and starts its machine agent. The host is recorded in state as already
+// provisioned, so the environment is never asked to start an instance
+// for it. If the agent cannot be started the machine is removed again.
+func addManualMachine(conn *juju.Conn, host string, params state.AddMachineParams) (*state.Machine, error) {
+	uuid, err := utils.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	nonce := fmt.Sprintf("%s%s", manualHostPrefix, uuid.String())
+	m, err := conn.State.AddManualMachine(params, manualInstanceId(host), nonce)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := manualUserData(conn, m, nonce)
+	if err == nil {
+		err = provisionManualHost(host, userData)
+	}
+	if err != nil {
+		if err1 := m.EnsureDead(); err1 != nil {
+			log.Warningf("cannot remove machine %v: %v", m, err1)
+		} else if err1 := m.Remove(); err1 != nil {
+			log.Warningf("cannot remove machine %v: %v", m, err1)
+		}
+		return nil, fmt.Errorf("cannot provision %q: %v", host, err)
+	}
+	return m, nil
+}
+
+// manualInstanceId returns the instance id recorded for a machine
+// added from the given host.
+func manualInstanceId(host string) instance.Id {
+	if at := strings.Index(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	return instance.Id(manualHostPrefix + host)
+}
+
+// manualUserData returns the cloud-init user data that starts the
+// agent for machine m, much as a provider would when starting an
+// instance for it.
+func manualUserData(conn *juju.Conn, m *state.Machine, nonce string) ([]byte, error) {
+	cfg, err := conn.State.EnvironConfig()
+	if err != nil {
+		return nil, err
+	}
+	environ, err := environs.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cons, err := m.Constraints()
+	if err != nil {
+		return nil, err
+	}
+	possibleTools, err := environs.FindInstanceTools(environ, m.Series(), cons)
+	if err != nil {
+		return nil, err
+	}
+	stateInfo, apiInfo, err := environ.StateInfo()
+	if err != nil {
+		return nil, err
+	}
+	password, err := utils.RandomPassword()
+	if err != nil {
+		return nil, fmt.Errorf("cannot make password for machine %v: %v", m, err)
+	}
+	if err := m.SetPassword(password); err != nil {
+		return nil, fmt.Errorf("cannot set API password for machine %v: %v", m, err)
+	}
+	if err := m.SetMongoPassword(password); err != nil {
+		return nil, fmt.Errorf("cannot set mongo password for machine %v: %v", m, err)
+	}
+	stateInfo.Tag, stateInfo.Password = m.Tag(), password
+	apiInfo.Tag, apiInfo.Password = m.Tag(), password
+	mcfg := &cloudinit.MachineConfig{
+		MachineId:    m.Id(),
+		MachineNonce: nonce,
+		StateInfo:    stateInfo,
+		APIInfo:      apiInfo,
+		DataDir:      "/var/lib/juju",
+		Tools:        possibleTools[0],
+	}
+	if err := environs.FinishMachineConfig(mcfg, cfg, cons); err != nil {
+		return nil, err
+	}
+	cloudcfg, err := cloudinit.New(mcfg)
+	if err != nil {
+		return nil, err
+	}
+	return cloudcfg.Render()
+}
+
+// manualProvisionScript returns a shell script that seeds cloud-init's
+// NoCloud data source with userData and runs cloud-init to apply it.
+func manualProvisionScript(instanceId instance.Id, userData []byte) string {
+	seed := "/var/lib/cloud/seed/nocloud-net"
+	return strings.Join([]string{
+		"set -e",
+		"mkdir -p " + seed,
+		fmt.Sprintf("echo %s | base64 -d > %s/user-data", base64.StdEncoding.EncodeToString(userData), seed),
+		fmt.Sprintf("echo 'instance-id: %s' > %s/meta-data", instanceId, seed),
+		"cloud-init init",
+		"cloud-init modules --mode=config",
+		"cloud-init modules --mode=final",
+	}, "\n") + "\n"
+}
+
+// manualConnectTimeout limits the time taken to connect to a host
+// being added over SSH.
+const manualConnectTimeout = 30 * time.Second
+
+// sshProvisionHost runs the provisioning script on host. The user data
+// holds the agent's secrets, so the host key must already be in
+// known_hosts. BatchMode stops ssh asking about an unknown host key or
+// for a password.
+func sshProvisionHost(host string, userData []byte) error {
+	args := []string{
+		"-o", "StrictHostKeyChecking yes",
+		"-o", "BatchMode yes",
+		"-o", fmt.Sprintf("ConnectTimeout %d", int(manualConnectTimeout/time.Second)),
+		host, "sudo", "/bin/bash",
+	}
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin = strings.NewReader(manualProvisionScript(manualInstanceId(host), userData))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
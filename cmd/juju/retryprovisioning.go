@@ -0,0 +1,61 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	"fmt"
+
+	"launchpad.net/gnuflag"
+	"launchpad.net/juju-core/cmd"
+	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/state"
+)
+
+// RetryProvisioningCommand asks the provisioner to try again to start
+// an instance for a machine whose provisioning failed.
+type RetryProvisioningCommand struct {
+	EnvCommandBase
+	MachineId string
+}
+
+func (c *RetryProvisioningCommand) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "retry-provisioning",
+		Args:    "<machine>",
+		Purpose: "retries provisioning for failed machines",
+	}
+}
+
+func (c *RetryProvisioningCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
+func (c *RetryProvisioningCommand) Init(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no machine specified")
+	}
+	c.MachineId = args[0]
+	if !state.IsMachineId(c.MachineId) {
+		return fmt.Errorf("invalid machine %q", c.MachineId)
+	}
+	return cmd.CheckEmpty(args[1:])
+}
+
+func (c *RetryProvisioningCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *RetryProvisioningCommand) run(_ *cmd.Context) error {
+	conn, err := juju.NewConnFromName(c.EnvName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	machine, err := conn.State.Machine(c.MachineId)
+	if err != nil {
+		return err
+	}
+	return machine.RetryProvisioning()
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package main
+
+import (
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/instance"
+	jujutesting "launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/state/api/params"
+	"launchpad.net/juju-core/testing"
+)
+
+type RetryProvisioningSuite struct {
+	jujutesting.RepoSuite
+}
+
+var _ = Suite(&RetryProvisioningSuite{})
+
+func runRetryProvisioning(c *C, args []string) error {
+	_, err := testing.RunCommand(c, &RetryProvisioningCommand{}, args)
+	return err
+}
+
+var retryProvisioningTests = []struct {
+	args    []string
+	err     string
+	machine string
+	status  params.Status
+}{
+	{
+		err: `no machine specified`,
+	}, {
+		args: []string{"jeremy-fisher"},
+		err:  `invalid machine "jeremy-fisher"`,
+	}, {
+		args: []string{"99"},
+		err:  `machine 99 not found`,
+	}, {
+		args:    []string{"0"},
+		err:     `cannot retry provisioning of machine 0: machine is already provisioned`,
+		machine: "0",
+		status:  params.StatusStarted,
+	}, {
+		args:    []string{"1"},
+		err:     `cannot retry provisioning of machine 1: machine is not in an error state`,
+		machine: "1",
+		status:  params.StatusPending,
+	}, {
+		args:    []string{"2"},
+		machine: "2",
+		status:  params.StatusPending,
+	}, {
+		args:    []string{"2"},
+		err:     `cannot retry provisioning of machine 2: machine is not in an error state`,
+		machine: "2",
+		status:  params.StatusPending,
+	}, {
+		args: []string{"2", "roflcopter"},
+		err:  `unrecognized args: \["roflcopter"\]`,
+	},
+}
+
+func (s *RetryProvisioningSuite) TestRetryProvisioning(c *C) {
+	m0, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	err = m0.SetProvisioned(instance.Id("i-am-0"), "fake_nonce", nil)
+	c.Assert(err, IsNil)
+	err = m0.SetStatus(params.StatusStarted, "")
+	c.Assert(err, IsNil)
+	_, err = s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	m2, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	err = m2.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+
+	for i, t := range retryProvisioningTests {
+		c.Logf("test %d: %v", i, t.args)
+		err := runRetryProvisioning(c, t.args)
+		if t.err != "" {
+			c.Assert(err, ErrorMatches, t.err)
+		} else {
+			c.Assert(err, IsNil)
+		}
+		if t.machine != "" {
+			m, err := s.State.Machine(t.machine)
+			c.Assert(err, IsNil)
+			status, _, err := m.Status()
+			c.Assert(err, IsNil)
+			c.Assert(status, Equals, t.status)
+		}
+	}
+}
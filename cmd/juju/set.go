@@ -33,6 +33,7 @@ func (c *SetCommand) Info() *cmd.Info {
 
 func (c *SetCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	f.Var(&c.SettingsYAML, "config", "path to yaml-formatted service config")
 }
 
@@ -54,6 +55,10 @@ func (c *SetCommand) Init(args []string) error {
 
 // Run updates the configuration of a service.
 func (c *SetCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *SetCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
@@ -85,7 +90,9 @@ func (c *SetCommand) Run(ctx *cmd.Context) error {
 	} else {
 		return nil
 	}
-	return service.UpdateConfigSettings(settings)
+	// The connection is made with the environment's admin secret,
+	// so the changes are recorded as made by the admin user.
+	return service.UpdateConfigSettingsAudited(settings, "user-admin")
 }
 
 // parse parses the option k=v strings into a map of options to be
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"launchpad.net/gnuflag"
@@ -13,22 +14,42 @@ import (
 	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/log"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/utils/set"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
 	"strings"
 )
 
 type StatusCommand struct {
 	EnvCommandBase
-	out cmd.Output
+	out      cmd.Output
+	patterns []string
+	watch    bool
 }
 
-var statusDoc = "This command will report on the runtime state of various system entities."
+var statusDoc = `
+This command will report on the runtime state of various system entities.
+
+If any service names, unit names or machine ids are given, only those
+entities are reported, together with the machines hosting their units and
+the units running on their machines or in containers on them.
+
+With --watch, the status is written once, followed by a summary of
+each change to the environment, until the command is interrupted. A
+summary gives the new status of the machines, services and units that
+have been added or changed, lists those that have been removed, and
+lists the units that have moved to another machine.
+`
 
 func (c *StatusCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "status",
+		Args:    "[<service> | <unit> | <machine> ...]",
 		Purpose: "output status information about an environment",
 		Doc:     statusDoc,
 		Aliases: []string{"stat"},
@@ -41,6 +62,17 @@ func (c *StatusCommand) SetFlags(f *gnuflag.FlagSet) {
 		"yaml": cmd.FormatYaml,
 		"json": cmd.FormatJson,
 	})
+	f.BoolVar(&c.watch, "watch", false, "write the changes to the status whenever it changes")
+}
+
+func (c *StatusCommand) Init(args []string) error {
+	for _, arg := range args {
+		if !state.IsMachineId(arg) && !state.IsUnitName(arg) && !state.IsServiceName(arg) {
+			return fmt.Errorf("invalid status filter %q", arg)
+		}
+	}
+	c.patterns = args
+	return nil
 }
 
 type statusContext struct {
@@ -56,13 +88,42 @@ func (c *StatusCommand) Run(ctx *cmd.Context) error {
 		return err
 	}
 	defer conn.Close()
+	if c.watch {
+		return c.watchStatus(ctx, conn)
+	}
+	return c.writeStatus(ctx, conn)
+}
 
-	var context statusContext
-	if context.machines, err = fetchAllMachines(conn.State); err != nil {
+// statusResult holds the status of an environment.
+type statusResult struct {
+	Machines map[string]machineStatus `json:"machines"`
+	Services map[string]serviceStatus `json:"services"`
+}
+
+// writeStatus writes the current status of the environment.
+func (c *StatusCommand) writeStatus(ctx *cmd.Context, conn *juju.Conn) error {
+	result, err := c.fetchStatus(ctx, conn)
+	if err != nil {
 		return err
 	}
-	if context.services, context.units, err = fetchAllServicesAndUnits(conn.State); err != nil {
-		return err
+	return c.out.Write(ctx, result)
+}
+
+// fetchStatus returns the current status of the environment.
+func (c *StatusCommand) fetchStatus(ctx *cmd.Context, conn *juju.Conn) (statusResult, error) {
+	var err error
+	var filter *statusFilter
+	if len(c.patterns) > 0 {
+		if filter, err = newStatusFilter(conn.State, c.patterns); err != nil {
+			return statusResult{}, err
+		}
+	}
+	var context statusContext
+	if context.machines, err = fetchAllMachines(conn.State, filter); err != nil {
+		return statusResult{}, err
+	}
+	if context.services, context.units, err = fetchAllServicesAndUnits(conn.State, filter); err != nil {
+		return statusResult{}, err
 	}
 	context.instances, err = fetchAllInstances(conn.Environ)
 	if err != nil {
@@ -70,14 +131,279 @@ func (c *StatusCommand) Run(ctx *cmd.Context) error {
 		// there's still lots of potentially useful info to print.
 		fmt.Fprintf(ctx.Stderr, "cannot retrieve instances from the environment: %v\n", err)
 	}
-	result := struct {
-		Machines map[string]machineStatus `json:"machines"`
-		Services map[string]serviceStatus `json:"services"`
-	}{
+	return statusResult{
 		Machines: context.processMachines(),
 		Services: context.processServices(),
+	}, nil
+}
+
+// notifyStatusInterrupt arranges for interrupt signals to be sent
+// to ch. It is a variable so that tests can interrupt status --watch.
+var notifyStatusInterrupt = func(ch chan<- os.Signal) {
+	signal.Notify(ch, os.Interrupt)
+}
+
+// watchStatus writes the status of the environment, and then writes
+// the changes to it whenever the environment changes, until interrupted.
+func (c *StatusCommand) watchStatus(ctx *cmd.Context, conn *juju.Conn) error {
+	w := conn.State.Watch()
+	interrupt := make(chan os.Signal, 1)
+	notifyStatusInterrupt(interrupt)
+	defer signal.Stop(interrupt)
+	interrupted := make(chan struct{})
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-interrupt:
+			close(interrupted)
+		case <-done:
+		}
+		if err := w.Stop(); err != nil {
+			log.Warningf("cannot stop status watcher: %v", err)
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	var last *statusResult
+	for {
+		// The first call returns at once, reporting the
+		// whole environment; later calls wait for changes.
+		if _, err := w.Next(); err != nil {
+			select {
+			case <-interrupted:
+				return nil
+			default:
+			}
+			return err
+		}
+		result, err := c.fetchStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			err = c.out.Write(ctx, result)
+		} else if change := diffStatus(*last, result); !change.isEmpty() {
+			err = c.out.Write(ctx, change)
+		}
+		if err != nil {
+			return err
+		}
+		last = &result
 	}
-	return c.out.Write(ctx, result)
+}
+
+// statusChange describes the differences between two statuses of an
+// environment. Added and changed entities are reported with their new
+// status, keyed by id or name; removed ones are reported by id or name
+// alone. Machines include containers, and units include subordinates.
+type statusChange struct {
+	AddedMachines   map[string]interface{} `json:"added-machines,omitempty" yaml:"added-machines,omitempty"`
+	RemovedMachines []string               `json:"removed-machines,omitempty" yaml:"removed-machines,omitempty"`
+	ChangedMachines map[string]interface{} `json:"changed-machines,omitempty" yaml:"changed-machines,omitempty"`
+	AddedServices   map[string]interface{} `json:"added-services,omitempty" yaml:"added-services,omitempty"`
+	RemovedServices []string               `json:"removed-services,omitempty" yaml:"removed-services,omitempty"`
+	ChangedServices map[string]interface{} `json:"changed-services,omitempty" yaml:"changed-services,omitempty"`
+	AddedUnits      map[string]interface{} `json:"added-units,omitempty" yaml:"added-units,omitempty"`
+	RemovedUnits    []string               `json:"removed-units,omitempty" yaml:"removed-units,omitempty"`
+	ChangedUnits    map[string]interface{} `json:"changed-units,omitempty" yaml:"changed-units,omitempty"`
+	ReassignedUnits map[string]string      `json:"reassigned-units,omitempty" yaml:"reassigned-units,omitempty"`
+}
+
+func (change statusChange) isEmpty() bool {
+	return reflect.DeepEqual(change, statusChange{})
+}
+
+// diffStatus returns the changes that turn old into new. Units that
+// are assigned to a different machine in new are reported in
+// ReassignedUnits, keyed by unit name, with their new machine.
+func diffStatus(old, new statusResult) statusChange {
+	var change statusChange
+	oldMachines, newMachines := flattenMachines(old.Machines), flattenMachines(new.Machines)
+	change.AddedMachines, change.RemovedMachines, change.ChangedMachines = diffEntities(oldMachines, newMachines)
+	oldServices, newServices := flattenServices(old.Services), flattenServices(new.Services)
+	change.AddedServices, change.RemovedServices, change.ChangedServices = diffEntities(oldServices, newServices)
+	oldUnits, newUnits := flattenUnits(old.Services), flattenUnits(new.Services)
+	change.AddedUnits, change.RemovedUnits, change.ChangedUnits = diffEntities(oldUnits, newUnits)
+	for name, unit := range newUnits {
+		oldUnit, ok := oldUnits[name]
+		if !ok || oldUnit.(unitStatus).Machine == unit.(unitStatus).Machine {
+			continue
+		}
+		if change.ReassignedUnits == nil {
+			change.ReassignedUnits = make(map[string]string)
+		}
+		change.ReassignedUnits[name] = unit.(unitStatus).Machine
+	}
+	return change
+}
+
+// diffEntities returns the new status of the entities that are only
+// in new, the sorted names of those only in old, and the new status of
+// those in both but with a different status.
+func diffEntities(old, new map[string]interface{}) (added map[string]interface{}, removed []string, changed map[string]interface{}) {
+	for name, status := range new {
+		oldStatus, ok := old[name]
+		if !ok {
+			if added == nil {
+				added = make(map[string]interface{})
+			}
+			added[name] = status
+		} else if !sameStatus(oldStatus, status) {
+			if changed == nil {
+				changed = make(map[string]interface{})
+			}
+			changed[name] = status
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return added, removed, changed
+}
+
+// sameStatus returns whether two statuses would be reported in the
+// same way.
+func sameStatus(s0, s1 interface{}) bool {
+	data0, err0 := json.Marshal(s0)
+	data1, err1 := json.Marshal(s1)
+	return err0 == nil && err1 == nil && bytes.Equal(data0, data1)
+}
+
+// flattenMachines returns the status of every machine and container in
+// machines, keyed by id, without the status of their containers.
+func flattenMachines(machines map[string]machineStatus) map[string]interface{} {
+	flat := make(map[string]interface{})
+	var add func(map[string]machineStatus)
+	add = func(machines map[string]machineStatus) {
+		for id, m := range machines {
+			add(m.Containers)
+			m.Containers = nil
+			flat[id] = m
+		}
+	}
+	add(machines)
+	return flat
+}
+
+// flattenServices returns the status of every service in services,
+// without the status of their units.
+func flattenServices(services map[string]serviceStatus) map[string]interface{} {
+	flat := make(map[string]interface{})
+	for name, s := range services {
+		s.Units = nil
+		flat[name] = s
+	}
+	return flat
+}
+
+// flattenUnits returns the status of every unit and subordinate unit
+// of services, keyed by unit name, without the status of their
+// subordinates.
+func flattenUnits(services map[string]serviceStatus) map[string]interface{} {
+	flat := make(map[string]interface{})
+	var add func(map[string]unitStatus)
+	add = func(units map[string]unitStatus) {
+		for name, u := range units {
+			add(u.Subordinates)
+			u.Subordinates = nil
+			flat[name] = u
+		}
+	}
+	for _, s := range services {
+		add(s.Units)
+	}
+	return flat
+}
+
+// statusFilter restricts the entities reported by status to
+// those related to the patterns given on the command line.
+type statusFilter struct {
+	// machines holds the ids of the top level machines to report;
+	// their containers are reported with them.
+	machines set.Strings
+	services set.Strings
+	// units holds the units to report, keyed by name.
+	units map[string]*state.Unit
+}
+
+// newStatusFilter returns a filter that selects the named machines,
+// services and units, the machines hosting any selected units, and
+// the units running on any selected machines or their containers.
+func newStatusFilter(st *state.State, patterns []string) (*statusFilter, error) {
+	filter := &statusFilter{units: make(map[string]*state.Unit)}
+	for _, pattern := range patterns {
+		var units []*state.Unit
+		switch {
+		case state.IsMachineId(pattern):
+			machines, err := st.MachineAndContainers(pattern)
+			if err != nil {
+				return nil, err
+			}
+			filter.machines.Add(state.TopParentId(pattern))
+			for _, m := range machines {
+				machineUnits, err := m.Units()
+				if err != nil {
+					return nil, err
+				}
+				units = append(units, machineUnits...)
+			}
+		case state.IsUnitName(pattern):
+			u, err := st.Unit(pattern)
+			if err != nil {
+				return nil, err
+			}
+			units = []*state.Unit{u}
+		default:
+			svc, err := st.Service(pattern)
+			if err != nil {
+				return nil, err
+			}
+			filter.services.Add(svc.Name())
+			if units, err = svc.AllUnits(); err != nil {
+				return nil, err
+			}
+		}
+		for _, u := range units {
+			if err := filter.addUnit(st, u); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return filter, nil
+}
+
+// addUnit selects u, its subordinates, and the machine hosting it.
+func (filter *statusFilter) addUnit(st *state.State, u *state.Unit) error {
+	filter.services.Add(u.ServiceName())
+	filter.units[u.Name()] = u
+	// Subordinates are reported with their principal, so
+	// they must be selected too.
+	for _, name := range u.SubordinateNames() {
+		if _, ok := filter.units[name]; ok {
+			continue
+		}
+		sub, err := st.Unit(name)
+		if err != nil {
+			return err
+		}
+		filter.services.Add(sub.ServiceName())
+		filter.units[name] = sub
+	}
+	machineId, err := u.AssignedMachineId()
+	if state.IsNotAssigned(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	filter.machines.Add(state.TopParentId(machineId))
+	return nil
 }
 
 // fetchAllInstances returns a map from instance id to instance.
@@ -95,13 +421,26 @@ func fetchAllInstances(env environs.Environ) (map[instance.Id]instance.Instance,
 
 // fetchAllMachines returns a map from top level machine id to machines, where machines[0] is the host
 // machine and machines[1..n] are any containers (including nested ones).
-func fetchAllMachines(st *state.State) (map[string][]*state.Machine, error) {
+// If filter is not nil, only the machines it selects are fetched.
+func fetchAllMachines(st *state.State, filter *statusFilter) (map[string][]*state.Machine, error) {
 	v := make(map[string][]*state.Machine)
-	machines, err := st.AllMachines()
-	if err != nil {
-		return nil, err
+	var machines []*state.Machine
+	if filter == nil {
+		var err error
+		if machines, err = st.AllMachines(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, id := range filter.machines.SortedValues() {
+			hostMachines, err := st.MachineAndContainers(id)
+			if err != nil {
+				return nil, err
+			}
+			machines = append(machines, hostMachines...)
+		}
 	}
-	// AllMachines gives us machines sorted by id.
+	// Each host machine comes before its containers, which
+	// are sorted by id.
 	for _, m := range machines {
 		parentId, ok := m.ParentId()
 		if !ok {
@@ -121,25 +460,46 @@ func fetchAllMachines(st *state.State) (map[string][]*state.Machine, error) {
 }
 
 // fetchAllServicesAndUnits returns a map from service name to service
-// and a map from service name to unit name to unit.
-func fetchAllServicesAndUnits(st *state.State) (map[string]*state.Service, map[string]map[string]*state.Unit, error) {
+// and a map from service name to unit name to unit. If filter is not nil,
+// only the services it selects are fetched, and the units it holds are
+// returned.
+func fetchAllServicesAndUnits(st *state.State, filter *statusFilter) (map[string]*state.Service, map[string]map[string]*state.Unit, error) {
 	svcMap := make(map[string]*state.Service)
 	unitMap := make(map[string]map[string]*state.Unit)
-	services, err := st.AllServices()
-	if err != nil {
-		return nil, nil, err
+	var services []*state.Service
+	if filter == nil {
+		var err error
+		if services, err = st.AllServices(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		for _, name := range filter.services.SortedValues() {
+			s, err := st.Service(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			services = append(services, s)
+		}
 	}
 	for _, s := range services {
 		svcMap[s.Name()] = s
+		svcUnitMap := make(map[string]*state.Unit)
+		unitMap[s.Name()] = svcUnitMap
+		if filter != nil {
+			continue
+		}
 		units, err := s.AllUnits()
 		if err != nil {
 			return nil, nil, err
 		}
-		svcUnitMap := make(map[string]*state.Unit)
 		for _, u := range units {
 			svcUnitMap[u.Name()] = u
 		}
-		unitMap[s.Name()] = svcUnitMap
+	}
+	if filter != nil {
+		for name, u := range filter.units {
+			unitMap[u.ServiceName()][name] = u
+		}
 	}
 	return svcMap, unitMap, nil
 }
@@ -184,6 +544,9 @@ func (context *statusContext) makeMachineStatus(machine *state.Machine) (status
 		status.AgentStateInfo,
 		status.Err = processAgent(machine)
 	status.Series = machine.Series()
+	// Addresses are only known once the machine agent has reported
+	// them, and are left out until then.
+	status.Addresses = machine.Addresses()
 	instid, err := machine.InstanceId()
 	if err == nil {
 		status.InstanceId = instid
@@ -370,6 +733,7 @@ type machineStatus struct {
 	AgentStateInfo string                   `json:"agent-state-info,omitempty" yaml:"agent-state-info,omitempty"`
 	AgentVersion   string                   `json:"agent-version,omitempty" yaml:"agent-version,omitempty"`
 	DNSName        string                   `json:"dns-name,omitempty" yaml:"dns-name,omitempty"`
+	Addresses      []string                 `json:"addresses,omitempty" yaml:"addresses,omitempty"`
 	InstanceId     instance.Id              `json:"instance-id,omitempty" yaml:"instance-id,omitempty"`
 	InstanceState  string                   `json:"instance-state,omitempty" yaml:"instance-state,omitempty"`
 	Life           string                   `json:"life,omitempty" yaml:"life,omitempty"`
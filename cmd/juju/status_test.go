@@ -18,9 +18,12 @@ import (
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/presence"
+	"launchpad.net/juju-core/state/watcher"
 	coretesting "launchpad.net/juju-core/testing"
 	"launchpad.net/juju-core/version"
 	"net/url"
+	"os"
+	"reflect"
 	"time"
 )
 
@@ -276,6 +279,146 @@ var statusTests = []testCase{
 				"services": M{},
 			},
 		},
+	), test(
+		"machine with addresses reported by its agent",
+		addMachine{machineId: "0", job: state.JobManageEnviron},
+		startAliveMachine{"0"},
+		setMachineStatus{"0", params.StatusStarted, ""},
+		expect{
+			"machine 0 has no addresses yet",
+			M{
+				"machines": M{
+					"0": machine0,
+				},
+				"services": M{},
+			},
+		},
+
+		setMachineAddresses{"0", []string{"10.0.0.1", "dummyenv-0.internal"}},
+		expect{
+			"machine 0 reports its addresses",
+			M{
+				"machines": M{
+					"0": M{
+						"agent-state": "started",
+						"dns-name":    "dummyenv-0.dns",
+						"addresses":   L{"10.0.0.1", "dummyenv-0.internal"},
+						"instance-id": "dummyenv-0",
+						"series":      "series",
+						"hardware":    "arch=amd64 cpu-cores=1 mem=1024M",
+					},
+				},
+				"services": M{},
+			},
+		},
+	), test(
+		"filter by service, unit and machine",
+		addMachine{machineId: "0", job: state.JobManageEnviron},
+		startAliveMachine{"0"},
+		setMachineStatus{"0", params.StatusStarted, ""},
+		addMachine{machineId: "1", job: state.JobHostUnits},
+		startAliveMachine{"1"},
+		setMachineStatus{"1", params.StatusStarted, ""},
+		addMachine{machineId: "2", job: state.JobHostUnits},
+		startAliveMachine{"2"},
+		setMachineStatus{"2", params.StatusStarted, ""},
+		addCharm{"dummy"},
+		addService{"dummy-service", "dummy"},
+		addService{"exposed-service", "dummy"},
+		setServiceExposed{"exposed-service", true},
+		addAliveUnit{"dummy-service", "1"},
+		setUnitStatus{"dummy-service/0", params.StatusStarted, ""},
+		addAliveUnit{"exposed-service", "2"},
+		setUnitStatus{"exposed-service/0", params.StatusStarted, ""},
+
+		expectFiltered{
+			"a service, and the machine hosting its unit",
+			[]string{"dummy-service"},
+			M{
+				"machines": M{
+					"1": machine1,
+				},
+				"services": M{
+					"dummy-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": false,
+						"units": M{
+							"dummy-service/0": M{
+								"machine":     "1",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		expectFiltered{
+			"a unit, its service and its machine",
+			[]string{"exposed-service/0"},
+			M{
+				"machines": M{
+					"2": machine2,
+				},
+				"services": M{
+					"exposed-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": true,
+						"units": M{
+							"exposed-service/0": M{
+								"machine":     "2",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		expectFiltered{
+			"a machine and the units running on it",
+			[]string{"2"},
+			M{
+				"machines": M{
+					"2": machine2,
+				},
+				"services": M{
+					"exposed-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": true,
+						"units": M{
+							"exposed-service/0": M{
+								"machine":     "2",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		expectFiltered{
+			"a machine without units, and a service",
+			[]string{"0", "dummy-service"},
+			M{
+				"machines": M{
+					"0": machine0,
+					"1": machine1,
+				},
+				"services": M{
+					"dummy-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": false,
+						"units": M{
+							"dummy-service/0": M{
+								"machine":     "1",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
 	), test(
 		"test pending and missing machines",
 		addMachine{machineId: "0", job: state.JobManageEnviron},
@@ -830,6 +973,54 @@ var statusTests = []testCase{
 				},
 			},
 		},
+
+		expectFiltered{
+			"a machine and the units running in its containers",
+			[]string{"1"},
+			M{
+				"machines": M{
+					"1": machine1WithContainers,
+				},
+				"services": M{
+					"mysql": M{
+						"charm":   "local:series/mysql-1",
+						"exposed": true,
+						"units": M{
+							"mysql/0": M{
+								"machine":     "1",
+								"agent-state": "started",
+							},
+							"mysql/1": M{
+								"machine":     "1/lxc/0",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		expectFiltered{
+			"a container, its host machine and the units running in it",
+			[]string{"1/lxc/0"},
+			M{
+				"machines": M{
+					"1": machine1WithContainers,
+				},
+				"services": M{
+					"mysql": M{
+						"charm":   "local:series/mysql-1",
+						"exposed": true,
+						"units": M{
+							"mysql/1": M{
+								"machine":     "1/lxc/0",
+								"agent-state": "started",
+							},
+						},
+					},
+				},
+			},
+		},
 	),
 }
 
@@ -1101,6 +1292,18 @@ func (sms setMachineStatus) step(c *C, ctx *context) {
 	c.Assert(err, IsNil)
 }
 
+type setMachineAddresses struct {
+	machineId string
+	addresses []string
+}
+
+func (sma setMachineAddresses) step(c *C, ctx *context) {
+	m, err := ctx.st.Machine(sma.machineId)
+	c.Assert(err, IsNil)
+	err = m.SetAddresses(sma.addresses)
+	c.Assert(err, IsNil)
+}
+
 type relateServices struct {
 	ep1, ep2 string
 }
@@ -1137,17 +1340,33 @@ type expect struct {
 
 func (e expect) step(c *C, ctx *context) {
 	c.Logf("expect: %s", e.what)
+	checkStatus(c, nil, e.output)
+}
+
+type expectFiltered struct {
+	what   string
+	args   []string
+	output M
+}
+
+func (e expectFiltered) step(c *C, ctx *context) {
+	c.Logf("expect filtered by %v: %s", e.args, e.what)
+	checkStatus(c, e.args, e.output)
+}
 
+// checkStatus runs the status command with the given arguments
+// in each output format, and checks that it reports output.
+func checkStatus(c *C, args []string, output M) {
 	// Now execute the command for each format.
 	for _, format := range statusFormats {
 		c.Logf("format %q", format.name)
 		// Run command with the required format.
-		code, stdout, stderr := runStatus(c, "--format", format.name)
+		code, stdout, stderr := runStatus(c, append([]string{"--format", format.name}, args...)...)
 		c.Assert(code, Equals, 0)
 		c.Assert(stderr, HasLen, 0)
 
 		// Prepare the output in the same format.
-		buf, err := format.marshal(e.output)
+		buf, err := format.marshal(output)
 		c.Assert(err, IsNil)
 		expected := make(M)
 		err = format.unmarshal(buf, &expected)
@@ -1161,6 +1380,198 @@ func (e expect) step(c *C, ctx *context) {
 	}
 }
 
+func (s *StatusSuite) TestStatusFilterErrors(c *C) {
+	code, _, stderr := runStatus(c, "bad#name")
+	c.Assert(code, Equals, 2)
+	c.Assert(string(stderr), Equals, "error: invalid status filter \"bad#name\"\n")
+
+	code, _, stderr = runStatus(c, "missing")
+	c.Assert(code, Equals, 1)
+	c.Assert(string(stderr), Equals, "error: service \"missing\" not found\n")
+
+	code, _, stderr = runStatus(c, "42")
+	c.Assert(code, Equals, 1)
+	c.Assert(string(stderr), Equals, "error: machine 42 not found\n")
+}
+
+// renderWriter sends each status written to it on a channel.
+type renderWriter chan []byte
+
+func (w renderWriter) Write(data []byte) (int, error) {
+	if len(data) > 1 {
+		w <- append([]byte(nil), data...)
+	}
+	return len(data), nil
+}
+
+func (w renderWriter) next(c *C) M {
+	select {
+	case data := <-w:
+		var result M
+		err := json.Unmarshal(data, &result)
+		c.Assert(err, IsNil)
+		return result
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for status")
+	}
+	panic("unreachable")
+}
+
+func (s *StatusSuite) TestStatusWatch(c *C) {
+	oldPeriod := watcher.Period
+	watcher.Period = 50 * time.Millisecond
+	defer func() { watcher.Period = oldPeriod }()
+	notified := make(chan chan<- os.Signal, 1)
+	oldNotify := notifyStatusInterrupt
+	notifyStatusInterrupt = func(ch chan<- os.Signal) { notified <- ch }
+	defer func() { notifyStatusInterrupt = oldNotify }()
+
+	out := make(renderWriter, 10)
+	ctx := coretesting.Context(c)
+	ctx.Stdout = out
+	done := make(chan int, 1)
+	go func() {
+		done <- cmd.Main(&StatusCommand{}, ctx, []string{"--watch", "--format", "json"})
+	}()
+	c.Assert(out.next(c), DeepEquals, M{"machines": M{}, "services": M{}})
+
+	_, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	c.Assert(out.next(c), DeepEquals, M{
+		"added-machines": map[string]interface{}{
+			"0": map[string]interface{}{
+				"instance-id": "pending",
+				"series":      "series",
+			},
+		},
+	})
+
+	interrupt := <-notified
+	interrupt <- os.Interrupt
+	select {
+	case code := <-done:
+		c.Assert(code, Equals, 0)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("status --watch did not stop when interrupted")
+	}
+}
+
+var diffStatusTests = []struct {
+	summary  string
+	old, new statusResult
+	change   statusChange
+}{{
+	summary: "no change",
+	old: statusResult{
+		Machines: map[string]machineStatus{"0": {Series: "series"}},
+		Services: map[string]serviceStatus{"mysql": {Charm: "local:series/mysql-1"}},
+	},
+	new: statusResult{
+		Machines: map[string]machineStatus{"0": {Series: "series"}},
+		Services: map[string]serviceStatus{"mysql": {Charm: "local:series/mysql-1"}},
+	},
+}, {
+	summary: "add a service",
+	old: statusResult{
+		Services: map[string]serviceStatus{},
+	},
+	new: statusResult{
+		Services: map[string]serviceStatus{
+			"mysql": {
+				Charm: "local:series/mysql-1",
+				Units: map[string]unitStatus{"mysql/0": {Machine: "0"}},
+			},
+		},
+	},
+	change: statusChange{
+		AddedServices: map[string]interface{}{
+			"mysql": serviceStatus{Charm: "local:series/mysql-1"},
+		},
+		AddedUnits: map[string]interface{}{
+			"mysql/0": unitStatus{Machine: "0"},
+		},
+	},
+}, {
+	summary: "remove a unit",
+	old: statusResult{
+		Services: map[string]serviceStatus{
+			"mysql": {
+				Units: map[string]unitStatus{
+					"mysql/0": {Machine: "0"},
+					"mysql/1": {
+						Machine:      "1",
+						Subordinates: map[string]unitStatus{"logging/0": {}},
+					},
+				},
+			},
+		},
+	},
+	new: statusResult{
+		Services: map[string]serviceStatus{
+			"mysql": {
+				Units: map[string]unitStatus{"mysql/0": {Machine: "0"}},
+			},
+		},
+	},
+	change: statusChange{
+		RemovedUnits: []string{"logging/0", "mysql/1"},
+	},
+}, {
+	summary: "reassign a unit's machine",
+	old: statusResult{
+		Machines: map[string]machineStatus{
+			"0": {Containers: map[string]machineStatus{"0/lxc/0": {}}},
+		},
+		Services: map[string]serviceStatus{
+			"mysql": {Units: map[string]unitStatus{"mysql/0": {Machine: "0"}}},
+		},
+	},
+	new: statusResult{
+		Machines: map[string]machineStatus{
+			"0": {Containers: map[string]machineStatus{"0/lxc/0": {}}},
+		},
+		Services: map[string]serviceStatus{
+			"mysql": {Units: map[string]unitStatus{"mysql/0": {Machine: "0/lxc/0"}}},
+		},
+	},
+	change: statusChange{
+		ChangedUnits: map[string]interface{}{
+			"mysql/0": unitStatus{Machine: "0/lxc/0"},
+		},
+		ReassignedUnits: map[string]string{"mysql/0": "0/lxc/0"},
+	},
+}, {
+	summary: "add a container and change a machine",
+	old: statusResult{
+		Machines: map[string]machineStatus{"0": {AgentState: params.StatusPending}},
+	},
+	new: statusResult{
+		Machines: map[string]machineStatus{
+			"0": {
+				AgentState: params.StatusStarted,
+				Containers: map[string]machineStatus{"0/lxc/0": {}},
+			},
+		},
+	},
+	change: statusChange{
+		AddedMachines: map[string]interface{}{
+			"0/lxc/0": machineStatus{},
+		},
+		ChangedMachines: map[string]interface{}{
+			"0": machineStatus{AgentState: params.StatusStarted},
+		},
+	},
+}}
+
+func (s *StatusSuite) TestDiffStatus(c *C) {
+	for i, t := range diffStatusTests {
+		c.Logf("test %d: %s", i, t.summary)
+		change := diffStatus(t.old, t.new)
+		c.Check(change, DeepEquals, t.change)
+		c.Check(change.isEmpty(), Equals, reflect.DeepEqual(t.change, statusChange{}))
+	}
+}
+
 func (s *StatusSuite) TestStatusAllFormats(c *C) {
 	for i, t := range statusTests {
 		c.Logf("test %d: %s", i, t.summary)
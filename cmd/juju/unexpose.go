@@ -5,6 +5,8 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state/api/params"
@@ -25,6 +27,11 @@ func (c *UnexposeCommand) Info() *cmd.Info {
 	}
 }
 
+func (c *UnexposeCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
+}
+
 func (c *UnexposeCommand) Init(args []string) error {
 	if len(args) == 0 {
 		return errors.New("no service name specified")
@@ -35,12 +42,28 @@ func (c *UnexposeCommand) Init(args []string) error {
 
 // Run changes the juju-managed firewall to hide any
 // ports that were also explicitly marked by units as closed.
-func (c *UnexposeCommand) Run(_ *cmd.Context) error {
+func (c *UnexposeCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *UnexposeCommand) run(ctx *cmd.Context) error {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
+	svc, err := conn.State.Service(c.ServiceName)
+	if err != nil {
+		return err
+	}
+	if !svc.IsExposed() {
+		fmt.Fprintf(ctx.Stdout, "service %q is not exposed\n", c.ServiceName)
+		return nil
+	}
 	params := params.ServiceUnexpose{ServiceName: c.ServiceName}
-	return statecmd.ServiceUnexpose(conn.State, params)
+	if err := statecmd.ServiceUnexpose(conn.State, params); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.Stdout, "service %q unexposed\n", c.ServiceName)
+	return nil
 }
@@ -46,3 +46,20 @@ func (s *UnexposeSuite) TestUnexpose(c *C) {
 	err = runUnexpose(c, "nonexistent-service")
 	c.Assert(err, ErrorMatches, `service "nonexistent-service" not found`)
 }
+
+func (s *UnexposeSuite) TestUnexposeIdempotent(c *C) {
+	_, err := s.State.AddService("wordpress", s.AddTestingCharm(c, "wordpress"))
+	c.Assert(err, IsNil)
+	err = runExpose(c, "wordpress")
+	c.Assert(err, IsNil)
+
+	for _, expect := range []string{
+		"service \"wordpress\" unexposed\n",
+		"service \"wordpress\" is not exposed\n",
+	} {
+		ctx, err := testing.RunCommand(c, &UnexposeCommand{}, []string{"wordpress"})
+		c.Assert(err, IsNil)
+		c.Assert(testing.Stdout(ctx), Equals, expect)
+		s.assertExposed(c, "wordpress", false)
+	}
+}
@@ -60,6 +60,7 @@ func (c *UpgradeJujuCommand) Info() *cmd.Info {
 
 func (c *UpgradeJujuCommand) SetFlags(f *gnuflag.FlagSet) {
 	c.EnvCommandBase.SetFlags(f)
+	c.AddTimeoutFlag(f)
 	f.StringVar(&c.vers, "version", "", "upgrade to specific version")
 	f.BoolVar(&c.Development, "dev", false, "allow development versions to be chosen")
 	f.BoolVar(&c.UploadTools, "upload-tools", false, "upload local version of tools")
@@ -95,7 +96,11 @@ func (c *UpgradeJujuCommand) Init(args []string) error {
 var errUpToDate = stderrors.New("no upgrades available")
 
 // Run changes the version proposed for the juju tools.
-func (c *UpgradeJujuCommand) Run(_ *cmd.Context) (err error) {
+func (c *UpgradeJujuCommand) Run(ctx *cmd.Context) error {
+	return c.RunWithTimeout(func() error { return c.run(ctx) })
+}
+
+func (c *UpgradeJujuCommand) run(_ *cmd.Context) (err error) {
 	conn, err := juju.NewConnFromName(c.EnvName)
 	if err != nil {
 		return err
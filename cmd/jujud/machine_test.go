@@ -246,7 +246,7 @@ func (s *MachineSuite) TestManageEnviron(c *C) {
 	c.Assert(err, IsNil)
 	err = svc.SetExposed()
 	c.Assert(err, IsNil)
-	units, err := s.Conn.AddUnits(svc, 1, "")
+	units, err := s.Conn.AddUnits(svc, 1, state.AssignNew, "")
 	c.Assert(err, IsNil)
 	c.Check(opRecvTimeout(c, s.State, op, dummy.OpStartInstance{}), NotNil)
 
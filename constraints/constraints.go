@@ -5,8 +5,6 @@ package constraints
 
 import (
 	"fmt"
-	"math"
-	"strconv"
 	"strings"
 
 	"launchpad.net/juju-core/instance"
@@ -64,6 +62,28 @@ func (v Value) String() string {
 	return strings.Join(strs, " ")
 }
 
+// Names returns the names of the constraints specified in v, in the
+// order in which String expresses them.
+func (v Value) Names() []string {
+	var names []string
+	if v.Arch != nil {
+		names = append(names, "arch")
+	}
+	if v.Container != nil {
+		names = append(names, "container")
+	}
+	if v.CpuCores != nil {
+		names = append(names, "cpu-cores")
+	}
+	if v.CpuPower != nil {
+		names = append(names, "cpu-power")
+	}
+	if v.Mem != nil {
+		names = append(names, "mem")
+	}
+	return names
+}
+
 // WithFallbacks returns a copy of v with nil values taken from v0.
 func (v Value) WithFallbacks(v0 Value) Value {
 	v1 := v0
@@ -184,11 +204,11 @@ func (v *Value) SetYAML(tag string, value interface{}) bool {
 			ctype := instance.ContainerType(vstr)
 			v.Container = &ctype
 		case "cpu-cores":
-			v.CpuCores, err = parseUint64(vstr)
+			v.CpuCores, err = instance.ParseUint64(vstr)
 		case "cpu-power":
-			v.CpuPower, err = parseUint64(vstr)
+			v.CpuPower, err = instance.ParseUint64(vstr)
 		case "mem":
-			v.Mem, err = parseUint64(vstr)
+			v.Mem, err = instance.ParseUint64(vstr)
 		default:
 			return false
 		}
@@ -216,25 +236,19 @@ func (v *Value) setContainer(str string) error {
 	return nil
 }
 
-func (v *Value) setArch(str string) error {
+func (v *Value) setArch(str string) (err error) {
 	if v.Arch != nil {
 		return fmt.Errorf("already set")
 	}
-	switch str {
-	case "":
-	case "amd64", "i386", "arm":
-	default:
-		return fmt.Errorf("%q not recognized", str)
-	}
-	v.Arch = &str
-	return nil
+	v.Arch, err = instance.ParseArch(str)
+	return
 }
 
 func (v *Value) setCpuCores(str string) (err error) {
 	if v.CpuCores != nil {
 		return fmt.Errorf("already set")
 	}
-	v.CpuCores, err = parseUint64(str)
+	v.CpuCores, err = instance.ParseUint64(str)
 	return
 }
 
@@ -242,47 +256,14 @@ func (v *Value) setCpuPower(str string) (err error) {
 	if v.CpuPower != nil {
 		return fmt.Errorf("already set")
 	}
-	v.CpuPower, err = parseUint64(str)
+	v.CpuPower, err = instance.ParseUint64(str)
 	return
 }
 
-func (v *Value) setMem(str string) error {
+func (v *Value) setMem(str string) (err error) {
 	if v.Mem != nil {
 		return fmt.Errorf("already set")
 	}
-	var value uint64
-	if str != "" {
-		mult := 1.0
-		if m, ok := mbSuffixes[str[len(str)-1:]]; ok {
-			str = str[:len(str)-1]
-			mult = m
-		}
-		val, err := strconv.ParseFloat(str, 64)
-		if err != nil || val < 0 {
-			return fmt.Errorf("must be a non-negative float with optional M/G/T/P suffix")
-		}
-		val *= mult
-		value = uint64(math.Ceil(val))
-	}
-	v.Mem = &value
-	return nil
-}
-
-func parseUint64(str string) (*uint64, error) {
-	var value uint64
-	if str != "" {
-		if val, err := strconv.ParseUint(str, 10, 64); err != nil {
-			return nil, fmt.Errorf("must be a non-negative integer")
-		} else {
-			value = uint64(val)
-		}
-	}
-	return &value, nil
-}
-
-var mbSuffixes = map[string]float64{
-	"M": 1,
-	"G": 1024,
-	"T": 1024 * 1024,
-	"P": 1024 * 1024 * 1024,
+	v.Mem, err = instance.ParseSize(str)
+	return
 }
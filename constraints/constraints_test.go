@@ -60,7 +60,7 @@ var parseConstraintsTests = []struct {
 	}, {
 		summary: "set nonsense container",
 		args:    []string{"container=foo"},
-		err:     `bad "container" constraint: invalid container type "foo"`,
+		err:     `bad "container" constraint: invalid container type "foo", expected one of "none", "lxc"`,
 	}, {
 		summary: "double set container together",
 		args:    []string{"container=lxc container=lxc"},
@@ -401,3 +401,18 @@ func (s *ConstraintsSuite) TestWithFallbacks(c *C) {
 		c.Assert(initial.WithFallbacks(fallbacks), DeepEquals, final)
 	}
 }
+
+func (s *ConstraintsSuite) TestNames(c *C) {
+	for i, t := range []struct {
+		cons  string
+		names []string
+	}{
+		{"", nil},
+		{"mem=4G", []string{"mem"}},
+		{"cpu-power=", []string{"cpu-power"}},
+		{"mem=4G arch=amd64 container=lxc cpu-cores=2", []string{"arch", "container", "cpu-cores", "mem"}},
+	} {
+		c.Logf("test %d: %q", i, t.cons)
+		c.Check(constraints.MustParse(t.cons).Names(), DeepEquals, t.names)
+	}
+}
@@ -33,6 +33,13 @@ func FinishMachineConfig(mcfg *cloudinit.MachineConfig, cfg *config.Config, cons
 		return fmt.Errorf("environment configuration has no authorized-keys")
 	}
 	mcfg.AuthorizedKeys = authKeys
+
+	// Every machine uses the environment's proxies and mirrors.
+	mcfg.HTTPProxy = cfg.HTTPProxy()
+	mcfg.HTTPSProxy = cfg.HTTPSProxy()
+	mcfg.NoProxy = cfg.NoProxy()
+	mcfg.AptMirror = cfg.AptMirror()
+	mcfg.AptHTTPProxy = cfg.AptHTTPProxy()
 	if !mcfg.StateServer {
 		return nil
 	}
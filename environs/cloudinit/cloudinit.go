@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"launchpad.net/goyaml"
 
@@ -89,6 +90,18 @@ type MachineConfig struct {
 
 	// Constraints holds the initial environment constraints.
 	Constraints constraints.Value
+
+	// HTTPProxy, HTTPSProxy and NoProxy hold the proxy settings that
+	// the new machine should use. Empty values mean no proxy.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// AptMirror and AptHTTPProxy hold the archive mirror and the
+	// proxy that apt should use on the new machine. Empty values
+	// mean the defaults.
+	AptMirror    string
+	AptHTTPProxy string
 }
 
 func addScripts(c *cloudinit.Config, scripts ...string) {
@@ -127,6 +140,7 @@ func Configure(cfg *MachineConfig, c *cloudinit.Config) (*cloudinit.Config, erro
 		"set -xe", // ensure we run all the scripts or abort.
 		fmt.Sprintf("mkdir -p %s", cfg.DataDir),
 		"mkdir -p /var/log/juju")
+	cfg.addProxySettings(c)
 
 	// Make a directory for the tools to live in, then fetch the
 	// tools and unarchive them into it.
@@ -194,10 +208,42 @@ func Configure(cfg *MachineConfig, c *cloudinit.Config) (*cloudinit.Config, erro
 	// general options
 	c.SetAptUpgrade(true)
 	c.SetAptUpdate(true)
+	c.SetAptMirror(cfg.AptMirror)
+	c.SetAptProxy(cfg.AptHTTPProxy)
 	c.SetOutput(cloudinit.OutAll, "| tee -a /var/log/cloud-init-output.log", "")
 	return c, nil
 }
 
+// proxyVars returns the shell assignments of the proxy
+// environment variables for the machine's proxy settings.
+func (cfg *MachineConfig) proxyVars() []string {
+	var vars []string
+	for _, v := range []struct{ name, value string }{
+		{"http_proxy", cfg.HTTPProxy},
+		{"https_proxy", cfg.HTTPSProxy},
+		{"no_proxy", cfg.NoProxy},
+	} {
+		if v.value != "" {
+			vars = append(vars, v.name+"="+shquote(v.value))
+		}
+	}
+	return vars
+}
+
+// addProxySettings makes the machine's proxy settings apply both to
+// the rest of the cloud-init scripts and to later login sessions.
+func (cfg *MachineConfig) addProxySettings(c *cloudinit.Config) {
+	vars := cfg.proxyVars()
+	if len(vars) == 0 {
+		return
+	}
+	for i, v := range vars {
+		vars[i] = "export " + v
+	}
+	addScripts(c, vars...)
+	addFile(c, "/etc/profile.d/juju-proxy.sh", strings.Join(vars, "\n")+"\n", 0644)
+}
+
 func (cfg *MachineConfig) addLogging(c *cloudinit.Config) error {
 	var configRenderer syslog.SyslogConfigRenderer
 	if cfg.StateServer {
@@ -359,6 +405,19 @@ func (e requiresError) Error() string {
 
 func verifyConfig(cfg *MachineConfig) (err error) {
 	defer utils.ErrorContextf(&err, "invalid machine configuration")
+	return cfg.check()
+}
+
+// Verify checks that all the fields needed to start the machine's
+// agent are present and consistent, so that a partially populated
+// configuration can be rejected with an error naming the machine
+// and the missing field before any user data is generated from it.
+func (cfg *MachineConfig) Verify() (err error) {
+	defer utils.ErrorContextf(&err, "invalid configuration for machine %s", cfg.MachineId)
+	return cfg.check()
+}
+
+func (cfg *MachineConfig) check() error {
 	if !state.IsMachineId(cfg.MachineId) {
 		return fmt.Errorf("invalid machine id")
 	}
@@ -345,6 +345,60 @@ func (*cloudinitSuite) TestCloudInitConfigureUsesGivenConfig(c *C) {
 	c.Check(runCmd[0], Equals, script)
 }
 
+func (*cloudinitSuite) TestCloudInitProxies(c *C) {
+	cfg := cloudinitTests[2].cfg
+	c.Assert(cfg.MachineId, Equals, "99")
+	cfg.HTTPProxy = "http://proxy.example.com:3128"
+	cfg.NoProxy = "localhost,10.0.0.1"
+	ci, err := cloudinit.New(&cfg)
+	c.Assert(err, IsNil)
+	data, err := ci.Render()
+	c.Assert(err, IsNil)
+	x := make(map[interface{}]interface{})
+	err = goyaml.Unmarshal(data, &x)
+	c.Assert(err, IsNil)
+
+	// The proxies are exported before the tools are downloaded,
+	// and saved for later login sessions.
+	scripts := getScripts(x)
+	c.Assert(len(scripts) > 8, Equals, true)
+	c.Assert(scripts[1:8], DeepEquals, []string{
+		"mkdir -p /var/lib/juju",
+		"mkdir -p /var/log/juju",
+		"export http_proxy='http://proxy.example.com:3128'",
+		"export no_proxy='localhost,10.0.0.1'",
+		`echo 'export http_proxy='"'"'http://proxy.example.com:3128'"'"'` + "\n" +
+			`export no_proxy='"'"'localhost,10.0.0.1'"'"'` + "\n" + `' > '/etc/profile.d/juju-proxy.sh'`,
+		"chmod 644 '/etc/profile.d/juju-proxy.sh'",
+		"bin='/var/lib/juju/tools/1.2.3-linux-amd64'",
+	})
+}
+
+func (*cloudinitSuite) TestCloudInitAptMirror(c *C) {
+	render := func(cfg cloudinit.MachineConfig) map[interface{}]interface{} {
+		ci, err := cloudinit.New(&cfg)
+		c.Assert(err, IsNil)
+		data, err := ci.Render()
+		c.Assert(err, IsNil)
+		x := make(map[interface{}]interface{})
+		err = goyaml.Unmarshal(data, &x)
+		c.Assert(err, IsNil)
+		return x
+	}
+
+	// By default, apt uses the default mirror and no proxy.
+	cfg := cloudinitTests[2].cfg
+	x := render(cfg)
+	c.Assert(x["apt_mirror"], IsNil)
+	c.Assert(x["apt_proxy"], IsNil)
+
+	cfg.AptMirror = "http://mirror.example.com/ubuntu"
+	cfg.AptHTTPProxy = "http://proxy.example.com:3142"
+	x = render(cfg)
+	c.Assert(x["apt_mirror"], Equals, "http://mirror.example.com/ubuntu")
+	c.Assert(x["apt_proxy"], Equals, "http://proxy.example.com:3142")
+}
+
 func getScripts(x map[interface{}]interface{}) []string {
 	var scripts []string
 	for _, s := range x["runcmd"].([]interface{}) {
@@ -568,6 +622,7 @@ func (*cloudinitSuite) TestCloudInitVerify(c *C) {
 	// check that the base configuration does not give an error
 	_, err := cloudinit.New(cfg)
 	c.Assert(err, IsNil)
+	c.Assert(cfg.Verify(), IsNil)
 
 	for i, test := range verifyTests {
 		c.Logf("test %d. %s", i, test.err)
@@ -576,6 +631,8 @@ func (*cloudinitSuite) TestCloudInitVerify(c *C) {
 		t, err := cloudinit.New(&cfg1)
 		c.Assert(err, ErrorMatches, "invalid machine configuration: "+test.err)
 		c.Assert(t, IsNil)
+		err = cfg1.Verify()
+		c.Assert(err, ErrorMatches, "invalid configuration for machine "+cfg1.MachineId+": "+test.err)
 	}
 }
 
@@ -45,6 +45,38 @@ func (s *CloudInitSuite) TestFinishInstanceConfig(c *C) {
 	})
 }
 
+func (s *CloudInitSuite) TestFinishMachineConfigProxiesAndMirror(c *C) {
+	cfg, err := config.New(map[string]interface{}{
+		"name":            "barbara",
+		"type":            "dummy",
+		"authorized-keys": "we-are-the-keys",
+		"ca-cert":         testing.CACert,
+		"ca-private-key":  "",
+		"http-proxy":      "http://proxy.example.com:3128",
+		"https-proxy":     "https://proxy.example.com:3129",
+		"no-proxy":        "localhost",
+		"apt-mirror":      "http://mirror.example.com/ubuntu",
+		"apt-http-proxy":  "http://proxy.example.com:3142",
+	})
+	c.Assert(err, IsNil)
+	mcfg := &cloudinit.MachineConfig{
+		StateInfo: &state.Info{Tag: "not touched"},
+		APIInfo:   &api.Info{Tag: "not touched"},
+	}
+	err = environs.FinishMachineConfig(mcfg, cfg, constraints.Value{})
+	c.Assert(err, IsNil)
+	c.Assert(mcfg, DeepEquals, &cloudinit.MachineConfig{
+		AuthorizedKeys: "we-are-the-keys",
+		StateInfo:      &state.Info{Tag: "not touched"},
+		APIInfo:        &api.Info{Tag: "not touched"},
+		HTTPProxy:      "http://proxy.example.com:3128",
+		HTTPSProxy:     "https://proxy.example.com:3129",
+		NoProxy:        "localhost",
+		AptMirror:      "http://mirror.example.com/ubuntu",
+		AptHTTPProxy:   "http://proxy.example.com:3142",
+	})
+}
+
 func (s *CloudInitSuite) TestFinishBootstrapConfig(c *C) {
 	cfg, err := config.New(map[string]interface{}{
 		"name":            "barbara",
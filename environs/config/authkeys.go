@@ -6,6 +6,8 @@ package config
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"launchpad.net/juju-core/cert"
@@ -63,6 +65,78 @@ func readAuthorizedKeys(path string) (string, error) {
 	return string(keyData), nil
 }
 
+// verifyAuthorizedKeys checks that each line of keys, which is in
+// authorized_keys format, holds an SSH public key. Blank lines and
+// comments are ignored. Key options are allowed before the key type,
+// but are not checked.
+func verifyAuthorizedKeys(keys string) error {
+	for i, line := range strings.Split(keys, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := verifyAuthorizedKey(line); err != nil {
+			return fmt.Errorf("line %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// verifyAuthorizedKey checks that line holds an SSH public key: a key
+// type, optionally preceded by options, followed by the base64-encoded
+// key whose embedded type matches. Any key type is accepted, so that
+// newer types such as certificates and security keys need no change
+// here.
+func verifyAuthorizedKey(line string) error {
+	fields := strings.Fields(line)
+	var firstErr error
+	for i, field := range fields {
+		var err error
+		if i+1 == len(fields) {
+			err = fmt.Errorf("no key data after key type %q", field)
+		} else if err = verifyKeyData(field, fields[i+1]); err == nil {
+			return nil
+		}
+		// Only report errors for fields that look like key types;
+		// others are most likely options or comments.
+		if firstErr == nil && looksLikeSSHKeyType(field) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return fmt.Errorf("no SSH key type found")
+}
+
+// verifyKeyData checks that data is the base64 encoding of an SSH
+// public key of the given type.
+func verifyKeyData(keyType, data string) error {
+	key, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("cannot decode %q key: %v", keyType, err)
+	}
+	if len(key) < 4 {
+		return fmt.Errorf("%q key is too short", keyType)
+	}
+	n := binary.BigEndian.Uint32(key)
+	if uint32(len(key)-4) < n || string(key[4:4+n]) != keyType {
+		return fmt.Errorf("%q key does not match its key type", keyType)
+	}
+	return nil
+}
+
+// looksLikeSSHKeyType returns whether s is named like a type of SSH
+// public key.
+func looksLikeSSHKeyType(s string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-", "sk-"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(s, "@")
+}
+
 // verifyKeyPair verifies that the certificate and key parse correctly.
 // The key is optional - if it is provided, we also check that the key
 // matches the certificate.
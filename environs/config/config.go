@@ -6,8 +6,11 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -44,6 +47,10 @@ const (
 
 	// DefaultApiPort is the default port the API server is listening on.
 	DefaultApiPort int = 17070
+
+	// DefaultStartInstanceAttempts is the default number of times
+	// the provisioner tries to start an instance for a machine.
+	DefaultStartInstanceAttempts int = 5
 )
 
 // Config holds an immutable environment configuration.
@@ -167,6 +174,31 @@ func Validate(cfg, old *Config) error {
 		return fmt.Errorf("invalid firewall mode in environment configuration: %q", firewallMode)
 	}
 
+	// Check that any proxies and mirrors are URLs. They are written
+	// to world-readable files on every machine, so they must not
+	// hold credentials.
+	for _, attr := range []string{"http-proxy", "https-proxy", "apt-mirror", "apt-http-proxy"} {
+		if proxy := cfg.asString(attr); proxy != "" {
+			u, err := url.Parse(proxy)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid %s in environment configuration: %q", attr, proxy)
+			}
+			if u.User != nil {
+				return fmt.Errorf("invalid %s in environment configuration: credentials are not supported", attr)
+			}
+		}
+	}
+
+	if n := cfg.asInt("start-instance-attempts"); n < 0 {
+		return fmt.Errorf("invalid start-instance-attempts in environment configuration: %d", n)
+	}
+
+	if spec := cfg.asString("logging-config"); spec != "" {
+		if _, err := loggo.ParseConfigurationString(spec); err != nil {
+			return fmt.Errorf("invalid logging-config in environment configuration: %v", err)
+		}
+	}
+
 	// Check the immutable config values.  These can't change
 	if old != nil {
 		for _, attr := range []string{"type", "name", "firewall-mode"} {
@@ -191,6 +223,13 @@ func Validate(cfg, old *Config) error {
 				return fmt.Errorf("cannot clear agent-version")
 			}
 		}
+		// Stored configurations may hold keys that predate this
+		// check, so only keys that are being changed are verified.
+		if keys := cfg.AuthorizedKeys(); keys != old.AuthorizedKeys() {
+			if err := verifyAuthorizedKeys(keys); err != nil {
+				return fmt.Errorf("bad authorized-keys in environment configuration: %v", err)
+			}
+		}
 	}
 
 	return nil
@@ -341,6 +380,70 @@ func (c *Config) SSLHostnameVerification() bool {
 	return c.m["ssl-hostname-verification"].(bool)
 }
 
+// HTTPProxy returns the proxy to be used for HTTP requests made by
+// the environment's machines, or the empty string for no proxy.
+func (c *Config) HTTPProxy() string {
+	return c.asString("http-proxy")
+}
+
+// HTTPSProxy returns the proxy to be used for HTTPS requests made by
+// the environment's machines, or the empty string for no proxy.
+func (c *Config) HTTPSProxy() string {
+	return c.asString("https-proxy")
+}
+
+// NoProxy returns the comma-separated list of hosts that the
+// environment's machines should reach without using a proxy.
+func (c *Config) NoProxy() string {
+	return c.asString("no-proxy")
+}
+
+// AptMirror returns the URL of the Ubuntu archive mirror that the
+// environment's machines should install packages from, or the empty
+// string for the default mirror.
+func (c *Config) AptMirror() string {
+	return c.asString("apt-mirror")
+}
+
+// AptHTTPProxy returns the proxy that apt should use on the
+// environment's machines, or the empty string for no proxy.
+func (c *Config) AptHTTPProxy() string {
+	return c.asString("apt-http-proxy")
+}
+
+// ProvisionerEnabled returns whether the provisioner should start
+// and stop instances for the environment's machines. It is true
+// unless provisioning has been explicitly paused.
+func (c *Config) ProvisionerEnabled() bool {
+	enabled, ok := c.m["provisioner-enabled"].(bool)
+	return !ok || enabled
+}
+
+// SafeMode returns whether the provisioner should leave running
+// instances alone when it cannot find machines for them, rather
+// than stopping them.
+func (c *Config) SafeMode() bool {
+	safeMode, _ := c.m["safe-mode"].(bool)
+	return safeMode
+}
+
+// LoggingConfig returns the loggo specification of the log levels
+// that agents in the environment should use, such as
+// "juju=INFO;juju.environs=DEBUG", or the empty string if none
+// has been set.
+func (c *Config) LoggingConfig() string {
+	return c.asString("logging-config")
+}
+
+// StartInstanceAttempts returns the number of times the provisioner
+// should try to start an instance for a machine before giving up.
+func (c *Config) StartInstanceAttempts() int {
+	if n := c.asInt("start-instance-attempts"); n != 0 {
+		return n
+	}
+	return DefaultStartInstanceAttempts
+}
+
 // UnknownAttrs returns a copy of the raw configuration attributes
 // that are supposedly specific to the environment type. They could
 // also be wrong attributes, though. Only the specific environment
@@ -362,6 +465,30 @@ func (c *Config) AllAttrs() map[string]interface{} {
 	return m
 }
 
+// Attr holds a single configuration attribute.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// SortedAttrs returns all the configuration attributes, including
+// unknown ones, sorted by key. Unlike the map returned by AllAttrs,
+// the result has a stable order, so identical configurations
+// serialize identically.
+func (c *Config) SortedAttrs() []Attr {
+	m := c.AllAttrs()
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]Attr, len(keys))
+	for i, k := range keys {
+		attrs[i] = Attr{k, m[k]}
+	}
+	return attrs
+}
+
 // Apply returns a new configuration that has the attributes of c plus attrs.
 func (c *Config) Apply(attrs map[string]interface{}) (*Config, error) {
 	m := c.AllAttrs()
@@ -371,6 +498,52 @@ func (c *Config) Apply(attrs map[string]interface{}) (*Config, error) {
 	return New(m)
 }
 
+// Remove returns a new configuration that has the attributes of c
+// minus those named in keys. A known attribute that is removed reverts
+// to its default; the result is invalid if one that has no default is
+// removed.
+func (c *Config) Remove(keys ...string) (*Config, error) {
+	m := c.AllAttrs()
+	for _, k := range keys {
+		delete(m, k)
+	}
+	return New(m)
+}
+
+// Change describes an attribute whose value differs between
+// two configurations. Old or New is nil if the attribute is
+// absent from the respective configuration.
+type Change struct {
+	Name string
+	Old  interface{}
+	New  interface{}
+}
+
+// Diff returns the changes that would turn c into newer,
+// sorted by attribute name.
+func (c *Config) Diff(newer *Config) []Change {
+	oldAttrs, newAttrs := c.AllAttrs(), newer.AllAttrs()
+	var changes []Change
+	for name, v := range oldAttrs {
+		if nv, ok := newAttrs[name]; !ok || !reflect.DeepEqual(v, nv) {
+			changes = append(changes, Change{name, v, newAttrs[name]})
+		}
+	}
+	for name, nv := range newAttrs {
+		if _, ok := oldAttrs[name]; !ok {
+			changes = append(changes, Change{name, nil, nv})
+		}
+	}
+	sort.Sort(changesByName(changes))
+	return changes
+}
+
+type changesByName []Change
+
+func (cs changesByName) Len() int           { return len(cs) }
+func (cs changesByName) Less(i, j int) bool { return cs[i].Name < cs[j].Name }
+func (cs changesByName) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
 var fields = schema.Fields{
 	"type":                      schema.String(),
 	"name":                      schema.String(),
@@ -388,6 +561,15 @@ var fields = schema.Fields{
 	"ssl-hostname-verification": schema.Bool(),
 	"state-port":                schema.ForceInt(),
 	"api-port":                  schema.ForceInt(),
+	"http-proxy":                schema.String(),
+	"https-proxy":               schema.String(),
+	"no-proxy":                  schema.String(),
+	"apt-mirror":                schema.String(),
+	"apt-http-proxy":            schema.String(),
+	"provisioner-enabled":       schema.Bool(),
+	"safe-mode":                 schema.Bool(),
+	"logging-config":            schema.String(),
+	"start-instance-attempts":   schema.ForceInt(),
 }
 
 var defaults = schema.Defaults{
@@ -405,6 +587,15 @@ var defaults = schema.Defaults{
 	"ssl-hostname-verification": true,
 	"state-port":                schema.Omit,
 	"api-port":                  schema.Omit,
+	"http-proxy":                schema.Omit,
+	"https-proxy":               schema.Omit,
+	"no-proxy":                  schema.Omit,
+	"apt-mirror":                schema.Omit,
+	"apt-http-proxy":            schema.Omit,
+	"provisioner-enabled":       schema.Omit,
+	"safe-mode":                 schema.Omit,
+	"logging-config":            schema.Omit,
+	"start-instance-attempts":   schema.Omit,
 }
 
 var checker = schema.FieldMap(fields, defaults)
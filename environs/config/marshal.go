@@ -0,0 +1,73 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"launchpad.net/goyaml"
+)
+
+// Redacted replaces the values of secret attributes in
+// configurations marshalled by MarshalRedacted.
+const Redacted = "<redacted>"
+
+// secretAttrs holds the secret attributes known to all environments.
+var secretAttrs = []string{"admin-secret", "ca-private-key"}
+
+// Marshal returns the configuration's attributes in YAML form.
+// The result can be turned back into a configuration with Unmarshal.
+func (c *Config) Marshal() ([]byte, error) {
+	return goyaml.Marshal(c.AllAttrs())
+}
+
+// MarshalRedacted is like Marshal, except that the values of the named
+// attributes, and of those secret to every environment, are replaced
+// by Redacted. Typically the names will be the keys of the attributes
+// returned by the environment provider's SecretAttrs method. The result
+// can only be turned back into a configuration by UnmarshalWithSecrets,
+// given the original values of the redacted attributes.
+func (c *Config) MarshalRedacted(secrets ...string) ([]byte, error) {
+	attrs := c.AllAttrs()
+	for _, name := range append(secrets, secretAttrs...) {
+		if v, ok := attrs[name]; ok && v != "" {
+			attrs[name] = Redacted
+		}
+	}
+	return goyaml.Marshal(attrs)
+}
+
+// Unmarshal returns a new configuration from data produced by Marshal.
+// It returns an error if data was produced by MarshalRedacted.
+func Unmarshal(data []byte) (*Config, error) {
+	return UnmarshalWithSecrets(data, nil)
+}
+
+// UnmarshalWithSecrets returns a new configuration from data produced by
+// Marshal or MarshalRedacted. The values of any redacted attributes are
+// taken from secrets; it is an error if any of them is missing.
+func UnmarshalWithSecrets(data []byte, secrets map[string]interface{}) (*Config, error) {
+	var attrs map[string]interface{}
+	if err := goyaml.Unmarshal(data, &attrs); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal configuration: %v", err)
+	}
+	var missing []string
+	for name, v := range attrs {
+		if v != Redacted {
+			continue
+		}
+		secret, ok := secrets[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		attrs[name] = secret
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("cannot unmarshal configuration: redacted attributes need their original values: %v", missing)
+	}
+	return New(attrs)
+}
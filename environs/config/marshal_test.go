@@ -0,0 +1,97 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package config_test
+
+import (
+	"strings"
+
+	gc "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/environs/config"
+	"launchpad.net/juju-core/testing"
+)
+
+type MarshalSuite struct {
+	testing.LoggingSuite
+}
+
+var _ = gc.Suite(&MarshalSuite{})
+
+func (*MarshalSuite) newConfig(c *gc.C) *config.Config {
+	cfg, err := config.New(attrs{
+		"type":            "my-type",
+		"name":            "my-name",
+		"authorized-keys": "my-keys",
+		"admin-secret":    "my-admin-secret",
+		"ca-cert":         testing.CACert,
+		"ca-private-key":  testing.CAKey,
+		"agent-version":   "1.2.3",
+		"state-port":      1234,
+		"development":     true,
+		"secret-key":      "my-secret-key",
+		"region":          "my-region",
+	})
+	c.Assert(err, gc.IsNil)
+	return cfg
+}
+
+func (s *MarshalSuite) TestRoundTrip(c *gc.C) {
+	cfg := s.newConfig(c)
+	data, err := cfg.Marshal()
+	c.Assert(err, gc.IsNil)
+	cfg1, err := config.Unmarshal(data)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg1.AllAttrs(), gc.DeepEquals, cfg.AllAttrs())
+}
+
+func (s *MarshalSuite) TestRedactedRoundTrip(c *gc.C) {
+	cfg := s.newConfig(c)
+	data, err := cfg.MarshalRedacted("secret-key")
+	c.Assert(err, gc.IsNil)
+	for _, secret := range []string{"my-admin-secret", "my-secret-key", testing.CAKey} {
+		c.Assert(strings.Contains(string(data), secret), gc.Equals, false)
+	}
+
+	_, err = config.Unmarshal(data)
+	c.Assert(err, gc.ErrorMatches, `cannot unmarshal configuration: redacted attributes need their original values: \[admin-secret ca-private-key secret-key\]`)
+
+	_, err = config.UnmarshalWithSecrets(data, map[string]interface{}{
+		"admin-secret": "my-admin-secret",
+		"secret-key":   "my-secret-key",
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot unmarshal configuration: redacted attributes need their original values: \[ca-private-key\]`)
+
+	cfg1, err := config.UnmarshalWithSecrets(data, map[string]interface{}{
+		"admin-secret":   "my-admin-secret",
+		"ca-private-key": testing.CAKey,
+		"secret-key":     "my-secret-key",
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg1.AllAttrs(), gc.DeepEquals, cfg.AllAttrs())
+}
+
+func (s *MarshalSuite) TestRedactedLeavesEmptySecrets(c *gc.C) {
+	cfg, err := config.New(attrs{
+		"type":            "my-type",
+		"name":            "my-name",
+		"authorized-keys": "my-keys",
+		"ca-cert":         "",
+		"ca-private-key":  "",
+		"secret-key":      "",
+	})
+	c.Assert(err, gc.IsNil)
+	data, err := cfg.MarshalRedacted("secret-key")
+	c.Assert(err, gc.IsNil)
+	cfg1, err := config.Unmarshal(data)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg1.AllAttrs(), gc.DeepEquals, cfg.AllAttrs())
+}
+
+func (*MarshalSuite) TestUnmarshalInvalid(c *gc.C) {
+	_, err := config.Unmarshal([]byte("type: [unterminated"))
+	c.Assert(err, gc.ErrorMatches, "cannot unmarshal configuration: .*")
+
+	_, err = config.Unmarshal([]byte("name: my-name\n"))
+	c.Assert(err, gc.ErrorMatches, "type: expected string, got nothing")
+}
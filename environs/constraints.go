@@ -0,0 +1,65 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/instance"
+)
+
+// ConstraintsSupporter is implemented by environments that can honour
+// only some of the possible constraints.
+type ConstraintsSupporter interface {
+	// SupportedConstraints returns the names of the constraints
+	// that the environment can honour.
+	SupportedConstraints() []string
+}
+
+// CheckConstraints returns an error if cons specifies any constraint
+// that the environment cannot honour. Environments that do not
+// implement ConstraintsSupporter are taken to honour all constraints.
+// Constraints set to empty values only clear any fallback values, so
+// they are always accepted.
+func CheckConstraints(environ Environ, cons constraints.Value) error {
+	supporter, ok := environ.(ConstraintsSupporter)
+	if !ok {
+		return nil
+	}
+	supported := make(map[string]bool)
+	for _, name := range supporter.SupportedConstraints() {
+		supported[name] = true
+	}
+	var unsupported []string
+	for _, name := range cons.Names() {
+		if !supported[name] && !isEmptyConstraint(cons, name) {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("constraints not supported by environment %q: %s", environ.Name(), strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// isEmptyConstraint returns whether the named constraint, which must be
+// specified in cons, is set to an empty value. A container constraint
+// of "none" asks for no container, so it is also taken to be empty.
+func isEmptyConstraint(cons constraints.Value, name string) bool {
+	switch name {
+	case "arch":
+		return *cons.Arch == ""
+	case "container":
+		return *cons.Container == "" || *cons.Container == instance.NONE
+	case "cpu-cores":
+		return *cons.CpuCores == 0
+	case "cpu-power":
+		return *cons.CpuPower == 0
+	case "mem":
+		return *cons.Mem == 0
+	}
+	return false
+}
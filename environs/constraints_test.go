@@ -0,0 +1,68 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs_test
+
+import (
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/environs/dummy"
+	"launchpad.net/juju-core/testing"
+)
+
+type ConstraintsSuite struct {
+	testing.LoggingSuite
+	env environs.Environ
+}
+
+var _ = Suite(&ConstraintsSuite{})
+
+func (s *ConstraintsSuite) SetUpTest(c *C) {
+	s.LoggingSuite.SetUpTest(c)
+	dummy.Reset()
+	env, err := environs.NewFromAttrs(map[string]interface{}{
+		"name":            "test",
+		"type":            "dummy",
+		"state-server":    false,
+		"authorized-keys": "i-am-a-key",
+		"ca-cert":         testing.CACert,
+		"ca-private-key":  "",
+	})
+	c.Assert(err, IsNil)
+	s.env = env
+}
+
+func (s *ConstraintsSuite) TearDownTest(c *C) {
+	dummy.Reset()
+	s.LoggingSuite.TearDownTest(c)
+}
+
+func (s *ConstraintsSuite) TestCheckConstraintsAllSupported(c *C) {
+	cons := constraints.MustParse("arch=amd64 container=lxc cpu-cores=2 cpu-power=100 mem=4G")
+	c.Assert(environs.CheckConstraints(s.env, cons), IsNil)
+}
+
+func (s *ConstraintsSuite) TestCheckConstraintsUnsupported(c *C) {
+	dummy.SetSupportedConstraints("arch", "mem")
+	c.Assert(environs.CheckConstraints(s.env, constraints.Value{}), IsNil)
+	c.Assert(environs.CheckConstraints(s.env, constraints.MustParse("arch=amd64 mem=4G")), IsNil)
+	err := environs.CheckConstraints(s.env, constraints.MustParse("mem=4G cpu-power=100 cpu-cores=2"))
+	c.Assert(err, ErrorMatches, `constraints not supported by environment "test": cpu-cores, cpu-power`)
+}
+
+func (s *ConstraintsSuite) TestCheckConstraintsAllowsEmptyValues(c *C) {
+	dummy.SetSupportedConstraints("mem")
+	cons := constraints.MustParse("arch= container= cpu-cores= cpu-power= mem=")
+	c.Assert(environs.CheckConstraints(s.env, cons), IsNil)
+	c.Assert(environs.CheckConstraints(s.env, constraints.MustParse("container=none")), IsNil)
+	err := environs.CheckConstraints(s.env, constraints.MustParse("container=lxc cpu-power="))
+	c.Assert(err, ErrorMatches, `constraints not supported by environment "test": container`)
+}
+
+func (s *ConstraintsSuite) TestSupportsFirewalling(c *C) {
+	c.Assert(environs.SupportsFirewalling(s.env), Equals, true)
+	dummy.SetSupportsFirewalling(false)
+	c.Assert(environs.SupportsFirewalling(s.env), Equals, false)
+}
@@ -0,0 +1,39 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/juju-core/instance"
+)
+
+// SupportedContainerTypes returns the types of container that can be
+// created on machines in the given environment. Environments that do
+// not implement ContainerEnviron support all the container types known
+// to juju.
+func SupportedContainerTypes(env Environ) []instance.ContainerType {
+	if env, ok := env.(ContainerEnviron); ok {
+		return env.SupportedContainerTypes()
+	}
+	return instance.SupportedContainerTypes
+}
+
+// CheckContainerType returns an error if containers of the given type
+// cannot be created on machines in the given environment.
+func CheckContainerType(env Environ, ctype instance.ContainerType) error {
+	supported := SupportedContainerTypes(env)
+	if len(supported) == 0 {
+		return fmt.Errorf("provider does not support containers")
+	}
+	var names []string
+	for _, t := range supported {
+		if t == ctype {
+			return nil
+		}
+		names = append(names, fmt.Sprintf("%q", t))
+	}
+	return fmt.Errorf("provider does not support %q containers, expected one of %s", ctype, strings.Join(names, ", "))
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs_test
+
+import (
+	gc "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/environs"
+	"launchpad.net/juju-core/instance"
+)
+
+type containersSuite struct{}
+
+var _ = gc.Suite(&containersSuite{})
+
+type plainEnviron struct {
+	environs.Environ
+}
+
+type containerEnviron struct {
+	environs.Environ
+	types []instance.ContainerType
+}
+
+func (env *containerEnviron) SupportedContainerTypes() []instance.ContainerType {
+	return env.types
+}
+
+func (*containersSuite) TestSupportedContainerTypesDefault(c *gc.C) {
+	types := environs.SupportedContainerTypes(&plainEnviron{})
+	c.Assert(types, gc.DeepEquals, instance.SupportedContainerTypes)
+}
+
+func (*containersSuite) TestSupportedContainerTypes(c *gc.C) {
+	env := &containerEnviron{types: []instance.ContainerType{instance.LXC}}
+	c.Assert(environs.SupportedContainerTypes(env), gc.DeepEquals, []instance.ContainerType{instance.LXC})
+	env.types = nil
+	c.Assert(environs.SupportedContainerTypes(env), gc.HasLen, 0)
+}
+
+func (*containersSuite) TestCheckContainerType(c *gc.C) {
+	c.Assert(environs.CheckContainerType(&plainEnviron{}, instance.LXC), gc.IsNil)
+
+	env := &containerEnviron{types: []instance.ContainerType{instance.LXC}}
+	c.Assert(environs.CheckContainerType(env, instance.LXC), gc.IsNil)
+
+	env.types = nil
+	err := environs.CheckContainerType(env, instance.LXC)
+	c.Assert(err, gc.ErrorMatches, "provider does not support containers")
+
+	env.types = []instance.ContainerType{"kvm"}
+	err = environs.CheckContainerType(env, instance.LXC)
+	c.Assert(err, gc.ErrorMatches, `provider does not support "lxc" containers, expected one of "kvm"`)
+}
@@ -113,6 +113,12 @@ type environProvider struct {
 	ops chan<- Operation
 	// We have one state for each environment name
 	state map[string]*environState
+	// supportedConstraints holds the names of the constraints
+	// honoured by all environments, or nil if all are honoured.
+	supportedConstraints []string
+	// noFirewalling records that environments must report
+	// that they do not support firewalling.
+	noFirewalling bool
 }
 
 var providerInstance environProvider
@@ -184,6 +190,8 @@ func Reset() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	providerInstance.ops = discardOperations
+	providerInstance.supportedConstraints = nil
+	providerInstance.noFirewalling = false
 	for _, s := range p.state {
 		s.httpListener.Close()
 		s.destroy()
@@ -297,6 +305,25 @@ func SetStorageDelay(d time.Duration) {
 	}
 }
 
+// SetSupportedConstraints restricts the constraints honoured by all
+// environments to those named. Reset restores support for all
+// constraints.
+func SetSupportedConstraints(names ...string) {
+	p := &providerInstance
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.supportedConstraints = names
+}
+
+// SetSupportsFirewalling sets whether all environments report that
+// they support firewalling. Reset restores support for firewalling.
+func SetSupportsFirewalling(supported bool) {
+	p := &providerInstance
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.noFirewalling = !supported
+}
+
 var configFields = schema.Fields{
 	"state-server": schema.Bool(),
 	"broken":       schema.String(),
@@ -497,6 +524,27 @@ func (e *environ) Bootstrap(cons constraints.Value) error {
 	return nil
 }
 
+// SupportedConstraints is specified in the environs.ConstraintsSupporter
+// interface.
+func (e *environ) SupportedConstraints() []string {
+	p := &providerInstance
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.supportedConstraints == nil {
+		return []string{"arch", "container", "cpu-cores", "cpu-power", "mem"}
+	}
+	return p.supportedConstraints
+}
+
+// SupportsFirewalling is specified in the environs.FirewallSupporter
+// interface.
+func (e *environ) SupportsFirewalling() bool {
+	p := &providerInstance
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.noFirewalling
+}
+
 func (e *environ) StateInfo() (*state.Info, *api.Info, error) {
 	e.state.mu.Lock()
 	defer e.state.mu.Unlock()
@@ -5,10 +5,13 @@ package dummy_test
 
 import (
 	. "launchpad.net/gocheck"
-	_ "launchpad.net/juju-core/environs/dummy"
+	"launchpad.net/juju-core/environs/dummy"
 	"launchpad.net/juju-core/environs/jujutest"
+	"launchpad.net/juju-core/instance"
+	jujutesting "launchpad.net/juju-core/juju/testing"
 	"launchpad.net/juju-core/testing"
 	stdtesting "testing"
+	"time"
 )
 
 func init() {
@@ -35,3 +38,51 @@ func init() {
 func TestSuite(t *stdtesting.T) {
 	testing.MgoTestPackage(t)
 }
+
+type portsSuite struct {
+	jujutesting.JujuConnSuite
+}
+
+var _ = Suite(&portsSuite{})
+
+func (s *portsSuite) TestPortOperations(c *C) {
+	inst, _ := jujutesting.StartInstance(c, s.Conn.Environ, "1")
+	ops := make(chan dummy.Operation, 10)
+	dummy.Listen(ops)
+	defer dummy.Listen(nil)
+
+	ports := []instance.Port{{Protocol: "tcp", Number: 80}, {Protocol: "udp", Number: 53}}
+	err := inst.OpenPorts("1", ports)
+	c.Assert(err, IsNil)
+	c.Assert(nextOp(c, ops), DeepEquals, dummy.OpOpenPorts{
+		Env:        "dummyenv",
+		MachineId:  "1",
+		InstanceId: inst.Id(),
+		Ports:      ports,
+	})
+	open, err := inst.Ports("1")
+	c.Assert(err, IsNil)
+	c.Assert(open, DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}, {Protocol: "udp", Number: 53}})
+
+	err = inst.ClosePorts("1", ports[:1])
+	c.Assert(err, IsNil)
+	c.Assert(nextOp(c, ops), DeepEquals, dummy.OpClosePorts{
+		Env:        "dummyenv",
+		MachineId:  "1",
+		InstanceId: inst.Id(),
+		Ports:      ports[:1],
+	})
+	open, err = inst.Ports("1")
+	c.Assert(err, IsNil)
+	c.Assert(open, DeepEquals, []instance.Port{{Protocol: "udp", Number: 53}})
+}
+
+func nextOp(c *C, ops <-chan dummy.Operation) dummy.Operation {
+	select {
+	case op := <-ops:
+		return op
+	case <-time.After(testing.LongWait):
+		c.Fatalf("timed out waiting for operation")
+	}
+	panic("unreachable")
+}
@@ -51,6 +51,8 @@ type environ struct {
 	s3Unlocked            *s3.S3
 	storageUnlocked       environs.Storage
 	publicStorageUnlocked environs.StorageReader // optional.
+
+	stateCache environs.StateCache
 }
 
 var _ environs.Environ = (*environ)(nil)
@@ -235,6 +237,7 @@ func (e *environ) PublicStorage() environs.StorageReader {
 }
 
 func (e *environ) Bootstrap(cons constraints.Value) error {
+	defer e.stateCache.Invalidate()
 	log.Infof("environs/ec2: bootstrapping environment %q", e.name)
 	// If the state file exists, it might actually have just been
 	// removed by Destroy, and eventual consistency has not caught
@@ -276,7 +279,7 @@ func (e *environ) Bootstrap(cons constraints.Value) error {
 }
 
 func (e *environ) StateInfo() (*state.Info, *api.Info, error) {
-	return environs.StateInfo(e)
+	return e.stateCache.StateInfo(e)
 }
 
 // getImageBaseURLs returns a list of URLs which are used to search for simplestreams image metadata.
@@ -516,6 +519,7 @@ func (e *environ) AllInstances() ([]instance.Instance, error) {
 
 func (e *environ) Destroy(ensureInsts []instance.Instance) error {
 	log.Infof("environs/ec2: destroying environment %q", e.name)
+	defer e.stateCache.Invalidate()
 	insts, err := e.AllInstances()
 	if err != nil {
 		return fmt.Errorf("cannot get instances: %v", err)
@@ -4,6 +4,8 @@
 package environs
 
 import (
+	"time"
+
 	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/state"
@@ -25,3 +27,13 @@ func ComposeAddresses(hostnames []string, port int) []string {
 func GetStateInfo(cfg *config.Config, hostnames []string) (*state.Info, *api.Info) {
 	return getStateInfo(cfg, hostnames)
 }
+
+// SetStateCacheExpiry sets how long a StateCache reuses the bootstrap
+// state, and returns a function that restores the original expiry.
+func SetStateCacheExpiry(d time.Duration) (restore func()) {
+	old := stateCacheExpiry
+	stateCacheExpiry = d
+	return func() {
+		stateCacheExpiry = old
+	}
+}
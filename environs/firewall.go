@@ -0,0 +1,22 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+// FirewallSupporter is implemented by environments that may be
+// unable to open and close ports.
+type FirewallSupporter interface {
+	// SupportsFirewalling returns whether the environment
+	// opens and closes ports when asked to.
+	SupportsFirewalling() bool
+}
+
+// SupportsFirewalling returns whether the environment opens and closes
+// ports when asked to. Environments that do not implement
+// FirewallSupporter are taken to support firewalling.
+func SupportsFirewalling(environ Environ) bool {
+	if supporter, ok := environ.(FirewallSupporter); ok {
+		return supporter.SupportsFirewalling()
+	}
+	return true
+}
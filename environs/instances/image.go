@@ -6,6 +6,7 @@ package instances
 import (
 	"fmt"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/errors"
 )
 
 // InstanceConstraint constrains the possible instances that may be
@@ -63,15 +64,15 @@ func FindInstanceSpec(possibleImages []Image, ic *InstanceConstraint, allInstanc
 	}
 
 	if len(possibleImages) == 0 || len(matchingTypes) == 0 {
-		return nil, fmt.Errorf("no %q images in %s with arches %s",
-			ic.Series, ic.Region, ic.Arches)
+		return nil, &errors.NotFoundError{Msg: fmt.Sprintf("no %q images in %s with arches %s",
+			ic.Series, ic.Region, ic.Arches)}
 	}
 
 	names := make([]string, len(matchingTypes))
 	for i, itype := range matchingTypes {
 		names[i] = itype.Name
 	}
-	return nil, fmt.Errorf("no %q images in %s matching instance types %v", ic.Series, ic.Region, names)
+	return nil, &errors.NotFoundError{Msg: fmt.Sprintf("no %q images in %s matching instance types %v", ic.Series, ic.Region, names)}
 }
 
 // Image holds the attributes that vary amongst relevant images for
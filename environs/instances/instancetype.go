@@ -6,6 +6,7 @@ package instances
 import (
 	"fmt"
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/errors"
 	"sort"
 )
 
@@ -120,7 +121,7 @@ func getMatchingInstanceTypes(ic *InstanceConstraint, allInstanceTypes []Instanc
 	}
 
 	// No luck, so report the error.
-	return nil, fmt.Errorf("no instance types in %s matching constraints %q", region, cons)
+	return nil, &errors.NotFoundError{Msg: fmt.Sprintf("no instance types in %s matching constraints %q", region, cons)}
 }
 
 // byCost is used to sort a slice of instance types by Cost.
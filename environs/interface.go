@@ -201,3 +201,30 @@ type Environ interface {
 	// Provider returns the EnvironProvider that created this Environ.
 	Provider() EnvironProvider
 }
+
+// ErrZonesNotSupported is returned by ZonedEnviron.AvailabilityZones
+// when the environment cannot place instances in availability zones.
+var ErrZonesNotSupported = errors.New("availability zones not supported")
+
+// ZonedEnviron is implemented by environments that can place instances
+// in availability zones. Callers should use a type assertion to find
+// out whether an Environ supports it.
+type ZonedEnviron interface {
+	Environ
+
+	// AvailabilityZones returns the names of the availability zones
+	// in which the environment can start instances. If the environment
+	// has no such zones, it returns ErrZonesNotSupported.
+	AvailabilityZones() ([]string, error)
+}
+
+// ContainerEnviron is implemented by environments that can create only
+// some types of container, or none at all, on their machines. Callers
+// should use SupportedContainerTypes rather than asserting it directly.
+type ContainerEnviron interface {
+	Environ
+
+	// SupportedContainerTypes returns the types of container that
+	// can be created on machines in the environment.
+	SupportedContainerTypes() []instance.ContainerType
+}
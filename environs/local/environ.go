@@ -19,7 +19,7 @@ import (
 )
 
 // localEnviron implements Environ.
-var _ environs.Environ = (*localEnviron)(nil)
+var _ environs.ZonedEnviron = (*localEnviron)(nil)
 
 type localEnviron struct {
 	localMutex            sync.Mutex
@@ -151,6 +151,12 @@ func (env *localEnviron) Ports() ([]instance.Port, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
+// AvailabilityZones is specified in the ZonedEnviron interface.
+// Local machines are not divided into zones.
+func (env *localEnviron) AvailabilityZones() ([]string, error) {
+	return nil, environs.ErrZonesNotSupported
+}
+
 // Provider is specified in the Environ interface.
 func (env *localEnviron) Provider() environs.EnvironProvider {
 	return &provider
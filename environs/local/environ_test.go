@@ -6,6 +6,7 @@ package local_test
 import (
 	gc "launchpad.net/gocheck"
 
+	"launchpad.net/juju-core/environs"
 	"launchpad.net/juju-core/environs/jujutest"
 	"launchpad.net/juju-core/environs/local"
 	jc "launchpad.net/juju-core/testing/checkers"
@@ -40,6 +41,20 @@ func (s *environSuite) TestNameAndStorage(c *gc.C) {
 	c.Assert(environ.PublicStorage(), gc.NotNil)
 }
 
+func (s *environSuite) TestAvailabilityZones(c *gc.C) {
+	testConfig := minimalConfig(c)
+	err := local.CreateDirs(c, testConfig)
+	c.Assert(err, gc.IsNil)
+
+	environ, err := local.Provider.Open(testConfig)
+	c.Assert(err, gc.IsNil)
+	zoned, ok := environ.(environs.ZonedEnviron)
+	c.Assert(ok, gc.Equals, true)
+	zones, err := zoned.AvailabilityZones()
+	c.Assert(err, gc.Equals, environs.ErrZonesNotSupported)
+	c.Assert(zones, gc.IsNil)
+}
+
 type localJujuTestSuite struct {
 	baseProviderSuite
 	jujutest.Tests
@@ -52,9 +52,11 @@ type maasEnviron struct {
 	ecfgUnlocked       *maasEnvironConfig
 	maasClientUnlocked *gomaasapi.MAASObject
 	storageUnlocked    environs.Storage
+
+	stateCache environs.StateCache
 }
 
-var _ environs.Environ = (*maasEnviron)(nil)
+var _ environs.ZonedEnviron = (*maasEnviron)(nil)
 
 func NewEnviron(cfg *config.Config) (*maasEnviron, error) {
 	env := new(maasEnviron)
@@ -110,6 +112,7 @@ func (env *maasEnviron) startBootstrapNode(cons constraints.Value) (instance.Ins
 
 // Bootstrap is specified in the Environ interface.
 func (env *maasEnviron) Bootstrap(cons constraints.Value) error {
+	defer env.stateCache.Invalidate()
 
 	if err := environs.VerifyBootstrapInit(env, shortAttempt); err != nil {
 		return err
@@ -137,7 +140,20 @@ func (env *maasEnviron) Bootstrap(cons constraints.Value) error {
 
 // StateInfo is specified in the Environ interface.
 func (env *maasEnviron) StateInfo() (*state.Info, *api.Info, error) {
-	return environs.StateInfo(env)
+	return env.stateCache.StateInfo(env)
+}
+
+// SupportedConstraints is specified in the environs.ConstraintsSupporter
+// interface. MAAS has no notion of CPU power, and does not support
+// containers; see SupportedContainerTypes.
+func (env *maasEnviron) SupportedConstraints() []string {
+	return []string{"arch", "cpu-cores", "mem"}
+}
+
+// SupportsFirewalling is specified in the environs.FirewallSupporter
+// interface. MAAS does not do firewalling.
+func (env *maasEnviron) SupportsFirewalling() bool {
+	return false
 }
 
 // ecfg returns the environment's maasEnvironConfig, and protects it with a
@@ -168,6 +184,12 @@ func (env *maasEnviron) SetConfig(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	if oldCfg != nil {
+		// Only the names are logged, as the values may be secret.
+		for _, change := range oldCfg.Diff(cfg) {
+			log.Infof("environs/maas: configuration attribute %q changed", change.Name)
+		}
+	}
 
 	ecfg, err := providerInstance.newConfig(cfg)
 	if err != nil {
@@ -410,6 +432,44 @@ func (environ *maasEnviron) AllInstances() ([]instance.Instance, error) {
 	return environ.instances(nil)
 }
 
+// SupportedContainerTypes is defined by the ContainerEnviron interface.
+// Containers cannot yet be created on MAAS nodes, because the provider
+// cannot give them addresses on the nodes' network.
+func (environ *maasEnviron) SupportedContainerTypes() []instance.ContainerType {
+	return nil
+}
+
+// AvailabilityZones is defined by the ZonedEnviron interface. It
+// returns the names of the zones the MAAS server divides its nodes
+// into, or ErrZonesNotSupported if the server does not know of zones.
+func (environ *maasEnviron) AvailabilityZones() ([]string, error) {
+	zoneObjects, err := environ.getMAASClient().GetSubObject("zones").CallGet("", nil)
+	if err != nil {
+		serverErr, ok := err.(gomaasapi.ServerError)
+		if ok && serverErr.StatusCode == 404 {
+			return nil, environs.ErrZonesNotSupported
+		}
+		return nil, fmt.Errorf("cannot list availability zones: %v", err)
+	}
+	listZones, err := zoneObjects.GetArray()
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]string, len(listZones))
+	for index, zoneObj := range listZones {
+		zone, err := zoneObj.GetMap()
+		if err != nil {
+			return nil, err
+		}
+		name, err := zone["name"].GetString()
+		if err != nil {
+			return nil, err
+		}
+		zones[index] = name
+	}
+	return zones, nil
+}
+
 // Storage is defined by the Environ interface.
 func (env *maasEnviron) Storage() environs.Storage {
 	env.ecfgMutex.Lock()
@@ -425,6 +485,7 @@ func (env *maasEnviron) PublicStorage() environs.StorageReader {
 
 func (environ *maasEnviron) Destroy(ensureInsts []instance.Instance) error {
 	log.Debugf("environs/maas: destroying environment %q", environ.name)
+	defer environ.stateCache.Invalidate()
 	insts, err := environ.AllInstances()
 	if err != nil {
 		return fmt.Errorf("cannot get instances: %v", err)
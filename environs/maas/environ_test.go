@@ -19,6 +19,8 @@ import (
 	"launchpad.net/juju-core/testing"
 	"launchpad.net/juju-core/utils"
 	"launchpad.net/juju-core/version"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 )
 
@@ -119,6 +121,19 @@ func (EnvironSuite) TestSetConfigUpdatesConfig(c *C) {
 	c.Check(MAASServer, DeepEquals, maas)
 }
 
+func (EnvironSuite) TestSetConfigLogsChanges(c *C) {
+	cfg := getTestConfig("test env", "http://maas.testing.invalid", "a:b:c", "secret")
+	env, err := NewEnviron(cfg)
+	c.Assert(err, IsNil)
+
+	cfg2 := getTestConfig("test env", "http://maas2.testing.invalid", "a:b:c", "secret2")
+	err = env.SetConfig(cfg2)
+	c.Assert(err, IsNil)
+	c.Check(c.GetTestLog(), Matches, `(?s).*configuration attribute "admin-secret" changed.*`)
+	c.Check(c.GetTestLog(), Matches, `(?s).*configuration attribute "maas-server" changed.*`)
+	c.Check(c.GetTestLog(), Not(Matches), `(?s).*(secret2|"maas-oauth").*`)
+}
+
 func (EnvironSuite) TestNewEnvironSetsConfig(c *C) {
 	name := "test env"
 	cfg := getTestConfig(name, "http://maas.testing.invalid", "a:b:c", "secret")
@@ -201,6 +216,25 @@ func (suite *EnvironSuite) TestInstancesReturnsErrorIfPartialInstances(c *C) {
 	c.Check(string(instances[0].Id()), Equals, resourceURI1)
 }
 
+func (suite *EnvironSuite) TestCheckConstraints(c *C) {
+	env := suite.makeEnviron()
+	err := environs.CheckConstraints(env, constraints.MustParse("arch=amd64 cpu-cores=2 mem=4G"))
+	c.Assert(err, IsNil)
+	err = environs.CheckConstraints(env, constraints.MustParse("cpu-power=100"))
+	c.Assert(err, ErrorMatches, `constraints not supported by environment ".*": cpu-power`)
+
+	// Empty values are accepted whether or not they are supported.
+	err = environs.CheckConstraints(env, constraints.MustParse("arch=amd64 cpu-cores=2 mem=4G container= cpu-power="))
+	c.Assert(err, IsNil)
+	err = environs.CheckConstraints(env, constraints.MustParse("container=lxc cpu-power=100"))
+	c.Assert(err, ErrorMatches, `constraints not supported by environment ".*": container, cpu-power`)
+}
+
+func (suite *EnvironSuite) TestSupportsFirewalling(c *C) {
+	env := suite.makeEnviron()
+	c.Check(environs.SupportsFirewalling(env), Equals, false)
+}
+
 func (suite *EnvironSuite) TestStorageReturnsStorage(c *C) {
 	env := suite.makeEnviron()
 	storage := env.Storage()
@@ -457,3 +491,57 @@ func (suite *EnvironSuite) TestBootstrapIntegratesWithEnvirons(c *C) {
 	err := environs.Bootstrap(env, constraints.Value{})
 	c.Assert(err, IsNil)
 }
+
+// newZonesServer returns an HTTP server that serves the MAAS zones
+// listing with the given status code and body.
+func newZonesServer(c *C, status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Check(req.Method, Equals, "GET")
+		c.Check(req.URL.Path, Equals, "/api/1.0/zones/")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func (EnvironSuite) TestSupportedContainerTypes(c *C) {
+	env, err := NewEnviron(getTestConfig("containers", "http://maas.example.com", "a:b:c", "secret"))
+	c.Assert(err, IsNil)
+	var _ environs.ContainerEnviron = env
+	c.Assert(environs.SupportedContainerTypes(env), HasLen, 0)
+}
+
+func (EnvironSuite) TestAvailabilityZones(c *C) {
+	server := newZonesServer(c, http.StatusOK, `[
+		{"name": "zone1", "description": "rack one", "resource_uri": "/api/1.0/zones/zone1/"},
+		{"name": "zone2", "description": "", "resource_uri": "/api/1.0/zones/zone2/"}
+	]`)
+	defer server.Close()
+	env, err := NewEnviron(getTestConfig("zoned", server.URL, "a:b:c", "secret"))
+	c.Assert(err, IsNil)
+
+	var zoned environs.ZonedEnviron = env
+	zones, err := zoned.AvailabilityZones()
+	c.Assert(err, IsNil)
+	c.Assert(zones, DeepEquals, []string{"zone1", "zone2"})
+}
+
+func (EnvironSuite) TestAvailabilityZonesNotSupported(c *C) {
+	server := newZonesServer(c, http.StatusNotFound, "Not Found")
+	defer server.Close()
+	env, err := NewEnviron(getTestConfig("zoned", server.URL, "a:b:c", "secret"))
+	c.Assert(err, IsNil)
+
+	zones, err := env.AvailabilityZones()
+	c.Assert(err, Equals, environs.ErrZonesNotSupported)
+	c.Assert(zones, IsNil)
+}
+
+func (EnvironSuite) TestAvailabilityZonesError(c *C) {
+	server := newZonesServer(c, http.StatusInternalServerError, "boom")
+	defer server.Close()
+	env, err := NewEnviron(getTestConfig("zoned", server.URL, "a:b:c", "secret"))
+	c.Assert(err, IsNil)
+
+	_, err = env.AvailabilityZones()
+	c.Assert(err, ErrorMatches, "cannot list availability zones: .*")
+}
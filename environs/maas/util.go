@@ -36,6 +36,9 @@ func getSystemIdValues(instanceIds []instance.Id) url.Values {
 
 // userData returns a zipped cloudinit config.
 func userData(cfg *cloudinit.MachineConfig, scripts ...string) ([]byte, error) {
+	if err := cfg.Verify(); err != nil {
+		return nil, err
+	}
 	cloudcfg := cloudinit_core.New()
 	for _, script := range scripts {
 		cloudcfg.AddRunCmd(script)
@@ -97,6 +97,57 @@ func (s *UtilSuite) TestUserData(c *C) {
 	c.Check(runCmd[1], Equals, script2)
 }
 
+func (s *UtilSuite) TestUserDataVerifiesConfig(c *C) {
+	env := &maasEnviron{name: "foo"}
+	stateInfo := &state.Info{
+		Addrs:  []string{"localhost:37017"},
+		CACert: []byte(testing.CACert),
+		Tag:    "machine-3",
+	}
+	apiInfo := &api.Info{
+		Addrs:  []string{"localhost:17070"},
+		CACert: []byte(testing.CACert),
+		Tag:    "machine-3",
+	}
+	tools := &state.Tools{
+		URL:    "http://foo.com/tools/juju1.2.3-linux-amd64.tgz",
+		Binary: version.MustParseBinary("1.2.3-linux-amd64"),
+	}
+	tests := []struct {
+		mutate func(*cloudinit.MachineConfig)
+		err    string
+	}{{
+		func(cfg *cloudinit.MachineConfig) {},
+		"invalid configuration for machine 3: missing tools",
+	}, {
+		func(cfg *cloudinit.MachineConfig) {
+			cfg.Tools = tools
+			cfg.StateInfo = nil
+		},
+		"invalid configuration for machine 3: missing state info",
+	}, {
+		func(cfg *cloudinit.MachineConfig) {
+			cfg.Tools = tools
+			cfg.APIInfo = nil
+		},
+		"invalid configuration for machine 3: missing API info",
+	}, {
+		func(cfg *cloudinit.MachineConfig) {
+			cfg.Tools = tools
+			cfg.MachineNonce = ""
+		},
+		"invalid configuration for machine 3: missing machine nonce",
+	}}
+	for i, test := range tests {
+		c.Logf("test %d: %s", i, test.err)
+		cfg := env.makeMachineConfig("3", "fake-nonce", stateInfo, apiInfo)
+		test.mutate(cfg)
+		data, err := userData(cfg)
+		c.Check(err, ErrorMatches, test.err)
+		c.Check(data, IsNil)
+	}
+}
+
 func (s *UtilSuite) TestMachineInfoCloudinitRunCmd(c *C) {
 	instanceId := "instanceId"
 	hostname := "hostname"
@@ -257,6 +257,8 @@ type environ struct {
 	// An ordered list of paths in which to find the simplestreams index files used to
 	// look up image ids.
 	imageBaseURLs []string
+
+	stateCache environs.StateCache
 }
 
 var _ environs.Environ = (*environ)(nil)
@@ -464,6 +466,7 @@ func (e *environ) PublicStorage() environs.StorageReader {
 }
 
 func (e *environ) Bootstrap(cons constraints.Value) error {
+	defer e.stateCache.Invalidate()
 	log.Infof("environs/openstack: bootstrapping environment %q", e.name)
 
 	if err := environs.VerifyBootstrapInit(e, shortAttempt); err != nil {
@@ -506,7 +509,7 @@ func (e *environ) Bootstrap(cons constraints.Value) error {
 }
 
 func (e *environ) StateInfo() (*state.Info, *api.Info, error) {
-	return environs.StateInfo(e)
+	return e.stateCache.StateInfo(e)
 }
 
 func (e *environ) Config() *config.Config {
@@ -891,6 +894,7 @@ func (e *environ) AllInstances() (insts []instance.Instance, err error) {
 
 func (e *environ) Destroy(ensureInsts []instance.Instance) error {
 	log.Infof("environs/openstack: destroying environment %q", e.name)
+	defer e.stateCache.Invalidate()
 	insts, err := e.AllInstances()
 	if err != nil {
 		return fmt.Errorf("cannot get instances: %v", err)
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"sync"
+	"time"
 
 	"launchpad.net/goyaml"
 
@@ -59,6 +61,64 @@ func LoadState(storage StorageReader) (*BootstrapState, error) {
 	return &state, nil
 }
 
+// StateCache caches the bootstrap state of an environment, so that
+// its StateInfo method need not read the state from storage every
+// time. Providers keep one in each environ, and must call Invalidate
+// when the environment is bootstrapped or destroyed. The cached state
+// is only reused for stateCacheExpiry, so that changes made by other
+// processes are seen eventually.
+type StateCache struct {
+	mu     sync.Mutex
+	state  *BootstrapState
+	loaded time.Time
+	// generation is incremented when the cache is invalidated,
+	// so that state loaded before then is not cached.
+	generation int
+}
+
+// stateCacheExpiry is how long cached bootstrap state is reused.
+var stateCacheExpiry = time.Minute
+
+// load returns the bootstrap state, reading it from storage unless it
+// was read recently.
+func (c *StateCache) load(storage StorageReader) (*BootstrapState, error) {
+	c.mu.Lock()
+	st, loaded, generation := c.state, c.loaded, c.generation
+	c.mu.Unlock()
+	if st != nil && time.Since(loaded) < stateCacheExpiry {
+		return st, nil
+	}
+	st, err := LoadState(storage)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.generation == generation {
+		c.state, c.loaded = st, time.Now()
+	}
+	c.mu.Unlock()
+	return st, nil
+}
+
+// Invalidate discards the cached state, so that it is read from
+// storage again when next needed.
+func (c *StateCache) Invalidate() {
+	c.mu.Lock()
+	c.state = nil
+	c.generation++
+	c.mu.Unlock()
+}
+
+// StateInfo is like the StateInfo function, but reads the bootstrap
+// state through the cache.
+func (c *StateCache) StateInfo(env Environ) (*state.Info, *api.Info, error) {
+	st, err := c.load(env.Storage())
+	if err != nil {
+		return nil, nil, err
+	}
+	return stateInfo(env, st)
+}
+
 // getDNSNames queries and returns the DNS names for the given instances,
 // ignoring nil instances or ones without DNS names.
 func getDNSNames(instances []instance.Instance) []string {
@@ -109,6 +169,12 @@ func StateInfo(env Environ) (*state.Info, *api.Info, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	return stateInfo(env, st)
+}
+
+// stateInfo returns the state and API connection information for the
+// environment with the given bootstrap state.
+func stateInfo(env Environ, st *BootstrapState) (*state.Info, *api.Info, error) {
 	config := env.Config()
 	if _, hasCert := config.CACert(); !hasCert {
 		return nil, nil, fmt.Errorf("no CA certificate in environment configuration")
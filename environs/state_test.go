@@ -5,7 +5,9 @@ package environs_test
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
+	"sync"
 
 	. "launchpad.net/gocheck"
 	"launchpad.net/goyaml"
@@ -147,3 +149,126 @@ func (suite *StateSuite) TestGetStateInfo(c *C) {
 	c.Check(apiInfo.Addrs, DeepEquals, []string{"onehost:456", "otherhost:456"})
 	c.Check(string(apiInfo.CACert), Equals, cert)
 }
+
+// countingStorage counts the reads of the state file from the
+// storage it wraps.
+type countingStorage struct {
+	environs.Storage
+	mu    sync.Mutex
+	reads int
+}
+
+func (s *countingStorage) Get(name string) (io.ReadCloser, error) {
+	if name == environs.StateFile {
+		s.mu.Lock()
+		s.reads++
+		s.mu.Unlock()
+	}
+	return s.Storage.Get(name)
+}
+
+func (s *countingStorage) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// stateInfoEnviron implements just enough of environs.Environ
+// for environs.StateInfo to be called on it.
+type stateInfoEnviron struct {
+	environs.Environ
+	cfg     *config.Config
+	storage environs.Storage
+}
+
+func (e *stateInfoEnviron) Config() *config.Config {
+	return e.cfg
+}
+
+func (e *stateInfoEnviron) Storage() environs.Storage {
+	return e.storage
+}
+
+func (e *stateInfoEnviron) Instances(ids []instance.Id) ([]instance.Instance, error) {
+	insts := make([]instance.Instance, len(ids))
+	for i, id := range ids {
+		insts[i] = &dnsNameFakeInstance{name: string(id) + ".example.com"}
+	}
+	return insts, nil
+}
+
+func (suite *StateSuite) TestStateCache(c *C) {
+	cfg, err := config.New(map[string]interface{}{
+		"name":            "aname",
+		"type":            "dummy",
+		"authorized-keys": "foo",
+		"ca-cert":         testing.CACert,
+		"ca-private-key":  testing.CAKey,
+		"state-port":      123,
+		"api-port":        456,
+	})
+	c.Assert(err, IsNil)
+	dummyStorage, cleanup := makeDummyStorage(c)
+	defer cleanup()
+	storage := &countingStorage{Storage: dummyStorage}
+	err = environs.SaveState(storage, &environs.BootstrapState{StateInstances: []instance.Id{"one"}})
+	c.Assert(err, IsNil)
+
+	env := &stateInfoEnviron{cfg: cfg, storage: storage}
+	var cache environs.StateCache
+	for i := 0; i < 3; i++ {
+		stateInfo, apiInfo, err := cache.StateInfo(env)
+		c.Assert(err, IsNil)
+		c.Assert(stateInfo.Addrs, DeepEquals, []string{"one.example.com:123"})
+		c.Assert(apiInfo.Addrs, DeepEquals, []string{"one.example.com:456"})
+	}
+	c.Assert(storage.Reads(), Equals, 1)
+
+	// Once invalidated, the state is read again.
+	err = environs.SaveState(storage, &environs.BootstrapState{StateInstances: []instance.Id{"two"}})
+	c.Assert(err, IsNil)
+	cache.Invalidate()
+	stateInfo, _, err := cache.StateInfo(env)
+	c.Assert(err, IsNil)
+	c.Assert(stateInfo.Addrs, DeepEquals, []string{"two.example.com:123"})
+	c.Assert(storage.Reads(), Equals, 2)
+
+	// Once expired, the state is read again, so that changes
+	// made elsewhere are seen.
+	err = environs.SaveState(storage, &environs.BootstrapState{StateInstances: []instance.Id{"three"}})
+	c.Assert(err, IsNil)
+	defer environs.SetStateCacheExpiry(0)()
+	stateInfo, _, err = cache.StateInfo(env)
+	c.Assert(err, IsNil)
+	c.Assert(stateInfo.Addrs, DeepEquals, []string{"three.example.com:123"})
+	c.Assert(storage.Reads(), Equals, 3)
+
+	// The plain StateInfo function does not cache at all.
+	_, _, err = environs.StateInfo(env)
+	c.Assert(err, IsNil)
+	c.Assert(storage.Reads(), Equals, 4)
+}
+
+func (suite *StateSuite) TestStateCacheDoesNotCacheErrors(c *C) {
+	cfg, err := config.New(map[string]interface{}{
+		"name":            "aname",
+		"type":            "dummy",
+		"authorized-keys": "foo",
+		"ca-cert":         testing.CACert,
+		"ca-private-key":  testing.CAKey,
+	})
+	c.Assert(err, IsNil)
+	dummyStorage, cleanup := makeDummyStorage(c)
+	defer cleanup()
+	storage := &countingStorage{Storage: dummyStorage}
+	env := &stateInfoEnviron{cfg: cfg, storage: storage}
+	var cache environs.StateCache
+
+	_, _, err = cache.StateInfo(env)
+	c.Assert(errors.IsNotFoundError(err), Equals, true)
+	err = environs.SaveState(storage, &environs.BootstrapState{StateInstances: []instance.Id{"one"}})
+	c.Assert(err, IsNil)
+	_, _, err = cache.StateInfo(env)
+	c.Assert(err, IsNil)
+	c.Assert(storage.Reads(), Equals, 2)
+}
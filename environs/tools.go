@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"launchpad.net/juju-core/constraints"
+	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/environs/tools"
 	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/log"
@@ -138,6 +139,28 @@ func FindExactTools(environ Environ, vers version.Binary) (t *state.Tools, err e
 	return list[0], nil
 }
 
+// CheckDefaultSeriesTools returns an error satisfying errors.IsNotFoundError
+// if no tools for the default-series in cfg are available to environ. If
+// cfg specifies an agent version, only tools of that version count;
+// otherwise any tools with the current major version will do.
+func CheckDefaultSeriesTools(environ Environ, cfg *config.Config) (err error) {
+	defer convertToolsError(&err)
+	series := cfg.DefaultSeries()
+	majorVersion := version.Current.Major
+	filter := tools.Filter{Series: series}
+	if agentVersion, ok := cfg.AgentVersion(); ok {
+		majorVersion = agentVersion.Major
+		filter.Number = agentVersion
+	}
+	list, err := FindAvailableTools(environ, majorVersion)
+	if err != nil {
+		return err
+	}
+	log.Infof("environs: checking for tools with series: %s", series)
+	_, err = list.Match(filter)
+	return err
+}
+
 func isToolsError(err error) bool {
 	switch err {
 	case tools.ErrNoTools, tools.ErrNoMatches:
@@ -553,3 +553,61 @@ func (s *ToolsSuite) TestFindExactTools(c *C) {
 		}
 	}
 }
+
+var checkDefaultSeriesToolsTests = []struct {
+	info         string
+	available    []version.Binary
+	agentVersion version.Number
+	series       string
+	err          error
+}{{
+	info:   "nothing at all",
+	series: "precise",
+	err:    tools.ErrNoTools,
+}, {
+	info:      "no tools for series",
+	available: v100q,
+	series:    "precise",
+	err:       tools.ErrNoMatches,
+}, {
+	info:      "no tools for major version",
+	available: v220all,
+	series:    "precise",
+	err:       tools.ErrNoMatches,
+}, {
+	info:         "no tools for agent version",
+	available:    append(v100p, v120q...),
+	agentVersion: v120,
+	series:       "precise",
+	err:          tools.ErrNoMatches,
+}, {
+	info:      "tools for series",
+	available: v1all,
+	series:    "quantal",
+}, {
+	info:         "tools for series and agent version",
+	available:    v1all,
+	agentVersion: v110,
+	series:       "precise",
+}}
+
+func (s *ToolsSuite) TestCheckDefaultSeriesTools(c *C) {
+	for i, test := range checkDefaultSeriesToolsTests {
+		c.Logf("\ntest %d: %s", i, test.info)
+		attrs := map[string]interface{}{
+			"default-series": test.series,
+		}
+		if test.agentVersion != version.Zero {
+			attrs["agent-version"] = test.agentVersion.String()
+		}
+		s.Reset(c, attrs)
+		version.Current = v100p64
+		s.uploadPrivate(c, test.available...)
+		err := environs.CheckDefaultSeriesTools(s.env, s.env.Config())
+		if test.err != nil {
+			c.Check(err, DeepEquals, &errors.NotFoundError{test.err, ""})
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
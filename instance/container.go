@@ -5,10 +5,15 @@ package instance
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ContainerType string
 
+func (ctype ContainerType) String() string {
+	return string(ctype)
+}
+
 const (
 	NONE = ContainerType("none")
 	LXC  = ContainerType("lxc")
@@ -24,19 +29,24 @@ var SupportedContainerTypes []ContainerType = []ContainerType{
 // ContainerType instance or returns an error if the container type is invalid.
 // For this version of the function, 'none' is a valid value.
 func ParseSupportedContainerTypeOrNone(ctype string) (ContainerType, error) {
-	if ContainerType(ctype) == NONE {
-		return NONE, nil
-	}
-	return ParseSupportedContainerType(ctype)
+	return parseContainerType(ctype, append([]ContainerType{NONE}, SupportedContainerTypes...))
 }
 
 // ParseSupportedContainerType converts the specified string into a supported
 // ContainerType instance or returns an error if the container type is invalid.
 func ParseSupportedContainerType(ctype string) (ContainerType, error) {
-	for _, supportedType := range SupportedContainerTypes {
-		if ContainerType(ctype) == supportedType {
-			return supportedType, nil
+	return parseContainerType(ctype, SupportedContainerTypes)
+}
+
+// parseContainerType returns the member of valid named by ctype, or
+// an error listing the valid container types.
+func parseContainerType(ctype string, valid []ContainerType) (ContainerType, error) {
+	names := make([]string, len(valid))
+	for i, validType := range valid {
+		if ContainerType(ctype) == validType {
+			return validType, nil
 		}
+		names[i] = fmt.Sprintf("%q", validType)
 	}
-	return "", fmt.Errorf("invalid container type %q", ctype)
+	return "", fmt.Errorf("invalid container type %q, expected one of %s", ctype, strings.Join(names, ", "))
 }
@@ -35,3 +35,25 @@ func (s *InstanceSuite) TestParseSupportedContainerTypeOrNone(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(ctype, Equals, instance.ContainerType("none"))
 }
+
+func (s *InstanceSuite) TestSupportedContainerTypesRoundTrip(c *C) {
+	c.Assert(instance.SupportedContainerTypes, Not(HasLen), 0)
+	for _, ctype := range instance.SupportedContainerTypes {
+		parsed, err := instance.ParseSupportedContainerType(ctype.String())
+		c.Assert(err, IsNil)
+		c.Assert(parsed, Equals, ctype)
+		parsed, err = instance.ParseSupportedContainerTypeOrNone(ctype.String())
+		c.Assert(err, IsNil)
+		c.Assert(parsed, Equals, ctype)
+	}
+	c.Assert(instance.NONE.String(), Equals, "none")
+}
+
+func (s *InstanceSuite) TestParseUnknownContainerType(c *C) {
+	_, err := instance.ParseSupportedContainerType("bogus")
+	c.Assert(err, ErrorMatches, `invalid container type "bogus", expected one of "lxc"`)
+	_, err = instance.ParseSupportedContainerType("none")
+	c.Assert(err, ErrorMatches, `invalid container type "none", expected one of "lxc"`)
+	_, err = instance.ParseSupportedContainerTypeOrNone("bogus")
+	c.Assert(err, ErrorMatches, `invalid container type "bogus", expected one of "none", "lxc"`)
+}
@@ -6,6 +6,8 @@ package instance
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -25,6 +27,21 @@ func (p Port) String() string {
 	return fmt.Sprintf("%s:%d", p.Protocol, p.Number)
 }
 
+// Validate normalizes the port's protocol to lower case, and returns
+// an error if the protocol is neither "tcp" nor "udp", or if the port
+// number is not in the range [1, 65535].
+func (p *Port) Validate() error {
+	protocol := strings.ToLower(p.Protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		return fmt.Errorf(`invalid protocol %q, expected "tcp" or "udp"`, p.Protocol)
+	}
+	if p.Number < 1 || p.Number > 65535 {
+		return fmt.Errorf("port number %d out of range [1, 65535]", p.Number)
+	}
+	p.Protocol = protocol
+	return nil
+}
+
 // Instance represents the the realization of a machine in state.
 type Instance interface {
 	// Id returns a provider-generated identifier for the Instance.
@@ -60,6 +77,7 @@ type Instance interface {
 type HardwareCharacteristics struct {
 	Arch     *string
 	Mem      *uint64
+	RootDisk *uint64
 	CpuCores *uint64
 	CpuPower *uint64
 }
@@ -71,6 +89,8 @@ func uintStr(i uint64) string {
 	return fmt.Sprintf("%d", i)
 }
 
+// String expresses the hardware characteristics in the form
+// accepted by ParseHardwareCharacteristics.
 func (v HardwareCharacteristics) String() string {
 	var strs []string
 	if v.Arch != nil {
@@ -83,11 +103,156 @@ func (v HardwareCharacteristics) String() string {
 		strs = append(strs, "cpu-power="+uintStr(*v.CpuPower))
 	}
 	if v.Mem != nil {
-		s := uintStr(*v.Mem)
-		if s != "" {
-			s += "M"
-		}
-		strs = append(strs, "mem="+s)
+		strs = append(strs, "mem="+sizeStr(*v.Mem))
+	}
+	if v.RootDisk != nil {
+		strs = append(strs, "root-disk="+sizeStr(*v.RootDisk))
 	}
 	return strings.Join(strs, " ")
 }
+
+func sizeStr(i uint64) string {
+	s := uintStr(i)
+	if s != "" {
+		s += "M"
+	}
+	return s
+}
+
+// ParseHardwareCharacteristics constructs a HardwareCharacteristics from
+// the supplied arguments, each of which must contain only spaces and
+// name=value pairs. Characteristics that are not mentioned are left nil.
+// If any name is unknown or specified more than once, an error is returned.
+func ParseHardwareCharacteristics(args ...string) (HardwareCharacteristics, error) {
+	hc := HardwareCharacteristics{}
+	for _, arg := range args {
+		raws := strings.Split(strings.TrimSpace(arg), " ")
+		for _, raw := range raws {
+			if raw == "" {
+				continue
+			}
+			if err := hc.setRaw(raw); err != nil {
+				return HardwareCharacteristics{}, err
+			}
+		}
+	}
+	return hc, nil
+}
+
+// MustParseHardwareCharacteristics constructs a HardwareCharacteristics
+// from the supplied arguments, as ParseHardwareCharacteristics, but panics
+// on failure.
+func MustParseHardwareCharacteristics(args ...string) HardwareCharacteristics {
+	hc, err := ParseHardwareCharacteristics(args...)
+	if err != nil {
+		panic(err)
+	}
+	return hc
+}
+
+// setRaw interprets a name=value string and sets the supplied value.
+func (hc *HardwareCharacteristics) setRaw(raw string) error {
+	eq := strings.Index(raw, "=")
+	if eq <= 0 {
+		return fmt.Errorf("malformed characteristic %q", raw)
+	}
+	name, str := raw[:eq], raw[eq+1:]
+	var err error
+	switch name {
+	case "arch":
+		err = hc.setArch(str)
+	case "cpu-cores":
+		err = setUint64(&hc.CpuCores, str)
+	case "cpu-power":
+		err = setUint64(&hc.CpuPower, str)
+	case "mem":
+		err = setSize(&hc.Mem, str)
+	case "root-disk":
+		err = setSize(&hc.RootDisk, str)
+	default:
+		return fmt.Errorf("unknown characteristic %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("bad %q characteristic: %v", name, err)
+	}
+	return nil
+}
+
+func (hc *HardwareCharacteristics) setArch(str string) (err error) {
+	if hc.Arch != nil {
+		return fmt.Errorf("already set")
+	}
+	hc.Arch, err = ParseArch(str)
+	return
+}
+
+func setUint64(target **uint64, str string) (err error) {
+	if *target != nil {
+		return fmt.Errorf("already set")
+	}
+	*target, err = ParseUint64(str)
+	return
+}
+
+func setSize(target **uint64, str string) (err error) {
+	if *target != nil {
+		return fmt.Errorf("already set")
+	}
+	*target, err = ParseSize(str)
+	return
+}
+
+// ParseArch checks that str names a known architecture, or is empty,
+// and returns it. It is shared by hardware characteristics and
+// constraints.
+func ParseArch(str string) (*string, error) {
+	switch str {
+	case "":
+	case "amd64", "i386", "arm":
+	default:
+		return nil, fmt.Errorf("%q not recognized", str)
+	}
+	return &str, nil
+}
+
+// ParseUint64 parses a non-negative integer. An empty string is
+// parsed as zero.
+func ParseUint64(str string) (*uint64, error) {
+	var value uint64
+	if str != "" {
+		val, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a non-negative integer")
+		}
+		value = val
+	}
+	return &value, nil
+}
+
+// ParseSize parses a size in megabytes, which may be given with an
+// M, G, T or P suffix and is rounded up to a whole number. An empty
+// string is parsed as zero.
+func ParseSize(str string) (*uint64, error) {
+	var value uint64
+	if str != "" {
+		mult := 1.0
+		if m, ok := mbSuffixes[str[len(str)-1:]]; ok {
+			str = str[:len(str)-1]
+			mult = m
+		}
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil || val < 0 {
+			return nil, fmt.Errorf("must be a non-negative float with optional M/G/T/P suffix")
+		}
+		val *= mult
+		value = uint64(math.Ceil(val))
+	}
+	return &value, nil
+}
+
+var mbSuffixes = map[string]float64{
+	"M": 1,
+	"G": 1024,
+	"T": 1024 * 1024,
+	"P": 1024 * 1024 * 1024,
+}
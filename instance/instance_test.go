@@ -0,0 +1,291 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package instance_test
+
+import (
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/instance"
+)
+
+type PortSuite struct{}
+
+var _ = Suite(&PortSuite{})
+
+var portValidateTests = []struct {
+	port   instance.Port
+	expect instance.Port
+	err    string
+}{{
+	port:   instance.Port{Protocol: "tcp", Number: 80},
+	expect: instance.Port{Protocol: "tcp", Number: 80},
+}, {
+	port:   instance.Port{Protocol: "UDP", Number: 53},
+	expect: instance.Port{Protocol: "udp", Number: 53},
+}, {
+	port:   instance.Port{Protocol: "Tcp", Number: 65535},
+	expect: instance.Port{Protocol: "tcp", Number: 65535},
+}, {
+	port: instance.Port{Protocol: "sctp", Number: 80},
+	err:  `invalid protocol "sctp", expected "tcp" or "udp"`,
+}, {
+	port: instance.Port{Protocol: "", Number: 80},
+	err:  `invalid protocol "", expected "tcp" or "udp"`,
+}, {
+	port: instance.Port{Protocol: "tcp", Number: 0},
+	err:  `port number 0 out of range \[1, 65535\]`,
+}, {
+	port: instance.Port{Protocol: "udp", Number: 65536},
+	err:  `port number 65536 out of range \[1, 65535\]`,
+}}
+
+func (*PortSuite) TestValidate(c *C) {
+	for i, t := range portValidateTests {
+		c.Logf("test %d: %v", i, t.port)
+		port := t.port
+		err := port.Validate()
+		if t.err != "" {
+			c.Assert(err, ErrorMatches, t.err)
+			c.Assert(port, Equals, t.port)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(port, Equals, t.expect)
+	}
+}
+
+type HardwareSuite struct{}
+
+var _ = Suite(&HardwareSuite{})
+
+var parseHardwareTests = []struct {
+	summary string
+	args    []string
+	err     string
+}{
+	// Simple errors.
+	{
+		summary: "nothing at all",
+	}, {
+		summary: "empty",
+		args:    []string{"     "},
+	}, {
+		summary: "complete nonsense",
+		args:    []string{"cheese"},
+		err:     `malformed characteristic "cheese"`,
+	}, {
+		summary: "missing name",
+		args:    []string{"=cheese"},
+		err:     `malformed characteristic "=cheese"`,
+	}, {
+		summary: "unknown characteristic",
+		args:    []string{"cheese=edam"},
+		err:     `unknown characteristic "cheese"`,
+	},
+
+	// "arch" in detail.
+	{
+		summary: "set arch empty",
+		args:    []string{"arch="},
+	}, {
+		summary: "set arch amd64",
+		args:    []string{"arch=amd64"},
+	}, {
+		summary: "set arch i386",
+		args:    []string{"arch=i386"},
+	}, {
+		summary: "set arch arm",
+		args:    []string{"arch=arm"},
+	}, {
+		summary: "set nonsense arch 1",
+		args:    []string{"arch=cheese"},
+		err:     `bad "arch" characteristic: "cheese" not recognized`,
+	}, {
+		summary: "double set arch together",
+		args:    []string{"arch=amd64 arch=amd64"},
+		err:     `bad "arch" characteristic: already set`,
+	}, {
+		summary: "double set arch separately",
+		args:    []string{"arch=arm", "arch="},
+		err:     `bad "arch" characteristic: already set`,
+	},
+
+	// "cpu-cores" in detail.
+	{
+		summary: "set cpu-cores empty",
+		args:    []string{"cpu-cores="},
+	}, {
+		summary: "set cpu-cores zero",
+		args:    []string{"cpu-cores=0"},
+	}, {
+		summary: "set cpu-cores",
+		args:    []string{"cpu-cores=4"},
+	}, {
+		summary: "set nonsense cpu-cores 1",
+		args:    []string{"cpu-cores=cheese"},
+		err:     `bad "cpu-cores" characteristic: must be a non-negative integer`,
+	}, {
+		summary: "set nonsense cpu-cores 2",
+		args:    []string{"cpu-cores=-1"},
+		err:     `bad "cpu-cores" characteristic: must be a non-negative integer`,
+	}, {
+		summary: "double set cpu-cores together",
+		args:    []string{"cpu-cores=128 cpu-cores=1"},
+		err:     `bad "cpu-cores" characteristic: already set`,
+	},
+
+	// "cpu-power" in detail.
+	{
+		summary: "set cpu-power empty",
+		args:    []string{"cpu-power="},
+	}, {
+		summary: "set cpu-power",
+		args:    []string{"cpu-power=44"},
+	}, {
+		summary: "set nonsense cpu-power",
+		args:    []string{"cpu-power=1.5"},
+		err:     `bad "cpu-power" characteristic: must be a non-negative integer`,
+	}, {
+		summary: "double set cpu-power separately",
+		args:    []string{"cpu-power=300", "cpu-power=1"},
+		err:     `bad "cpu-power" characteristic: already set`,
+	},
+
+	// "mem" in detail.
+	{
+		summary: "set mem empty",
+		args:    []string{"mem="},
+	}, {
+		summary: "set mem with implied megabytes",
+		args:    []string{"mem=8192"},
+	}, {
+		summary: "set mem with explicit suffix",
+		args:    []string{"mem=8G"},
+	}, {
+		summary: "set nonsense mem",
+		args:    []string{"mem=-1"},
+		err:     `bad "mem" characteristic: must be a non-negative float with optional M/G/T/P suffix`,
+	}, {
+		summary: "double set mem together",
+		args:    []string{"mem=1G mem=2G"},
+		err:     `bad "mem" characteristic: already set`,
+	},
+
+	// "root-disk" in detail.
+	{
+		summary: "set root-disk empty",
+		args:    []string{"root-disk="},
+	}, {
+		summary: "set root-disk with implied megabytes",
+		args:    []string{"root-disk=20480"},
+	}, {
+		summary: "set root-disk with explicit suffix",
+		args:    []string{"root-disk=0.5T"},
+	}, {
+		summary: "set nonsense root-disk",
+		args:    []string{"root-disk=big"},
+		err:     `bad "root-disk" characteristic: must be a non-negative float with optional M/G/T/P suffix`,
+	}, {
+		summary: "double set root-disk separately",
+		args:    []string{"root-disk=10G", "root-disk=20G"},
+		err:     `bad "root-disk" characteristic: already set`,
+	},
+
+	// Everything at once.
+	{
+		summary: "kitchen sink together",
+		args:    []string{" root-disk=4G mem=2T  arch=i386  cpu-cores=4096 cpu-power=9001"},
+	}, {
+		summary: "kitchen sink separately",
+		args:    []string{"root-disk=4G", "mem=2T", "cpu-cores=4096", "cpu-power=9001", "arch=arm"},
+	},
+}
+
+func (s *HardwareSuite) TestParseHardware(c *C) {
+	for i, t := range parseHardwareTests {
+		c.Logf("test %d: %s", i, t.summary)
+		hc, err := instance.ParseHardwareCharacteristics(t.args...)
+		if t.err == "" {
+			c.Assert(err, IsNil)
+		} else {
+			c.Assert(err, ErrorMatches, t.err)
+			continue
+		}
+		hc1, err := instance.ParseHardwareCharacteristics(hc.String())
+		c.Assert(err, IsNil)
+		c.Assert(hc1, DeepEquals, hc)
+		c.Assert(hc1.String(), Equals, hc.String())
+	}
+}
+
+func uint64p(i uint64) *uint64 {
+	return &i
+}
+
+func strp(s string) *string {
+	return &s
+}
+
+var hardwareStringTests = []struct {
+	summary string
+	hc      instance.HardwareCharacteristics
+	str     string
+}{{
+	summary: "nothing known",
+	str:     "",
+}, {
+	summary: "arch only",
+	hc:      instance.HardwareCharacteristics{Arch: strp("amd64")},
+	str:     "arch=amd64",
+}, {
+	summary: "cpu-cores only",
+	hc:      instance.HardwareCharacteristics{CpuCores: uint64p(4)},
+	str:     "cpu-cores=4",
+}, {
+	summary: "cpu-power only",
+	hc:      instance.HardwareCharacteristics{CpuPower: uint64p(100)},
+	str:     "cpu-power=100",
+}, {
+	summary: "mem only",
+	hc:      instance.HardwareCharacteristics{Mem: uint64p(8192)},
+	str:     "mem=8192M",
+}, {
+	summary: "root-disk only",
+	hc:      instance.HardwareCharacteristics{RootDisk: uint64p(20480)},
+	str:     "root-disk=20480M",
+}, {
+	summary: "zero values",
+	hc:      instance.HardwareCharacteristics{Arch: strp(""), Mem: uint64p(0)},
+	str:     "arch= mem=",
+}, {
+	summary: "partially known",
+	hc: instance.HardwareCharacteristics{
+		Arch:     strp("amd64"),
+		CpuCores: uint64p(4),
+		Mem:      uint64p(8192),
+		RootDisk: uint64p(20480),
+	},
+	str: "arch=amd64 cpu-cores=4 mem=8192M root-disk=20480M",
+}}
+
+func (s *HardwareSuite) TestString(c *C) {
+	for i, t := range hardwareStringTests {
+		c.Logf("test %d: %s", i, t.summary)
+		c.Assert(t.hc.String(), Equals, t.str)
+		hc, err := instance.ParseHardwareCharacteristics(t.str)
+		c.Assert(err, IsNil)
+		c.Assert(hc, DeepEquals, t.hc)
+	}
+}
+
+func (s *HardwareSuite) TestMustParseHardwareCharacteristics(c *C) {
+	hc := instance.MustParseHardwareCharacteristics("mem=2G root-disk=1T")
+	c.Assert(hc, DeepEquals, instance.HardwareCharacteristics{
+		Mem:      uint64p(2048),
+		RootDisk: uint64p(1024 * 1024),
+	})
+	c.Assert(func() {
+		instance.MustParseHardwareCharacteristics("cheese")
+	}, PanicMatches, `malformed characteristic "cheese"`)
+}
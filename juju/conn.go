@@ -10,9 +10,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"launchpad.net/juju-core/charm"
@@ -20,6 +24,7 @@ import (
 	"launchpad.net/juju-core/environs"
 	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/errors"
+	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/log"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/utils"
@@ -30,6 +35,11 @@ import (
 type Conn struct {
 	Environ environs.Environ
 	State   *state.State
+
+	// VerifyCharmUploads causes PutCharm to read each charm back
+	// from storage after uploading it, and to fail if its contents
+	// differ from what was written.
+	VerifyCharmUploads bool
 }
 
 var redialStrategy = utils.AttemptStrategy{
@@ -128,32 +138,124 @@ func (c *Conn) Close() error {
 	return c.State.Close()
 }
 
+// secretsStrategy governs how long updateSecrets keeps
+// trying to deliver the provider secrets.
+var secretsStrategy = utils.AttemptStrategy{
+	Total: 5 * time.Second,
+	Delay: 250 * time.Millisecond,
+}
+
+// environConfigState holds the State methods used by updateSecrets.
+type environConfigState interface {
+	EnvironConfig() (*config.Config, error)
+	SetEnvironConfig(cfg *config.Config) error
+}
+
 // updateSecrets writes secrets into the environment when there are none.
 // This is done because environments such as ec2 offer no way to securely
 // deliver the secrets onto the machine, so the bootstrap is done with the
 // whole environment configuration but without secrets, and then secrets
 // are delivered on the first communication with the running environment.
 func (c *Conn) updateSecrets() error {
-	secrets, err := c.Environ.Provider().SecretAttrs(c.Environ.Config())
-	if err != nil {
-		return err
-	}
-	cfg, err := c.State.EnvironConfig()
+	return updateSecrets(c.Environ, c.State)
+}
+
+func updateSecrets(environ environs.Environ, st environConfigState) error {
+	secrets, err := environ.Provider().SecretAttrs(environ.Config())
 	if err != nil {
 		return err
 	}
-	attrs := cfg.AllAttrs()
-	for k := range secrets {
-		if _, exists := attrs[k]; exists {
-			// Environment already has secrets. Won't send again.
+	var writeErr error
+	for a := secretsStrategy.Start(); a.Next(); {
+		// The configuration is read afresh on every attempt, so
+		// that secrets delivered by another connection in the
+		// meantime are not overwritten.
+		cfg, err := st.EnvironConfig()
+		if err != nil {
+			return err
+		}
+		attrs := cfg.AllAttrs()
+		for k := range secrets {
+			if _, exists := attrs[k]; exists {
+				// Environment already has secrets. Won't send again.
+				return nil
+			}
+		}
+		cfg, err = cfg.Apply(secrets)
+		if err != nil {
+			return err
+		}
+		if writeErr = st.SetEnvironConfig(cfg); writeErr == nil {
 			return nil
 		}
+		if !isTransientSecretsError(writeErr) {
+			return writeErr
+		}
+		log.Warningf("juju: cannot push secrets (will retry): %v", writeErr)
+	}
+	return writeErr
+}
+
+// isTransientSecretsError reports whether an error returned when
+// writing the secrets might not recur if the write is retried: that
+// is, whether it was caused by a lost connection to the state server
+// or by concurrent changes to the configuration. Other errors, such as
+// the configuration being rejected, will recur.
+func isTransientSecretsError(err error) bool {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF, state.ErrExcessiveContention:
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// ResolveCharm returns the fully qualified URL of the charm that ref
+// refers to. If ref does not specify a series, the environment's
+// default series is used; if it does not specify a revision, the
+// latest revision in the charm's repository is used; if it does, the
+// repository must hold that revision. Local charms are looked for in
+// the repository at repoPath. A charm that cannot be found in its
+// repository causes a NotFoundError.
+func (conn *Conn) ResolveCharm(ref, repoPath string) (*charm.URL, error) {
+	cfg, err := conn.State.EnvironConfig()
+	if err != nil {
+		return nil, err
 	}
-	cfg, err = cfg.Apply(secrets)
+	curl, err := charm.InferURL(ref, cfg.DefaultSeries())
 	if err != nil {
+		return nil, err
+	}
+	repo, err := charm.InferRepository(curl, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if curl.Revision != -1 {
+		err = checkCharmRevision(repo, curl)
+	} else {
+		var rev int
+		if rev, err = repo.Latest(curl); err == nil {
+			curl = curl.WithRevision(rev)
+		}
+	}
+	if _, ok := err.(*charm.NotFoundError); ok {
+		return nil, &errors.NotFoundError{err, fmt.Sprintf("cannot resolve charm %q", ref)}
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot resolve charm %q: %v", ref, err)
+	}
+	return curl, nil
+}
+
+// checkCharmRevision returns an error if repo does not hold the charm
+// revision named by curl. The charm store is asked for the charm's
+// details rather than the charm itself, to avoid downloading it.
+func checkCharmRevision(repo charm.Repository, curl *charm.URL) error {
+	if store, ok := repo.(*charm.CharmStore); ok {
+		_, err := store.Info(curl)
 		return err
 	}
-	return c.State.SetEnvironConfig(cfg)
+	_, err := repo.Get(curl)
+	return err
 }
 
 // PutCharm uploads the given charm to provider storage, and adds a
@@ -189,6 +291,174 @@ func (conn *Conn) PutCharm(curl *charm.URL, repo charm.Repository, bumpRevision
 	return conn.addCharm(curl, ch)
 }
 
+// maxBundleSize and bundleDownloadTimeout limit the size of the
+// charm bundles downloaded by PutCharmFromURL, and the time
+// taken to download them.
+var (
+	maxBundleSize         int64 = 100 * 1024 * 1024
+	bundleDownloadTimeout       = 5 * time.Minute
+)
+
+// PutCharmFromURL downloads the charm bundle at bundleURL, which must
+// be an http or https URL, and adds it to state as curl, uploading it
+// to provider storage as PutCharm does. If curl does not specify a
+// revision, the bundle's own revision is used.
+func (conn *Conn) PutCharmFromURL(curl *charm.URL, bundleURL string) (*state.Charm, error) {
+	path, err := downloadBundle(bundleURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download charm bundle from %q: %v", bundleURL, err)
+	}
+	defer os.Remove(path)
+	bundle, err := charm.ReadBundle(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read charm bundle from %q: %v", bundleURL, err)
+	}
+	if curl.Revision == -1 {
+		curl = curl.WithRevision(bundle.Revision())
+	}
+	if sch, err := conn.State.Charm(curl); err == nil {
+		return sch, nil
+	}
+	return conn.addCharm(curl, bundle)
+}
+
+// downloadBundle fetches the contents of bundleURL into a temporary
+// file and returns its path.
+func downloadBundle(bundleURL string) (path string, err error) {
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	f, err := ioutil.TempFile("", "charm-bundle")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	err = httpGet(bundleURL, bundleDownloadTimeout, func(resp *http.Response) error {
+		if resp.ContentLength > maxBundleSize {
+			return fmt.Errorf("bundle larger than %d bytes", maxBundleSize)
+		}
+		size, err := io.Copy(f, io.LimitReader(resp.Body, maxBundleSize+1))
+		if err != nil {
+			return err
+		}
+		if size > maxBundleSize {
+			return fmt.Errorf("bundle larger than %d bytes", maxBundleSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// httpTransport is shared by the HTTP requests made by Conn, so that
+// they can reuse its connections.
+var httpTransport = &http.Transport{Proxy: http.ProxyFromEnvironment}
+
+// httpGet fetches the object at the given URL and, if the response
+// status is 200, passes the response to read. It gives up if fetching
+// and reading take longer than timeout.
+func httpGet(ustr string, timeout time.Duration, read func(resp *http.Response) error) error {
+	req, err := http.NewRequest("GET", ustr, nil)
+	if err != nil {
+		return err
+	}
+	// Give up on slow requests by cancelling them while waiting for
+	// the response, and by closing the body under the reader after.
+	var mu sync.Mutex
+	var body io.Closer
+	timer := time.AfterFunc(timeout, func() {
+		httpTransport.CancelRequest(req)
+		mu.Lock()
+		defer mu.Unlock()
+		if body != nil {
+			body.Close()
+		}
+	})
+	defer timer.Stop()
+	client := &http.Client{Transport: httpTransport}
+	resp, err := client.Do(req)
+	if err == nil {
+		mu.Lock()
+		body = resp.Body
+		mu.Unlock()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("bad http response: %v", resp.Status)
+		}
+		err = read(resp)
+	}
+	if err != nil && !timer.Stop() {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	return err
+}
+
+// UpgradeCharm uploads the charm identified by curl from repo and
+// switches the named service to it. If curl does not specify a revision,
+// the latest one available in repo is used. If that turns out to be the
+// charm the service is already running and the charm is a local
+// directory, its revision is incremented before uploading (see PutCharm).
+// Unless force is true, the upgrade is refused when any of the service's
+// current settings would not be valid for the new charm. Force also
+// upgrades units that are in an error state. The upgrade is always
+// refused if the new charm would break any of the service's relations.
+func (conn *Conn) UpgradeCharm(serviceName string, curl *charm.URL, repo charm.Repository, force bool) error {
+	service, err := conn.State.Service(serviceName)
+	if err != nil {
+		return err
+	}
+	oldURL, _ := service.CharmURL()
+	explicitRevision := curl.Revision != -1
+	if !explicitRevision {
+		rev, err := repo.Latest(curl)
+		if err != nil {
+			return fmt.Errorf("cannot get latest charm revision: %v", err)
+		}
+		curl = curl.WithRevision(rev)
+	}
+	bumpRevision := false
+	if *curl == *oldURL {
+		if explicitRevision {
+			return fmt.Errorf("already running specified charm %q", curl)
+		}
+		ch, err := repo.Get(curl)
+		if err != nil {
+			return fmt.Errorf("cannot get charm: %v", err)
+		}
+		if _, bumpRevision = ch.(*charm.Dir); !bumpRevision {
+			return fmt.Errorf("already running latest charm %q", curl)
+		}
+	}
+	sch, err := conn.PutCharm(curl, repo, bumpRevision)
+	if err != nil {
+		return err
+	}
+	if !force {
+		settings, err := service.ConfigSettings()
+		if err != nil {
+			return err
+		}
+		valid := sch.Config().FilterSettings(settings)
+		for name := range settings {
+			if _, ok := valid[name]; !ok {
+				return fmt.Errorf("cannot upgrade service %q to charm %q: setting %q is not valid for the new charm", serviceName, sch.URL(), name)
+			}
+		}
+	}
+	return service.SetCharm(sch, force)
+}
+
 // DeployServiceParams contains the arguments required to deploy the referenced
 // charm.
 type DeployServiceParams struct {
@@ -236,13 +506,28 @@ func (conn *Conn) DeployService(args DeployServiceParams) (*state.Service, error
 		}
 	}
 	if args.NumUnits > 0 {
-		if _, err := conn.AddUnits(service, args.NumUnits, args.ForceMachineId); err != nil {
+		if _, err := conn.AddUnits(service, args.NumUnits, state.AssignNew, args.ForceMachineId); err != nil {
 			return nil, err
 		}
 	}
 	return service, nil
 }
 
+// GetCharmInfo returns the metadata and configuration schema of the
+// charm currently used by the named service, as recorded in state.
+func (conn *Conn) GetCharmInfo(serviceName string) (*charm.Meta, *charm.Config, error) {
+	svc, err := conn.State.Service(serviceName)
+	if err != nil {
+		return nil, nil, err
+	}
+	curl, _ := svc.CharmURL()
+	ch, err := conn.State.Charm(curl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ch.Meta(), ch.Config(), nil
+}
+
 func (conn *Conn) addCharm(curl *charm.URL, ch charm.Charm) (*state.Charm, error) {
 	var f *os.File
 	name := charm.Quote(curl.String())
@@ -288,6 +573,14 @@ func (conn *Conn) addCharm(curl *charm.URL, ch charm.Charm) (*state.Charm, error
 	if err != nil {
 		return nil, fmt.Errorf("cannot get storage URL for charm: %v", err)
 	}
+	if conn.VerifyCharmUploads {
+		if err := verifyUpload(ustr, digest); err != nil {
+			if err := storage.Remove(name); err != nil {
+				log.Warningf("juju: cannot remove charm %q from storage: %v", name, err)
+			}
+			return nil, fmt.Errorf("cannot verify uploaded charm: %v", err)
+		}
+	}
 	u, err := url.Parse(ustr)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse storage URL: %v", err)
@@ -300,40 +593,345 @@ func (conn *Conn) addCharm(curl *charm.URL, ch charm.Charm) (*state.Charm, error
 	return sch, nil
 }
 
+// verifyUploadTimeout limits the time taken by verifyUpload to
+// fetch an uploaded charm.
+var verifyUploadTimeout = time.Minute
+
+// verifyUpload fetches the stored object at the given URL and
+// checks that its sha256 digest matches the expected one.
+func verifyUpload(ustr, expectDigest string) error {
+	h := sha256.New()
+	err := httpGet(ustr, verifyUploadTimeout, func(resp *http.Response) error {
+		_, err := io.Copy(h, resp.Body)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != expectDigest {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", expectDigest, digest)
+	}
+	return nil
+}
+
 // AddUnits starts n units of the given service and allocates machines
-// to them as necessary.
-func (conn *Conn) AddUnits(svc *state.Service, n int, mid string) ([]*state.Unit, error) {
-	units := make([]*state.Unit, n)
-	// Hard code for now till we implement a constraints based approach.
-	// We currently only support AssignNew.
-	policy := state.AssignNew
-	// TODO what do we do if we fail half-way through this process?
+// to them as necessary. If mid is not empty, n must be 1, and the single
+// unit is assigned to the machine or container it names (see
+// assignToTarget); otherwise each unit is placed according to policy.
+// If any unit cannot be added, the units already added, and any
+// machines created for them, are removed again before the error is
+// returned.
+func (conn *Conn) AddUnits(svc *state.Service, n int, policy state.AssignmentPolicy, mid string) ([]*state.Unit, error) {
+	if mid != "" && n != 1 {
+		return nil, fmt.Errorf("cannot add multiple units of service %q to a single machine", svc.Name())
+	}
+	units := make([]*state.Unit, 0, n)
+	var machineIds []string
 	for i := 0; i < n; i++ {
 		unit, err := svc.AddUnit()
 		if err != nil {
+			conn.rollbackUnits(units, machineIds)
 			return nil, fmt.Errorf("cannot add unit %d/%d to service %q: %v", i+1, n, svc.Name(), err)
 		}
+		units = append(units, unit)
+		var created []string
 		if mid != "" {
-			if n != 1 {
-				return nil, fmt.Errorf("cannot add multiple units of service %q to a single machine", svc.Name())
-			}
-			m, err := conn.State.Machine(mid)
-			if err != nil {
-				return nil, fmt.Errorf("cannot assign unit %q to machine: %v", unit.Name(), err)
-			}
-			err = unit.AssignToMachine(m)
+			created, err = conn.assignToTarget(svc, unit, mid)
+		} else {
+			created, err = assignUnit(conn, svc, unit, policy)
+		}
+		machineIds = append(machineIds, created...)
+		if err != nil {
+			conn.rollbackUnits(units, machineIds)
+			return nil, err
+		}
+	}
+	return units, nil
+}
 
-			if err != nil {
-				return nil, err
+// rollbackUnits destroys the given units, which have not yet been
+// deployed, and then removes the machines with the given ids, which
+// were created for them, logging rather than returning any errors.
+// Hosts must come before the containers created on them. A machine
+// that has been provisioned in the meantime is destroyed instead, so
+// that its instance is stopped.
+func (conn *Conn) rollbackUnits(units []*state.Unit, machineIds []string) {
+	for _, unit := range units {
+		if err := unit.Destroy(); err != nil {
+			log.Warningf("juju: cannot destroy unit %q: %v", unit.Name(), err)
+		}
+	}
+	for i := len(machineIds) - 1; i >= 0; i-- {
+		m, err := conn.State.Machine(machineIds[i])
+		if errors.IsNotFoundError(err) {
+			continue
+		} else if err != nil {
+			log.Warningf("juju: cannot remove machine %s: %v", machineIds[i], err)
+			continue
+		}
+		if _, err = m.InstanceId(); err == nil {
+			err = m.Destroy()
+		} else if state.IsNotProvisionedError(err) {
+			if err = m.EnsureDead(); err == nil {
+				err = m.Remove()
 			}
-		} else if err := conn.State.AssignUnit(unit, policy); err != nil {
+		}
+		if err != nil {
+			log.Warningf("juju: cannot remove machine %s: %v", m, err)
+		}
+	}
+}
+
+// newMachineIds returns the ids of m and, if it is a container whose
+// host was created with it, of its host, host first.
+func newMachineIds(m *state.Machine, newHost bool) []string {
+	if parentId, ok := m.ParentId(); ok && newHost {
+		return []string{parentId, m.Id()}
+	}
+	return []string{m.Id()}
+}
+
+// AddUnitsWithConstraints starts n units of the given service, each on
+// a new machine provisioned according to cons, with any values not set
+// in cons taken from the service's constraints. If any unit cannot be
+// added, the units already added, and the machines created for them,
+// are removed again before the error is returned.
+func (conn *Conn) AddUnitsWithConstraints(svc *state.Service, n int, cons constraints.Value) ([]*state.Unit, error) {
+	if !svc.IsPrincipal() {
+		return nil, state.ErrSubordinateConstraints
+	}
+	scons, err := svc.Constraints()
+	if err != nil {
+		return nil, err
+	}
+	cons = cons.WithFallbacks(scons)
+	var ctype instance.ContainerType
+	if cons.Container != nil && *cons.Container != instance.NONE {
+		ctype = *cons.Container
+		if err := environs.CheckContainerType(conn.Environ, ctype); err != nil {
+			return nil, err
+		}
+	}
+	curl, _ := svc.CharmURL()
+	units := make([]*state.Unit, 0, n)
+	var machineIds []string
+	for i := 0; i < n; i++ {
+		unit, err := svc.AddUnit()
+		if err != nil {
+			conn.rollbackUnits(units, machineIds)
+			return nil, fmt.Errorf("cannot add unit %d/%d to service %q: %v", i+1, n, svc.Name(), err)
+		}
+		units = append(units, unit)
+		m, err := conn.State.AddMachineWithConstraints(&state.AddMachineParams{
+			Series:        curl.Series,
+			ContainerType: ctype,
+			Constraints:   cons,
+			Jobs:          []state.MachineJob{state.JobHostUnits},
+		})
+		if err != nil {
+			conn.rollbackUnits(units, machineIds)
+			return nil, fmt.Errorf("cannot assign unit %q to machine: %v", unit.Name(), err)
+		}
+		machineIds = append(machineIds, newMachineIds(m, true)...)
+		if err := unit.AssignToMachine(m); err != nil {
+			conn.rollbackUnits(units, machineIds)
 			return nil, err
 		}
-		units[i] = unit
 	}
 	return units, nil
 }
 
+// assignToTarget assigns the unit to the machine named by target. The
+// target is either the id of an existing machine or container, such as
+// "0" or "0/lxc/0", or takes the form "<machine>/<container-type>" (or
+// "/<container-type>" for a new machine), as accepted by add-machine,
+// in which case a new container is created to host the unit. It
+// returns the ids of any machines it created, hosts first, even if
+// the assignment fails.
+func (conn *Conn) assignToTarget(svc *state.Service, unit *state.Unit, target string) (created []string, err error) {
+	if !state.IsMachineId(target) {
+		m, err := conn.addContainer(svc, target)
+		if err != nil {
+			return nil, fmt.Errorf("cannot assign unit %q to machine: %v", unit.Name(), err)
+		}
+		created = newMachineIds(m, strings.HasPrefix(target, "/"))
+		target = m.Id()
+	}
+	m, err := conn.State.Machine(target)
+	if err != nil {
+		return created, fmt.Errorf("cannot assign unit %q to machine: %v", unit.Name(), err)
+	}
+	return created, unit.AssignToMachine(m)
+}
+
+// addContainer creates a new container for a unit of svc, as
+// described by the container spec.
+func (conn *Conn) addContainer(svc *state.Service, spec string) (*state.Machine, error) {
+	sep := strings.LastIndex(spec, "/")
+	if sep < 0 {
+		return nil, fmt.Errorf("invalid machine id %q", spec)
+	}
+	parentId := spec[:sep]
+	if parentId != "" {
+		if !state.IsMachineId(parentId) {
+			return nil, fmt.Errorf("invalid machine id %q", parentId)
+		}
+		if _, err := conn.State.Machine(parentId); err != nil {
+			return nil, err
+		}
+	}
+	ctype, err := instance.ParseSupportedContainerType(spec[sep+1:])
+	if err != nil {
+		return nil, err
+	}
+	if err := environs.CheckContainerType(conn.Environ, ctype); err != nil {
+		return nil, err
+	}
+	cons, err := svc.Constraints()
+	if err != nil {
+		return nil, err
+	}
+	curl, _ := svc.CharmURL()
+	params := state.AddMachineParams{
+		ParentId:      parentId,
+		ContainerType: ctype,
+		Series:        curl.Series,
+		Constraints:   cons,
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	}
+	return conn.State.AddMachineWithConstraints(&params)
+}
+
+// assignUnit is called by AddUnits to assign each unit according to
+// its policy. It is a variable so that tests can make it fail.
+var assignUnit = (*Conn).assignUnit
+
+// assignUnit places the unit on a machine according to policy. For the
+// AssignClean and AssignCleanEmpty policies, an existing clean machine
+// whose known hardware satisfies the service constraints is preferred;
+// a new machine is launched only when no such machine exists. It
+// returns the id of the machine it created, if any.
+func (conn *Conn) assignUnit(svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) (created []string, err error) {
+	if policy != state.AssignClean && policy != state.AssignCleanEmpty {
+		if err := conn.State.AssignUnit(unit, policy); err != nil {
+			return nil, err
+		}
+		if policy != state.AssignNew {
+			return nil, nil
+		}
+		return assignedMachineIds(unit)
+	}
+	scons, err := svc.Constraints()
+	if err != nil {
+		return nil, err
+	}
+	econs, err := conn.State.EnvironConstraints()
+	if err != nil {
+		return nil, err
+	}
+	cons := scons.WithFallbacks(econs)
+	curl, _ := svc.CharmURL()
+	machines, err := conn.State.AllMachines()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range machines {
+		ok, err := machineMatches(m, curl.Series, cons, policy == state.AssignCleanEmpty)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		// The machine may have been taken by a concurrent assignment
+		// since we looked at it, in which case we try the next one.
+		if err := unit.AssignToUnusedMachine(m); err != nil {
+			log.Debugf("juju: cannot assign unit %q to machine %v: %v", unit.Name(), m, err)
+			continue
+		}
+		return nil, nil
+	}
+	if err := conn.State.AssignUnit(unit, state.AssignNew); err != nil {
+		return nil, err
+	}
+	return assignedMachineIds(unit)
+}
+
+// assignedMachineIds returns the id of the machine the unit is
+// assigned to, as the only element of a slice.
+func assignedMachineIds(unit *state.Unit) ([]string, error) {
+	id, err := unit.AssignedMachineId()
+	if err != nil {
+		return nil, err
+	}
+	return []string{id}, nil
+}
+
+// machineMatches reports whether m is an alive, clean machine of the given
+// series that can host units and whose hardware satisfies cons. If
+// requireEmpty is true, the machine must also not host any containers.
+func machineMatches(m *state.Machine, series string, cons constraints.Value, requireEmpty bool) (bool, error) {
+	if m.Life() != state.Alive || !m.Clean() || m.Series() != series {
+		return false, nil
+	}
+	canHostUnits := false
+	for _, job := range m.Jobs() {
+		if job == state.JobHostUnits {
+			canHostUnits = true
+			break
+		}
+	}
+	if !canHostUnits {
+		return false, nil
+	}
+	if requireEmpty {
+		containers, err := m.Containers()
+		if err != nil {
+			return false, err
+		}
+		if len(containers) > 0 {
+			return false, nil
+		}
+	}
+	if cons.Container != nil {
+		ctype := m.ContainerType()
+		if ctype == "" {
+			ctype = instance.NONE
+		}
+		if ctype != *cons.Container {
+			return false, nil
+		}
+	}
+	if cons.Arch == nil && cons.CpuCores == nil && cons.CpuPower == nil && cons.Mem == nil {
+		return true, nil
+	}
+	hc, err := m.HardwareCharacteristics()
+	if errors.IsNotFoundError(err) {
+		// The machine has not been provisioned yet, so we
+		// cannot know whether it satisfies the constraints.
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if cons.Arch != nil && *cons.Arch != "" {
+		if hc.Arch == nil || *hc.Arch != *cons.Arch {
+			return false, nil
+		}
+	}
+	return atLeast(hc.CpuCores, cons.CpuCores) &&
+		atLeast(hc.CpuPower, cons.CpuPower) &&
+		atLeast(hc.Mem, cons.Mem), nil
+}
+
+// atLeast reports whether have is known and no less than want.
+// An unset want is always satisfied.
+func atLeast(have, want *uint64) bool {
+	if want == nil || *want == 0 {
+		return true
+	}
+	return have != nil && *have >= *want
+}
+
 // InitJujuHome initializes the charm and environs/config packages to use
 // default paths based on the $JUJU_HOME or $HOME environment variables.
 // This function should be called before calling NewConn or Conn.Deploy.
@@ -346,7 +944,19 @@ func InitJujuHome() error {
 		}
 		jujuHome = filepath.Join(home, ".juju")
 	}
+	return InitJujuHomePath(jujuHome)
+}
+
+// InitJujuHomePath initializes the charm and environs/config packages
+// to use paths based on the given juju home directory, without
+// consulting the environment. The charm cache directory is created if
+// it does not exist.
+func InitJujuHomePath(jujuHome string) error {
+	cacheDir := filepath.Join(jujuHome, "charmcache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return fmt.Errorf("cannot create charm cache directory: %v", err)
+	}
 	config.SetJujuHome(jujuHome)
-	charm.CacheDir = filepath.Join(jujuHome, "charmcache")
+	charm.CacheDir = cacheDir
 	return nil
 }
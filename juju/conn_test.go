@@ -4,10 +4,19 @@
 package juju_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	stdtesting "testing"
+	"time"
 
 	. "launchpad.net/gocheck"
 
@@ -17,6 +26,7 @@ import (
 	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/environs/dummy"
 	"launchpad.net/juju-core/errors"
+	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/juju/testing"
 	"launchpad.net/juju-core/state"
@@ -187,6 +197,95 @@ func (cs *NewConnSuite) TestConnStateDoesNotUpdateExistingSecrets(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// flakyConfigState is a stand-in for *state.State whose first
+// failures calls to SetEnvironConfig fail with err.
+type flakyConfigState struct {
+	cfg      *config.Config
+	err      error
+	failures int
+	reads    int
+	writes   int
+}
+
+func (st *flakyConfigState) EnvironConfig() (*config.Config, error) {
+	st.reads++
+	return st.cfg, nil
+}
+
+func (st *flakyConfigState) SetEnvironConfig(cfg *config.Config) error {
+	st.writes++
+	if st.writes <= st.failures {
+		return st.err
+	}
+	st.cfg = cfg
+	return nil
+}
+
+// newSecretsEnviron returns an environment with secrets, and the
+// configuration held by its state before the secrets are delivered.
+func newSecretsEnviron(c *C) (environs.Environ, *config.Config) {
+	attrs := map[string]interface{}{
+		"name":            "erewhemos",
+		"type":            "dummy",
+		"state-server":    true,
+		"authorized-keys": "i-am-a-key",
+		"secret":          "pork",
+		"admin-secret":    "some secret",
+		"ca-cert":         coretesting.CACert,
+		"ca-private-key":  coretesting.CAKey,
+	}
+	env, err := environs.NewFromAttrs(attrs)
+	c.Assert(err, IsNil)
+	delete(attrs, "secret")
+	cfg, err := config.New(attrs)
+	c.Assert(err, IsNil)
+	return env, cfg
+}
+
+func (cs *NewConnSuite) TestUpdateSecretsRetries(c *C) {
+	defer juju.SetSecretsStrategy(juju.SetSecretsStrategy(utils.AttemptStrategy{
+		Total: coretesting.LongWait,
+		Delay: time.Millisecond,
+	}))
+	env, cfg := newSecretsEnviron(c)
+
+	st := &flakyConfigState{cfg: cfg, err: io.EOF, failures: 1}
+	err := juju.UpdateSecrets(env, st)
+	c.Assert(err, IsNil)
+	c.Assert(st.writes, Equals, 2)
+	// The configuration is re-read before every attempt.
+	c.Assert(st.reads, Equals, 2)
+	c.Assert(st.cfg.UnknownAttrs()["secret"], Equals, "pork")
+}
+
+func (cs *NewConnSuite) TestUpdateSecretsGivesUp(c *C) {
+	defer juju.SetSecretsStrategy(juju.SetSecretsStrategy(utils.AttemptStrategy{
+		Total: 10 * time.Millisecond,
+		Delay: time.Millisecond,
+	}))
+	env, cfg := newSecretsEnviron(c)
+
+	st := &flakyConfigState{cfg: cfg, err: state.ErrExcessiveContention, failures: 1 << 20}
+	err := juju.UpdateSecrets(env, st)
+	c.Assert(err, Equals, state.ErrExcessiveContention)
+	c.Assert(st.writes > 1, Equals, true)
+	c.Assert(st.cfg.UnknownAttrs()["secret"], IsNil)
+}
+
+func (cs *NewConnSuite) TestUpdateSecretsDoesNotRetryPermanentErrors(c *C) {
+	defer juju.SetSecretsStrategy(juju.SetSecretsStrategy(utils.AttemptStrategy{
+		Total: coretesting.LongWait,
+		Delay: time.Millisecond,
+	}))
+	env, cfg := newSecretsEnviron(c)
+
+	st := &flakyConfigState{cfg: cfg, err: fmt.Errorf("configuration rejected"), failures: 1}
+	err := juju.UpdateSecrets(env, st)
+	c.Assert(err, ErrorMatches, "configuration rejected")
+	c.Assert(st.writes, Equals, 1)
+	c.Assert(st.cfg.UnknownAttrs()["secret"], IsNil)
+}
+
 func (cs *NewConnSuite) TestConnWithPassword(c *C) {
 	env, err := environs.NewFromAttrs(map[string]interface{}{
 		"name":            "erewhemos",
@@ -295,6 +394,50 @@ func (s *ConnSuite) TestNewConnFromState(c *C) {
 	c.Assert(conn.Environ.Name(), Equals, "erewhemos")
 }
 
+func (s *ConnSuite) TestResolveCharm(c *C) {
+	coretesting.Charms.ClonedURL(s.repo.Path, config.DefaultSeries, "riak")
+	coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
+	for i, t := range []struct {
+		ref string
+		url string
+		err string
+	}{{
+		ref: "local:riak",
+		url: "local:" + config.DefaultSeries + "/riak-7",
+	}, {
+		ref: "local:series/riak",
+		url: "local:series/riak-7",
+	}, {
+		ref: "local:series/riak-7",
+		url: "local:series/riak-7",
+	}, {
+		ref: "local:series/riak-3",
+		err: `cannot resolve charm "local:series/riak-3": charm not found in ".*": local:series/riak-3`,
+	}, {
+		ref: "local:series/nonexistent",
+		err: `cannot resolve charm "local:series/nonexistent": charm not found in ".*": local:series/nonexistent`,
+	}, {
+		ref: "local:otherseries/riak",
+		err: `cannot resolve charm "local:otherseries/riak": charm not found in ".*": local:otherseries/riak`,
+	}, {
+		ref: "~user/riak",
+		err: `cannot infer charm URL with user but no schema: "~user/riak"`,
+	}} {
+		c.Logf("test %d: %s", i, t.ref)
+		curl, err := s.conn.ResolveCharm(t.ref, s.repo.Path)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err)
+			c.Check(curl, IsNil)
+			if strings.Contains(t.err, "not found") {
+				c.Check(errors.IsNotFoundError(err), Equals, true)
+			}
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Check(curl.String(), Equals, t.url)
+	}
+}
+
 func (s *ConnSuite) TestPutCharmBasic(c *C) {
 	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
 	curl.Revision = -1 // make sure we trigger the repo.Latest logic.
@@ -340,6 +483,94 @@ func (s *ConnSuite) TestPutBundledCharm(c *C) {
 	c.Assert(sch.Meta().Summary, Equals, "K/V storage engine")
 }
 
+func (s *ConnSuite) TestPutCharmFromURL(c *C) {
+	data, err := ioutil.ReadFile(coretesting.Charms.BundlePath(c.MkDir(), "dummy"))
+	c.Assert(err, IsNil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	curl := charm.MustParseURL("cs:series/dummy")
+	sch, err := s.conn.PutCharmFromURL(curl, srv.URL+"/dummy.charm")
+	c.Assert(err, IsNil)
+	c.Assert(sch.URL().String(), Equals, "cs:series/dummy-1")
+	c.Assert(sch.Meta().Name, Equals, "dummy")
+	h := sha256.New()
+	h.Write(data)
+	c.Assert(sch.BundleSha256(), Equals, hex.EncodeToString(h.Sum(nil)))
+
+	// The bundle was uploaded to storage unchanged.
+	r, err := s.conn.Environ.Storage().Get(charm.Quote(sch.URL().String()))
+	c.Assert(err, IsNil)
+	defer r.Close()
+	stored, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(stored, data), Equals, true)
+}
+
+func (s *ConnSuite) TestVerifyUploadTimesOut(c *C) {
+	defer juju.SetVerifyUploadTimeout(50 * time.Millisecond)()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-body" {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	err := juju.VerifyUpload(srv.URL+"/slow-body", "digest")
+	c.Assert(err, ErrorMatches, "timed out after 50ms")
+	err = juju.VerifyUpload(srv.URL+"/slow-headers", "digest")
+	c.Assert(err, NotNil)
+}
+
+func (s *ConnSuite) TestPutCharmFromURLErrors(c *C) {
+	defer juju.SetBundleLimits(100, 50*time.Millisecond)()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Write(make([]byte, 101))
+		case "/slow":
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+		case "/garbage":
+			w.Write([]byte("not a bundle"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for i, t := range []struct {
+		url string
+		err string
+	}{{
+		url: "ftp://example.com/dummy.charm",
+		err: `cannot download charm bundle from "ftp://example.com/dummy.charm": unsupported URL scheme "ftp"`,
+	}, {
+		url: srv.URL + "/missing",
+		err: `cannot download charm bundle from ".*/missing": bad http response: 404 Not Found`,
+	}, {
+		url: srv.URL + "/large",
+		err: `cannot download charm bundle from ".*/large": bundle larger than 100 bytes`,
+	}, {
+		url: srv.URL + "/slow",
+		err: `cannot download charm bundle from ".*/slow": timed out after 50ms`,
+	}, {
+		url: srv.URL + "/garbage",
+		err: `cannot read charm bundle from ".*/garbage": .*`,
+	}} {
+		c.Logf("test %d: %s", i, t.url)
+		_, err := s.conn.PutCharmFromURL(charm.MustParseURL("cs:series/dummy"), t.url)
+		c.Check(err, ErrorMatches, t.err)
+	}
+	_, err := s.conn.State.Charm(charm.MustParseURL("cs:series/dummy-1"))
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
 func (s *ConnSuite) TestPutCharmUpload(c *C) {
 	repo := &charm.LocalRepository{c.MkDir()}
 	curl := coretesting.Charms.ClonedURL(repo.Path, "series", "riak")
@@ -381,13 +612,68 @@ func (s *ConnSuite) TestPutCharmUpload(c *C) {
 	c.Assert(sch.Revision(), Equals, rev+1)
 }
 
+// corruptingEnviron wraps an Environ so that every file
+// put into its storage has its contents altered.
+type corruptingEnviron struct {
+	environs.Environ
+}
+
+func (e corruptingEnviron) Storage() environs.Storage {
+	return corruptingStorage{e.Environ.Storage()}
+}
+
+type corruptingStorage struct {
+	environs.Storage
+}
+
+func (s corruptingStorage) Put(name string, r io.Reader, length int64) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data[len(data)/2] ^= 0xff
+	return s.Storage.Put(name, bytes.NewReader(data), length)
+}
+
+func (s *ConnSuite) TestPutCharmVerifyUpload(c *C) {
+	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
+	conn := &juju.Conn{
+		Environ:            s.conn.Environ,
+		State:              s.conn.State,
+		VerifyCharmUploads: true,
+	}
+	sch, err := conn.PutCharm(curl, s.repo, false)
+	c.Assert(err, IsNil)
+	c.Assert(sch.URL().String(), Equals, "local:series/riak-7")
+}
+
+func (s *ConnSuite) TestPutCharmVerifyUploadCorrupted(c *C) {
+	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
+	conn := &juju.Conn{
+		Environ:            corruptingEnviron{s.conn.Environ},
+		State:              s.conn.State,
+		VerifyCharmUploads: true,
+	}
+	_, err := conn.PutCharm(curl, s.repo, false)
+	c.Assert(err, ErrorMatches, "cannot verify uploaded charm: sha256 mismatch: expected [0-9a-f]+, got [0-9a-f]+")
+
+	// Neither the bad object nor the charm remain.
+	names, err := s.conn.Environ.Storage().List("")
+	c.Assert(err, IsNil)
+	for _, name := range names {
+		c.Assert(name, Not(Matches), ".*riak.*")
+	}
+	_, err = s.conn.State.Charm(charm.MustParseURL("local:series/riak-7"))
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
 func (s *ConnSuite) TestAddUnits(c *C) {
 	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
 	sch, err := s.conn.PutCharm(curl, s.repo, false)
 	c.Assert(err, IsNil)
 	svc, err := s.conn.State.AddService("testriak", sch)
 	c.Assert(err, IsNil)
-	units, err := s.conn.AddUnits(svc, 2, "")
+	units, err := s.conn.AddUnits(svc, 2, state.AssignNew, "")
 	c.Assert(err, IsNil)
 	c.Assert(units, HasLen, 2)
 
@@ -397,16 +683,386 @@ func (s *ConnSuite) TestAddUnits(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(id0, Not(Equals), id1)
 
-	units, err = s.conn.AddUnits(svc, 2, "0")
+	units, err = s.conn.AddUnits(svc, 2, state.AssignNew, "0")
 	c.Assert(err, ErrorMatches, `cannot add multiple units of service "testriak" to a single machine`)
 
-	units, err = s.conn.AddUnits(svc, 1, "0")
+	units, err = s.conn.AddUnits(svc, 1, state.AssignNew, "0")
 	c.Assert(err, IsNil)
 	id2, err := units[0].AssignedMachineId()
 	c.Assert(id2, Equals, id0)
 
 }
 
+func (s *ConnSuite) TestAddUnitsRollsBack(c *C) {
+	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
+	sch, err := s.conn.PutCharm(curl, s.repo, false)
+	c.Assert(err, IsNil)
+	svc, err := s.conn.State.AddService("testriak", sch)
+	c.Assert(err, IsNil)
+
+	// There is no machine 0 for the units to be assigned to.
+	units, err := s.conn.AddUnits(svc, 3, state.AssignLocal, "")
+	c.Assert(err, ErrorMatches, `cannot assign unit "testriak/0" to machine: machine 0 not found`)
+	c.Assert(units, IsNil)
+	units, err = svc.AllUnits()
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 0)
+
+	units, err = s.conn.AddUnits(svc, 1, state.AssignNew, "42")
+	c.Assert(err, ErrorMatches, `.*machine 42 not found`)
+	c.Assert(units, IsNil)
+	units, err = svc.AllUnits()
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 0)
+
+	// Once machine 0 exists, the units can be added.
+	_, err = s.conn.AddUnits(svc, 1, state.AssignNew, "")
+	c.Assert(err, IsNil)
+	units, err = s.conn.AddUnits(svc, 2, state.AssignLocal, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 2)
+	c.Assert(units[0].Name(), Equals, "testriak/3")
+
+	// If the third of several assignments fails, the units added
+	// before it are removed too.
+	restore := juju.PatchAssignUnit(func(assign juju.AssignUnitFunc, conn *juju.Conn, svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) ([]string, error) {
+		if unit.Name() == "testriak/7" {
+			return nil, fmt.Errorf("cannot assign unit %q: boom", unit.Name())
+		}
+		return assign(conn, svc, unit, policy)
+	})
+	defer restore()
+	units, err = s.conn.AddUnits(svc, 3, state.AssignLocal, "")
+	c.Assert(err, ErrorMatches, `cannot assign unit "testriak/7": boom`)
+	c.Assert(units, IsNil)
+	units, err = svc.AllUnits()
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 3)
+	for _, name := range []string{"testriak/5", "testriak/6", "testriak/7"} {
+		_, err := s.conn.State.Unit(name)
+		c.Assert(errors.IsNotFoundError(err), Equals, true)
+	}
+	restore()
+
+	// Machines created for the units are removed too, including one
+	// created by the failed assignment.
+	machines, err := s.conn.State.AllMachines()
+	c.Assert(err, IsNil)
+	calls := 0
+	restore = juju.PatchAssignUnit(func(assign juju.AssignUnitFunc, conn *juju.Conn, svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) ([]string, error) {
+		created, err := assign(conn, svc, unit, policy)
+		if calls++; calls == 2 {
+			err = fmt.Errorf("cannot assign unit %q: boom", unit.Name())
+		}
+		return created, err
+	})
+	defer restore()
+	units, err = s.conn.AddUnits(svc, 3, state.AssignNew, "")
+	c.Assert(err, ErrorMatches, `cannot assign unit "testriak/9": boom`)
+	c.Assert(units, IsNil)
+	after, err := s.conn.State.AllMachines()
+	c.Assert(err, IsNil)
+	c.Assert(after, HasLen, len(machines))
+}
+
+func (s *ConnSuite) deployDummy(c *C) (*state.Service, *charm.URL) {
+	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "dummy")
+	sch, err := s.conn.PutCharm(curl, s.repo, false)
+	c.Assert(err, IsNil)
+	svc, err := s.conn.DeployService(juju.DeployServiceParams{
+		ServiceName: "dummy",
+		Charm:       sch,
+	})
+	c.Assert(err, IsNil)
+	return svc, curl
+}
+
+func (s *ConnSuite) TestGetCharmInfo(c *C) {
+	s.deployDummy(c)
+	meta, cfg, err := s.conn.GetCharmInfo("dummy")
+	c.Assert(err, IsNil)
+	ch := coretesting.Charms.Dir("dummy")
+	c.Assert(meta, DeepEquals, ch.Meta())
+	c.Assert(cfg, DeepEquals, ch.Config())
+
+	_, _, err = s.conn.GetCharmInfo("nonexistent")
+	c.Assert(err, ErrorMatches, `service "nonexistent" not found`)
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
+func (s *ConnSuite) assertServiceCharm(c *C, svc *state.Service, expect string) {
+	err := svc.Refresh()
+	c.Assert(err, IsNil)
+	curl, _ := svc.CharmURL()
+	c.Assert(curl.String(), Equals, expect)
+}
+
+func (s *ConnSuite) TestUpgradeCharmNewRevision(c *C) {
+	svc, curl := s.deployDummy(c)
+	s.assertServiceCharm(c, svc, "local:series/dummy-1")
+
+	dir, err := charm.ReadDir(filepath.Join(s.repo.Path, "series", "dummy"))
+	c.Assert(err, IsNil)
+	err = dir.SetDiskRevision(2)
+	c.Assert(err, IsNil)
+
+	err = s.conn.UpgradeCharm("dummy", curl, s.repo, false)
+	c.Assert(err, IsNil)
+	s.assertServiceCharm(c, svc, "local:series/dummy-2")
+}
+
+func (s *ConnSuite) TestUpgradeCharmBumpRevision(c *C) {
+	svc, curl := s.deployDummy(c)
+
+	// No newer revision is available, so the local directory's
+	// revision is incremented.
+	err := s.conn.UpgradeCharm("dummy", curl, s.repo, false)
+	c.Assert(err, IsNil)
+	s.assertServiceCharm(c, svc, "local:series/dummy-2")
+	_, err = s.conn.State.Charm(charm.MustParseURL("local:series/dummy-2"))
+	c.Assert(err, IsNil)
+}
+
+func (s *ConnSuite) TestUpgradeCharmAlreadyRunningSpecified(c *C) {
+	_, curl := s.deployDummy(c)
+	err := s.conn.UpgradeCharm("dummy", curl.WithRevision(1), s.repo, false)
+	c.Assert(err, ErrorMatches, `already running specified charm "local:series/dummy-1"`)
+}
+
+func (s *ConnSuite) TestUpgradeCharmIncompatibleSettings(c *C) {
+	svc, curl := s.deployDummy(c)
+	err := svc.UpdateConfigSettings(charm.Settings{"skill-level": int64(9001)})
+	c.Assert(err, IsNil)
+
+	// Change the type of the option in the next revision.
+	dir := filepath.Join(s.repo.Path, "series", "dummy")
+	config := `
+options:
+  skill-level: {description: A description of skill., type: string}
+`
+	err = ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644)
+	c.Assert(err, IsNil)
+
+	err = s.conn.UpgradeCharm("dummy", curl, s.repo, false)
+	c.Assert(err, ErrorMatches, `cannot upgrade service "dummy" to charm "local:series/dummy-2": setting "skill-level" is not valid for the new charm`)
+	s.assertServiceCharm(c, svc, "local:series/dummy-1")
+
+	err = s.conn.UpgradeCharm("dummy", curl, s.repo, true)
+	c.Assert(err, IsNil)
+	s.assertServiceCharm(c, svc, "local:series/dummy-2")
+	settings, err := svc.ConfigSettings()
+	c.Assert(err, IsNil)
+	c.Assert(settings, HasLen, 0)
+}
+
+func (s *ConnSuite) addRiak(c *C) *state.Service {
+	curl := coretesting.Charms.ClonedURL(s.repo.Path, "series", "riak")
+	sch, err := s.conn.PutCharm(curl, s.repo, false)
+	c.Assert(err, IsNil)
+	svc, err := s.conn.State.AddService("testriak", sch)
+	c.Assert(err, IsNil)
+	return svc
+}
+
+func (s *ConnSuite) assertAssignedMachine(c *C, unit *state.Unit, expect string) {
+	id, err := unit.AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, expect)
+}
+
+func (s *ConnSuite) TestAddUnitsCleanReusesEmptyMachine(c *C) {
+	svc := s.addRiak(c)
+	m, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnits(svc, 1, state.AssignClean, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	s.assertAssignedMachine(c, units[0], m.Id())
+
+	// The machine is no longer clean, so a new one is created.
+	units, err = s.conn.AddUnits(svc, 1, state.AssignClean, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	id, err := units[0].AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Not(Equals), m.Id())
+}
+
+func (s *ConnSuite) TestAddUnitsNewIgnoresEmptyMachine(c *C) {
+	svc := s.addRiak(c)
+	m, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnits(svc, 1, state.AssignNew, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	id, err := units[0].AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Not(Equals), m.Id())
+}
+
+func (s *ConnSuite) TestAddUnitsCleanMatchesConstraints(c *C) {
+	svc := s.addRiak(c)
+	err := svc.SetConstraints(constraints.MustParse("mem=4G"))
+	c.Assert(err, IsNil)
+
+	small, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	smallMem := uint64(2048)
+	err = small.SetProvisioned("i-small", "fake_nonce", &instance.HardwareCharacteristics{Mem: &smallMem})
+	c.Assert(err, IsNil)
+	unprovisioned, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	big, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	bigMem := uint64(8192)
+	err = big.SetProvisioned("i-big", "fake_nonce", &instance.HardwareCharacteristics{Mem: &bigMem})
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnits(svc, 1, state.AssignClean, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	s.assertAssignedMachine(c, units[0], big.Id())
+
+	// Neither remaining machine is known to satisfy the constraints.
+	units, err = s.conn.AddUnits(svc, 1, state.AssignClean, "")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	id, err := units[0].AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Not(Equals), small.Id())
+	c.Assert(id, Not(Equals), unprovisioned.Id())
+	c.Assert(id, Not(Equals), big.Id())
+}
+
+func (s *ConnSuite) TestAddUnitsWithConstraints(c *C) {
+	svc := s.addRiak(c)
+	err := svc.SetConstraints(constraints.MustParse("cpu-cores=2 mem=1G"))
+	c.Assert(err, IsNil)
+	m, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnitsWithConstraints(svc, 2, constraints.MustParse("mem=4G"))
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 2)
+	for _, unit := range units {
+		id, err := unit.AssignedMachineId()
+		c.Assert(err, IsNil)
+		// A new machine is always created for the unit.
+		c.Assert(id, Not(Equals), m.Id())
+		um, err := s.conn.State.Machine(id)
+		c.Assert(err, IsNil)
+		mcons, err := um.Constraints()
+		c.Assert(err, IsNil)
+		c.Assert(mcons, DeepEquals, constraints.MustParse("cpu-cores=2 mem=4G"))
+	}
+}
+
+func (s *ConnSuite) TestAddUnitsWithContainerConstraint(c *C) {
+	svc := s.addRiak(c)
+	units, err := s.conn.AddUnitsWithConstraints(svc, 1, constraints.MustParse("container=lxc"))
+	c.Assert(err, IsNil)
+	s.assertAssignedMachine(c, units[0], "0/lxc/0")
+}
+
+func (s *ConnSuite) TestAddUnitsToNewContainer(c *C) {
+	svc := s.addRiak(c)
+	m, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnits(svc, 1, state.AssignNew, m.Id()+"/lxc")
+	c.Assert(err, IsNil)
+	c.Assert(units, HasLen, 1)
+	s.assertAssignedMachine(c, units[0], m.Id()+"/lxc/0")
+
+	container, err := s.conn.State.Machine(m.Id() + "/lxc/0")
+	c.Assert(err, IsNil)
+	c.Assert(container.ContainerType(), Equals, instance.LXC)
+	containers, err := m.Containers()
+	c.Assert(err, IsNil)
+	c.Assert(containers, DeepEquals, []string{m.Id() + "/lxc/0"})
+}
+
+func (s *ConnSuite) TestAddUnitsToExistingContainer(c *C) {
+	svc := s.addRiak(c)
+	m, err := s.conn.State.AddMachineWithConstraints(&state.AddMachineParams{
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	})
+	c.Assert(err, IsNil)
+
+	units, err := s.conn.AddUnits(svc, 1, state.AssignNew, m.Id())
+	c.Assert(err, IsNil)
+	s.assertAssignedMachine(c, units[0], m.Id())
+}
+
+func (s *ConnSuite) TestAddUnitsToContainerOnNewMachine(c *C) {
+	svc := s.addRiak(c)
+	units, err := s.conn.AddUnits(svc, 1, state.AssignNew, "/lxc")
+	c.Assert(err, IsNil)
+	id, err := units[0].AssignedMachineId()
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "0/lxc/0")
+}
+
+func (s *ConnSuite) TestAddUnitsToContainerErrors(c *C) {
+	svc := s.addRiak(c)
+	_, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	for i, t := range []struct {
+		target string
+		err    string
+	}{{
+		target: "0/kvm",
+		err:    `invalid container type "kvm", expected one of "lxc"`,
+	}, {
+		target: "0/bogus",
+		err:    `invalid container type "bogus", expected one of "lxc"`,
+	}, {
+		target: "foo/lxc",
+		err:    `invalid machine id "foo"`,
+	}, {
+		target: "foo",
+		err:    `invalid machine id "foo"`,
+	}, {
+		target: "42/lxc",
+		err:    `machine 42 not found`,
+	}} {
+		c.Logf("test %d: %s", i, t.target)
+		_, err := s.conn.AddUnits(svc, 1, state.AssignNew, t.target)
+		c.Assert(err, ErrorMatches, `cannot assign unit "testriak/\d+" to machine: `+t.err)
+	}
+}
+
+// noContainersEnviron is an environment whose provider
+// supports no containers.
+type noContainersEnviron struct {
+	environs.Environ
+}
+
+func (*noContainersEnviron) SupportedContainerTypes() []instance.ContainerType {
+	return nil
+}
+
+func (s *ConnSuite) TestAddUnitsToContainerUnsupportedByProvider(c *C) {
+	svc := s.addRiak(c)
+	_, err := s.conn.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	defer func(env environs.Environ) { s.conn.Environ = env }(s.conn.Environ)
+	s.conn.Environ = &noContainersEnviron{s.conn.Environ}
+
+	_, err = s.conn.AddUnits(svc, 1, state.AssignNew, "0/lxc")
+	c.Assert(err, ErrorMatches, `cannot assign unit "testriak/\d+" to machine: provider does not support containers`)
+	_, err = s.conn.AddUnitsWithConstraints(svc, 1, constraints.MustParse("container=lxc"))
+	c.Assert(err, ErrorMatches, "provider does not support containers")
+	machines, err := s.conn.State.AllMachines()
+	c.Assert(err, IsNil)
+	c.Assert(machines, HasLen, 1)
+}
+
 // DeployLocalSuite uses a fresh copy of the same local dummy charm for each
 // test, because DeployService demands that a charm already exists in state,
 // and that's is the simplest way to get one in there.
@@ -580,18 +1236,20 @@ func (s *InitJujuHomeSuite) TearDownTest(c *C) {
 }
 
 func (s *InitJujuHomeSuite) TestJujuHome(c *C) {
-	os.Setenv("JUJU_HOME", "/my/juju/home")
+	jujuHome := filepath.Join(c.MkDir(), "my", "juju", "home")
+	os.Setenv("JUJU_HOME", jujuHome)
 	err := juju.InitJujuHome()
 	c.Assert(err, IsNil)
-	c.Assert(config.JujuHome(), Equals, "/my/juju/home")
+	c.Assert(config.JujuHome(), Equals, jujuHome)
 }
 
 func (s *InitJujuHomeSuite) TestHome(c *C) {
+	home := c.MkDir()
 	os.Setenv("JUJU_HOME", "")
-	os.Setenv("HOME", "/my/home/")
+	os.Setenv("HOME", home+"/")
 	err := juju.InitJujuHome()
 	c.Assert(err, IsNil)
-	c.Assert(config.JujuHome(), Equals, "/my/home/.juju")
+	c.Assert(config.JujuHome(), Equals, filepath.Join(home, ".juju"))
 }
 
 func (s *InitJujuHomeSuite) TestError(c *C) {
@@ -602,9 +1260,35 @@ func (s *InitJujuHomeSuite) TestError(c *C) {
 }
 
 func (s *InitJujuHomeSuite) TestCacheDir(c *C) {
-	os.Setenv("JUJU_HOME", "/foo/bar")
+	jujuHome := filepath.Join(c.MkDir(), "foo", "bar")
+	os.Setenv("JUJU_HOME", jujuHome)
 	c.Assert(charm.CacheDir, Equals, "")
 	err := juju.InitJujuHome()
 	c.Assert(err, IsNil)
-	c.Assert(charm.CacheDir, Equals, "/foo/bar/charmcache")
+	c.Assert(charm.CacheDir, Equals, jujuHome+"/charmcache")
+}
+
+func (s *InitJujuHomeSuite) TestInitJujuHomePath(c *C) {
+	os.Setenv("JUJU_HOME", "/ignored")
+	os.Setenv("HOME", "/ignored")
+	jujuHome := filepath.Join(c.MkDir(), "juju")
+	err := juju.InitJujuHomePath(jujuHome)
+	c.Assert(err, IsNil)
+	c.Assert(config.JujuHome(), Equals, jujuHome)
+	c.Assert(charm.CacheDir, Equals, filepath.Join(jujuHome, "charmcache"))
+	info, err := os.Stat(charm.CacheDir)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsDir(), Equals, true)
+
+	// An existing cache directory is fine.
+	err = juju.InitJujuHomePath(jujuHome)
+	c.Assert(err, IsNil)
+}
+
+func (s *InitJujuHomeSuite) TestInitJujuHomePathError(c *C) {
+	file := filepath.Join(c.MkDir(), "file")
+	err := ioutil.WriteFile(file, nil, 0644)
+	c.Assert(err, IsNil)
+	err = juju.InitJujuHomePath(file)
+	c.Assert(err, ErrorMatches, "cannot create charm cache directory: .*")
 }
@@ -0,0 +1,63 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package juju
+
+import (
+	"time"
+
+	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/utils"
+)
+
+var (
+	UpdateSecrets = updateSecrets
+	VerifyUpload  = verifyUpload
+)
+
+// SetVerifyUploadTimeout sets the time allowed for fetching an
+// uploaded charm to verify it, and returns a function that restores
+// the original timeout.
+func SetVerifyUploadTimeout(timeout time.Duration) (restore func()) {
+	old := verifyUploadTimeout
+	verifyUploadTimeout = timeout
+	return func() {
+		verifyUploadTimeout = old
+	}
+}
+
+// SetBundleLimits sets the maximum size of, and time taken for,
+// charm bundle downloads, and returns a function that restores
+// the original limits.
+func SetBundleLimits(maxSize int64, timeout time.Duration) (restore func()) {
+	oldSize, oldTimeout := maxBundleSize, bundleDownloadTimeout
+	maxBundleSize, bundleDownloadTimeout = maxSize, timeout
+	return func() {
+		maxBundleSize, bundleDownloadTimeout = oldSize, oldTimeout
+	}
+}
+
+// AssignUnitFunc is the type of the function used by AddUnits to
+// assign each unit.
+type AssignUnitFunc func(conn *Conn, svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) ([]string, error)
+
+// PatchAssignUnit replaces the function used by AddUnits to assign
+// each unit, and returns a function that restores the original. The
+// original is passed to f so that it can be called.
+func PatchAssignUnit(f func(assign AssignUnitFunc, conn *Conn, svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) ([]string, error)) (restore func()) {
+	old := assignUnit
+	assignUnit = func(conn *Conn, svc *state.Service, unit *state.Unit, policy state.AssignmentPolicy) ([]string, error) {
+		return f(old, conn, svc, unit, policy)
+	}
+	return func() {
+		assignUnit = old
+	}
+}
+
+// SetSecretsStrategy sets the strategy used when delivering
+// secrets and returns the previous one.
+func SetSecretsStrategy(s utils.AttemptStrategy) utils.AttemptStrategy {
+	old := secretsStrategy
+	secretsStrategy = s
+	return old
+}
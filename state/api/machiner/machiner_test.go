@@ -86,11 +86,10 @@ func (s *machinerSuite) TestEnsureDead(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(s.machine.Life(), Equals, state.Dead)
 
+	// Once the machine is dead, its agent can no longer use the
+	// machiner facade.
 	err = machine.EnsureDead()
-	c.Assert(err, IsNil)
-	err = s.machine.Refresh()
-	c.Assert(err, IsNil)
-	c.Assert(s.machine.Life(), Equals, state.Dead)
+	c.Assert(err, ErrorMatches, "machine 0 is dead")
 
 	err = s.machine.Remove()
 	c.Assert(err, IsNil)
@@ -107,11 +106,11 @@ func (s *machinerSuite) TestRefresh(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(machine.Life(), Equals, params.Alive)
 
-	err = machine.EnsureDead()
+	err = s.machine.Destroy()
 	c.Assert(err, IsNil)
 	c.Assert(machine.Life(), Equals, params.Alive)
 
 	err = machine.Refresh()
 	c.Assert(err, IsNil)
-	c.Assert(machine.Life(), Equals, params.Dead)
+	c.Assert(machine.Life(), Equals, params.Dying)
 }
@@ -6,6 +6,7 @@ package params
 import (
 	"fmt"
 
+	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/rpc"
 )
 
@@ -54,6 +55,27 @@ func ErrCode(err error) string {
 	return ""
 }
 
+// ServerError returns an error suitable for returning to an API
+// client. The error code is the one carried by err, if any, or
+// else one derived from the kind of error it is.
+func ServerError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	code := ErrCode(err)
+	switch {
+	case code != "":
+	case errors.IsUnauthorizedError(err):
+		code = CodeUnauthorized
+	case errors.IsNotFoundError(err):
+		code = CodeNotFound
+	}
+	return &Error{
+		Message: err.Error(),
+		Code:    code,
+	}
+}
+
 // clientError maps errors returned from an RPC call into local errors with
 // appropriate values.
 func ClientError(err error) error {
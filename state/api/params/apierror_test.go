@@ -0,0 +1,51 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package params_test
+
+import (
+	stderrors "errors"
+
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/errors"
+	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/state/api/params"
+)
+
+type errorSuite struct{}
+
+var _ = Suite(&errorSuite{})
+
+var serverErrorTests = []struct {
+	err  error
+	code string
+}{{
+	err:  errors.NotFoundf("hello"),
+	code: params.CodeNotFound,
+}, {
+	err:  errors.Unauthorizedf("hello"),
+	code: params.CodeUnauthorized,
+}, {
+	err:  state.ErrExcessiveContention,
+	code: params.CodeExcessiveContention,
+}, {
+	err:  &state.HasAssignedUnitsError{"42", []string{"a"}},
+	code: params.CodeHasAssignedUnits,
+}, {
+	err:  &params.Error{Message: "hello", Code: "some code"},
+	code: "some code",
+}, {
+	err:  stderrors.New("an error"),
+	code: "",
+}}
+
+func (*errorSuite) TestServerError(c *C) {
+	for i, t := range serverErrorTests {
+		c.Logf("test %d: %v", i, t.err)
+		err := params.ServerError(t.err)
+		c.Check(err.Message, Equals, t.err.Error())
+		c.Check(err.Code, Equals, t.code)
+	}
+	c.Assert(params.ServerError(nil), IsNil)
+}
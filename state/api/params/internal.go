@@ -33,8 +33,35 @@ type MachineSetStatus struct {
 }
 
 // MachinesSetStatus holds the parameters for making a Machiner.SetStatus call.
+// If Atomic is true, no status is set unless every status can be.
 type MachinesSetStatus struct {
 	Machines []MachineSetStatus
+	Atomic   bool
+}
+
+// MachineSetAddresses holds a machine tag and the network addresses
+// discovered for it.
+type MachineSetAddresses struct {
+	Tag       string
+	Addresses []string
+}
+
+// MachinesSetAddresses holds the parameters for making a
+// Machiner.SetMachineAddresses call.
+type MachinesSetAddresses struct {
+	Machines []MachineSetAddresses
+}
+
+// JobsResult holds the jobs of a single machine, or an error
+// indicating why they are not available.
+type JobsResult struct {
+	Jobs  []MachineJob
+	Error *Error
+}
+
+// JobsResults holds the jobs or error status of multiple machines.
+type JobsResults struct {
+	Results []JobsResult
 }
 
 // MachineAgentGetMachinesResults holds the results of a
@@ -122,3 +149,9 @@ type StringsWatchResult struct {
 	Changes          []string
 	Error            *Error
 }
+
+// StringsWatchResults holds the results for any API call which ends up
+// returning a list of StringsWatchers.
+type StringsWatchResults struct {
+	Results []StringsWatchResult
+}
@@ -117,6 +117,12 @@ type AddServiceUnitsResults struct {
 type AddServiceUnits struct {
 	ServiceName string
 	NumUnits    int
+	// ToMachineSpec, if set, names the machine or container to
+	// place the single new unit on, as accepted by Conn.AddUnits.
+	ToMachineSpec string
+	// Constraints, if set, override the service constraints
+	// for the machines created for the new units.
+	Constraints constraints.Value
 }
 
 // DestroyServiceUnits holds parameters for the DestroyUnits call.
@@ -93,7 +93,7 @@ func (c *Client) ServiceSet(p params.ServiceSet) error {
 	if err != nil {
 		return err
 	}
-	return svc.UpdateConfigSettings(changes)
+	return svc.UpdateConfigSettingsAudited(changes, c.api.auth.GetAuthTag())
 }
 
 // ServiceSetYAML implements the server side of Client.ServerSetYAML.
@@ -110,7 +110,7 @@ func (c *Client) ServiceSetYAML(p params.ServiceSetYAML) error {
 	if err != nil {
 		return err
 	}
-	return svc.UpdateConfigSettings(changes)
+	return svc.UpdateConfigSettingsAudited(changes, c.api.auth.GetAuthTag())
 }
 
 // ServiceGet returns the configuration for a service.
@@ -44,6 +44,10 @@ func (s *clientSuite) TestClientServerSet(c *C) {
 		"title":    "xxx",
 		"username": "yyy",
 	})
+	history, err := dummy.ConfigHistory(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Assert(history[0].ActorTag, Equals, "user-admin")
 }
 
 func (s *clientSuite) TestClientServiceSetYAML(c *C) {
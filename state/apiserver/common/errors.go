@@ -5,8 +5,6 @@ package common
 
 import (
 	stderrors "errors"
-	"launchpad.net/juju-core/errors"
-	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 )
 
@@ -22,41 +20,23 @@ var (
 )
 
 var singletonErrorCodes = map[error]string{
-	state.ErrCannotEnterScopeYet: params.CodeCannotEnterScopeYet,
-	state.ErrCannotEnterScope:    params.CodeCannotEnterScope,
-	state.ErrExcessiveContention: params.CodeExcessiveContention,
-	state.ErrUnitHasSubordinates: params.CodeUnitHasSubordinates,
-	ErrBadId:                     params.CodeNotFound,
-	ErrBadCreds:                  params.CodeUnauthorized,
-	ErrPerm:                      params.CodeUnauthorized,
-	ErrNotLoggedIn:               params.CodeUnauthorized,
-	ErrUnknownWatcher:            params.CodeNotFound,
-	ErrStoppedWatcher:            params.CodeStopped,
+	ErrBadId:          params.CodeNotFound,
+	ErrBadCreds:       params.CodeUnauthorized,
+	ErrPerm:           params.CodeUnauthorized,
+	ErrNotLoggedIn:    params.CodeUnauthorized,
+	ErrUnknownWatcher: params.CodeNotFound,
+	ErrStoppedWatcher: params.CodeStopped,
 }
 
 // ServerError returns an error suitable for returning to an API
 // client, with an error code suitable for various kinds of errors
 // generated in packages outside the API.
 func ServerError(err error) *params.Error {
-	if err == nil {
-		return nil
-	}
-	code := singletonErrorCodes[err]
-	switch {
-	case code != "":
-	case errors.IsUnauthorizedError(err):
-		code = params.CodeUnauthorized
-	case errors.IsNotFoundError(err):
-		code = params.CodeNotFound
-	case state.IsNotAssigned(err):
-		code = params.CodeNotAssigned
-	case state.IsHasAssignedUnitsError(err):
-		code = params.CodeHasAssignedUnits
-	default:
-		code = params.ErrCode(err)
-	}
-	return &params.Error{
-		Message: err.Error(),
-		Code:    code,
+	if code, ok := singletonErrorCodes[err]; ok {
+		return &params.Error{
+			Message: err.Error(),
+			Code:    code,
+		}
 	}
+	return params.ServerError(err)
 }
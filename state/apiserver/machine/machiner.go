@@ -4,6 +4,10 @@
 package machine
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/apiserver/common"
@@ -16,13 +20,23 @@ type MachinerAPI struct {
 	st        *state.State
 	resources *common.Resources
 	auth      common.Authorizer
+	passwords *common.PasswordChanger
 }
 
-// NewMachinerAPI creates a new instance of the Machiner API.
+// NewMachinerAPI creates a new instance of the Machiner API. It fails
+// unless the authenticated entity is a machine agent whose tag is a
+// valid machine tag, and that machine exists and is not dead.
 func NewMachinerAPI(st *state.State, resources *common.Resources, authorizer common.Authorizer) (*MachinerAPI, error) {
-	if !authorizer.AuthMachineAgent() {
+	if !authorizer.AuthMachineAgent() || !isMachineTag(authorizer.GetAuthTag()) {
 		return nil, common.ErrPerm
 	}
+	machine, err := st.Machine(state.MachineIdFromTag(authorizer.GetAuthTag()))
+	if err != nil {
+		return nil, err
+	}
+	if machine.Life() == state.Dead {
+		return nil, fmt.Errorf("machine %s is dead", machine)
+	}
 	getCanRead := func() (common.AuthFunc, error) {
 		return func(tag string) bool {
 			// TODO(go1.1): method expression
@@ -34,10 +48,17 @@ func NewMachinerAPI(st *state.State, resources *common.Resources, authorizer com
 		st:         st,
 		resources:  resources,
 		auth:       authorizer,
+		passwords:  common.NewPasswordChanger(st, getCanRead),
 	}, nil
 }
 
-// SetStatus sets the status of each given machine.
+// isMachineTag returns whether tag is the tag of a machine.
+func isMachineTag(tag string) bool {
+	return strings.HasPrefix(tag, "machine-") && state.IsMachineId(state.MachineIdFromTag(tag))
+}
+
+// SetStatus sets the status of each given machine. In atomic mode, no
+// status is set if any entry fails.
 func (m *MachinerAPI) SetStatus(args params.MachinesSetStatus) (params.ErrorResults, error) {
 	result := params.ErrorResults{
 		Errors: make([]*params.Error, len(args.Machines)),
@@ -45,6 +66,9 @@ func (m *MachinerAPI) SetStatus(args params.MachinesSetStatus) (params.ErrorResu
 	if len(args.Machines) == 0 {
 		return result, nil
 	}
+	if args.Atomic {
+		return m.setStatusAtomic(args)
+	}
 	for i, arg := range args.Machines {
 		err := common.ErrPerm
 		if m.auth.AuthOwner(arg.Tag) {
@@ -59,6 +83,101 @@ func (m *MachinerAPI) SetStatus(args params.MachinesSetStatus) (params.ErrorResu
 	return result, nil
 }
 
+var errBatchAborted = errors.New("status not set: another status in the batch failed")
+
+// setStatusAtomic sets the status of every given machine in a single
+// transaction. If any entry cannot be set, none are; the failed
+// entries report their own errors and the others errBatchAborted.
+func (m *MachinerAPI) setStatusAtomic(args params.MachinesSetStatus) (params.ErrorResults, error) {
+	result := params.ErrorResults{
+		Errors: make([]*params.Error, len(args.Machines)),
+	}
+	statuses := make([]state.MachineStatus, len(args.Machines))
+	failed := false
+	for i, arg := range args.Machines {
+		err := common.ErrPerm
+		if m.auth.AuthOwner(arg.Tag) {
+			var machine *state.Machine
+			machine, err = m.st.Machine(state.MachineIdFromTag(arg.Tag))
+			if err == nil {
+				statuses[i] = state.MachineStatus{
+					Machine: machine,
+					Status:  arg.Status,
+					Info:    arg.Info,
+				}
+			}
+		}
+		if err != nil {
+			result.Errors[i] = common.ServerError(err)
+			failed = true
+		}
+	}
+	var err error
+	if failed {
+		err = errBatchAborted
+	} else {
+		err = m.st.SetMachineStatuses(statuses)
+	}
+	if err != nil {
+		for i := range result.Errors {
+			if result.Errors[i] == nil {
+				result.Errors[i] = common.ServerError(err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// SetMachineAddresses sets the network addresses of each given machine.
+func (m *MachinerAPI) SetMachineAddresses(args params.MachinesSetAddresses) (params.ErrorResults, error) {
+	result := params.ErrorResults{
+		Errors: make([]*params.Error, len(args.Machines)),
+	}
+	if len(args.Machines) == 0 {
+		return result, nil
+	}
+	for i, arg := range args.Machines {
+		err := common.ErrPerm
+		if m.auth.AuthOwner(arg.Tag) {
+			var machine *state.Machine
+			machine, err = m.st.Machine(state.MachineIdFromTag(arg.Tag))
+			if err == nil {
+				err = machine.SetAddresses(arg.Addresses)
+			}
+		}
+		result.Errors[i] = common.ServerError(err)
+	}
+	return result, nil
+}
+
+// SetPassword sets the mongo and API password of each given machine.
+// A machine may only change its own password.
+func (m *MachinerAPI) SetPassword(args params.PasswordChanges) (params.ErrorResults, error) {
+	return m.passwords.SetPasswords(args)
+}
+
+// Jobs returns the jobs of each given machine.
+func (m *MachinerAPI) Jobs(args params.Entities) (params.JobsResults, error) {
+	result := params.JobsResults{
+		Results: make([]params.JobsResult, len(args.Entities)),
+	}
+	if len(args.Entities) == 0 {
+		return result, nil
+	}
+	for i, entity := range args.Entities {
+		err := common.ErrPerm
+		if m.auth.AuthOwner(entity.Tag) {
+			var machine *state.Machine
+			machine, err = m.st.Machine(state.MachineIdFromTag(entity.Tag))
+			if err == nil {
+				result.Results[i].Jobs = stateJobsToAPIParamsJobs(machine.Jobs())
+			}
+		}
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
+
 // Watch starts an NotifyWatcher for each given machine.
 func (m *MachinerAPI) Watch(args params.Entities) (params.NotifyWatchResults, error) {
 	result := params.NotifyWatchResults{
@@ -90,6 +209,38 @@ func (m *MachinerAPI) Watch(args params.Entities) (params.NotifyWatchResults, er
 	return result, nil
 }
 
+// WatchUnits starts a StringsWatcher for the units assigned to each
+// given machine, and their subordinates. The names of the units
+// initially present are returned with the watcher id.
+func (m *MachinerAPI) WatchUnits(args params.Entities) (params.StringsWatchResults, error) {
+	result := params.StringsWatchResults{
+		Results: make([]params.StringsWatchResult, len(args.Entities)),
+	}
+	if len(args.Entities) == 0 {
+		return result, nil
+	}
+	for i, entity := range args.Entities {
+		err := common.ErrPerm
+		if m.auth.AuthOwner(entity.Tag) {
+			var machine *state.Machine
+			machine, err = m.st.Machine(state.MachineIdFromTag(entity.Tag))
+			if err == nil {
+				watch := machine.WatchUnits()
+				// Consume the initial event and forward it
+				// to the result.
+				if changes, ok := <-watch.Changes(); ok {
+					result.Results[i].StringsWatcherId = m.resources.Register(watch)
+					result.Results[i].Changes = changes
+				} else {
+					err = watcher.MustErr(watch)
+				}
+			}
+		}
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
+
 // EnsureDead changes the lifecycle of each given machine to Dead if
 // it's Alive or Dying. It does nothing otherwise.
 func (m *MachinerAPI) EnsureDead(args params.Entities) (params.ErrorResults, error) {
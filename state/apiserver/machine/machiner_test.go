@@ -6,6 +6,7 @@ package machine_test
 import (
 	. "launchpad.net/gocheck"
 
+	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/apiserver/common"
@@ -54,6 +55,34 @@ func (s *machinerSuite) TestMachinerFailsWithNonMachineAgentUser(c *C) {
 	c.Assert(err, ErrorMatches, "permission denied")
 }
 
+func (s *machinerSuite) TestMachinerFailsWithNonMachineTag(c *C) {
+	for _, tag := range []string{"unit-wordpress-0", "machine-", "machine-foo", "user-admin"} {
+		c.Logf("tag %q", tag)
+		anAuthorizer := s.authorizer
+		anAuthorizer.Tag = tag
+		aMachiner, err := machine.NewMachinerAPI(s.State, s.resources, anAuthorizer)
+		c.Assert(err, ErrorMatches, "permission denied")
+		c.Assert(aMachiner, IsNil)
+	}
+}
+
+func (s *machinerSuite) TestMachinerFailsWithNonexistentMachine(c *C) {
+	anAuthorizer := s.authorizer
+	anAuthorizer.Tag = "machine-42"
+	aMachiner, err := machine.NewMachinerAPI(s.State, s.resources, anAuthorizer)
+	c.Assert(err, ErrorMatches, "machine 42 not found")
+	c.Assert(errors.IsNotFoundError(err), Equals, true)
+	c.Assert(aMachiner, IsNil)
+}
+
+func (s *machinerSuite) TestMachinerFailsWithDeadMachine(c *C) {
+	err := s.machine1.EnsureDead()
+	c.Assert(err, IsNil)
+	aMachiner, err := machine.NewMachinerAPI(s.State, s.resources, s.authorizer)
+	c.Assert(err, ErrorMatches, "machine 1 is dead")
+	c.Assert(aMachiner, IsNil)
+}
+
 func (s *machinerSuite) TestSetStatus(c *C) {
 	err := s.machine0.SetStatus(params.StatusStarted, "blah")
 	c.Assert(err, IsNil)
@@ -85,6 +114,161 @@ func (s *machinerSuite) TestSetStatus(c *C) {
 	c.Assert(info, Equals, "not really")
 }
 
+func (s *machinerSuite) TestWatchUnits(c *C) {
+	c.Assert(s.resources.Count(), Equals, 0)
+
+	args := params.Entities{Entities: []params.Entity{
+		{Tag: "machine-1"},
+		{Tag: "machine-0"},
+		{Tag: "machine-42"},
+	}}
+	result, err := s.machiner.WatchUnits(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Results, HasLen, 3)
+	c.Assert(result.Results[0].Error, IsNil)
+	c.Assert(result.Results[0].Changes, HasLen, 0)
+	s.assertError(c, result.Results[1].Error, params.CodeUnauthorized, "permission denied")
+	s.assertError(c, result.Results[2].Error, params.CodeUnauthorized, "permission denied")
+
+	// Verify the resource was registered and stop when done
+	c.Assert(s.resources.Count(), Equals, 1)
+	c.Assert(result.Results[0].StringsWatcherId, Equals, "1")
+	resource := s.resources.Get("1")
+	defer statetesting.AssertStop(c, resource)
+
+	// Check that the initial event was consumed, and that
+	// assigning a unit to the machine is reported.
+	wc := statetesting.NewStringsWatcherC(c, s.State, resource.(state.StringsWatcher))
+	wc.AssertNoChange()
+
+	svc, err := s.State.AddService("wordpress", s.AddTestingCharm(c, "wordpress"))
+	c.Assert(err, IsNil)
+	unit, err := svc.AddUnit()
+	c.Assert(err, IsNil)
+	err = unit.AssignToMachine(s.machine1)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange("wordpress/0")
+	wc.AssertNoChange()
+}
+
+func (s *machinerSuite) TestSetStatusAtomic(c *C) {
+	err := s.machine1.SetStatus(params.StatusStopped, "foo")
+	c.Assert(err, IsNil)
+
+	args := params.MachinesSetStatus{
+		Machines: []params.MachineSetStatus{
+			{Tag: "machine-1", Status: params.StatusStarted},
+			{Tag: "machine-0", Status: params.StatusStopped, Info: "foobar"},
+		},
+		Atomic: true,
+	}
+	result, err := s.machiner.SetStatus(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Errors, HasLen, 2)
+	c.Assert(result.Errors[0], ErrorMatches, "status not set: another status in the batch failed")
+	s.assertError(c, result.Errors[1], params.CodeUnauthorized, "permission denied")
+
+	// Machine 1 is unchanged, because the batch failed as a whole.
+	status, info, err := s.machine1.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusStopped)
+	c.Assert(info, Equals, "foo")
+
+	args.Machines = args.Machines[:1]
+	result, err = s.machiner.SetStatus(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Errors, DeepEquals, []*params.Error{nil})
+	status, info, err = s.machine1.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusStarted)
+	c.Assert(info, Equals, "")
+}
+
+func (s *machinerSuite) TestSetStatusAtomicDeadMachine(c *C) {
+	err := s.machine1.EnsureDead()
+	c.Assert(err, IsNil)
+
+	args := params.MachinesSetStatus{
+		Machines: []params.MachineSetStatus{
+			{Tag: "machine-1", Status: params.StatusStopped},
+		},
+		Atomic: true,
+	}
+	result, err := s.machiner.SetStatus(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Errors, HasLen, 1)
+	c.Assert(result.Errors[0], ErrorMatches, `cannot set status of machines 1: not found or not alive`)
+}
+
+func (s *machinerSuite) TestSetMachineAddresses(c *C) {
+	c.Assert(s.machine0.Addresses(), HasLen, 0)
+	c.Assert(s.machine1.Addresses(), HasLen, 0)
+
+	addresses := []string{"10.0.0.1", "example.com"}
+	args := params.MachinesSetAddresses{
+		Machines: []params.MachineSetAddresses{
+			{Tag: "machine-1", Addresses: addresses},
+			{Tag: "machine-0", Addresses: []string{"10.0.0.2"}},
+			{Tag: "machine-42", Addresses: []string{"10.0.0.3"}},
+		}}
+	result, err := s.machiner.SetMachineAddresses(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Errors, HasLen, 3)
+	c.Assert(result.Errors[0], IsNil)
+	s.assertError(c, result.Errors[1], params.CodeUnauthorized, "permission denied")
+	s.assertError(c, result.Errors[2], params.CodeUnauthorized, "permission denied")
+
+	// Verify machine 0 - no change.
+	err = s.machine0.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.machine0.Addresses(), HasLen, 0)
+	// ...machine 1 is fine though.
+	err = s.machine1.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.machine1.Addresses(), DeepEquals, addresses)
+}
+
+func (s *machinerSuite) TestSetPassword(c *C) {
+	args := params.PasswordChanges{
+		Changes: []params.PasswordChange{
+			{Tag: "machine-1", Password: "xxx"},
+			{Tag: "machine-0", Password: "yyy"},
+			{Tag: "machine-42", Password: "zzz"},
+		}}
+	result, err := s.machiner.SetPassword(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Errors, HasLen, 3)
+	c.Assert(result.Errors[0], IsNil)
+	s.assertError(c, result.Errors[1], params.CodeUnauthorized, "permission denied")
+	s.assertError(c, result.Errors[2], params.CodeUnauthorized, "permission denied")
+
+	// Verify machine 0 - no change.
+	err = s.machine0.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.machine0.PasswordValid("yyy"), Equals, false)
+	// ...machine 1 is fine though.
+	err = s.machine1.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.machine1.PasswordValid("xxx"), Equals, true)
+}
+
+func (s *machinerSuite) TestJobs(c *C) {
+	args := params.Entities{Entities: []params.Entity{
+		{Tag: "machine-1"},
+		{Tag: "machine-0"},
+		{Tag: "machine-42"},
+	}}
+	result, err := s.machiner.Jobs(args)
+	c.Assert(err, IsNil)
+	c.Assert(result.Results, HasLen, 3)
+	c.Assert(result.Results[0].Error, IsNil)
+	c.Assert(result.Results[0].Jobs, DeepEquals, []params.MachineJob{params.JobHostUnits})
+	s.assertError(c, result.Results[1].Error, params.CodeUnauthorized, "permission denied")
+	c.Assert(result.Results[1].Jobs, IsNil)
+	s.assertError(c, result.Results[2].Error, params.CodeUnauthorized, "permission denied")
+	c.Assert(result.Results[2].Jobs, IsNil)
+}
+
 func (s *machinerSuite) TestLife(c *C) {
 	err := s.machine1.EnsureDead()
 	c.Assert(err, IsNil)
@@ -178,3 +178,8 @@ func (r *srvRoot) AuthEnvironManager() bool {
 func (r *srvRoot) AuthClient() bool {
 	return !isAgent(r.entity)
 }
+
+// GetAuthTag returns the tag of the authenticated entity.
+func (r *srvRoot) GetAuthTag() string {
+	return r.entity.Tag()
+}
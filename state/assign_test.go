@@ -328,6 +328,28 @@ func (s *AssignSuite) assertAssignedUnit(c *C, unit *state.Unit) string {
 	return machineId
 }
 
+func (s *AssignSuite) TestAssignToUnusedMachine(c *C) {
+	machine, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	unit0, err := s.wordpress.AddUnit()
+	c.Assert(err, IsNil)
+	unit1, err := s.wordpress.AddUnit()
+	c.Assert(err, IsNil)
+
+	// Take the machine through a different machine object, so
+	// the one used below still thinks the machine is clean.
+	machine0, err := s.State.Machine(machine.Id())
+	c.Assert(err, IsNil)
+	err = unit0.AssignToUnusedMachine(machine0)
+	c.Assert(err, IsNil)
+	c.Assert(machine.Clean(), jc.IsTrue)
+
+	err = unit1.AssignToUnusedMachine(machine)
+	c.Assert(err, ErrorMatches, `cannot assign unit "wordpress/1" to machine 0: machine is not unused`)
+	_, err = unit1.AssignedMachineId()
+	c.Assert(err, NotNil)
+}
+
 func (s *AssignSuite) TestAssignUnitToNewMachine(c *C) {
 	unit, err := s.wordpress.AddUnit()
 	c.Assert(err, IsNil)
@@ -113,6 +113,24 @@ func ServiceSettingsRefCount(st *State, serviceName string, curl *charm.URL) (in
 	return 0, mgo.ErrNotFound
 }
 
+// ServiceSettingsHistoryCount returns the number of history entries
+// recorded for the settings of the given service and charm.
+func ServiceSettingsHistoryCount(st *State, serviceName string, curl *charm.URL) (int, error) {
+	return st.settingsHistory.Find(D{{"key", serviceSettingsKey(serviceName, curl)}}).Count()
+}
+
+// ServiceSettings returns the settings of the given service and charm.
+func ServiceSettings(st *State, serviceName string, curl *charm.URL) (*Settings, error) {
+	return readSettings(st, serviceSettingsKey(serviceName, curl))
+}
+
+// ServiceSettingsExist returns whether the settings document for the
+// given service and charm exists.
+func ServiceSettingsExist(st *State, serviceName string, curl *charm.URL) (bool, error) {
+	n, err := st.settings.FindId(serviceSettingsKey(serviceName, curl)).Count()
+	return n > 0, err
+}
+
 func AddTestingCharm(c *C, st *State, name string) *Charm {
 	return addCharm(c, st, "series", testing.Charms.Dir(name))
 }
@@ -51,6 +51,54 @@ func (job MachineJob) String() string {
 	return string(jobNames[j])
 }
 
+// jobFlagNames holds the names by which machine jobs are given on
+// the command line.
+var jobFlagNames = []string{
+	JobHostUnits:     "host-units",
+	JobManageEnviron: "manage-environ",
+	JobManageState:   "manage-state",
+}
+
+// ParseMachineJobs parses a comma-separated list of machine job
+// names, as given on the command line; for example,
+// "host-units,manage-environ".
+func ParseMachineJobs(s string) ([]MachineJob, error) {
+	var jobs []MachineJob
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		job, ok := parseMachineJob(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown machine job %q", name)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func parseMachineJob(name string) (MachineJob, bool) {
+	for j, flagName := range jobFlagNames {
+		if flagName != "" && flagName == name {
+			return MachineJob(j), true
+		}
+	}
+	return 0, false
+}
+
+// FormatMachineJobs returns the given jobs in the form
+// accepted by ParseMachineJobs.
+func FormatMachineJobs(jobs []MachineJob) string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		j := int(job)
+		if j <= 0 || j >= len(jobFlagNames) {
+			names[i] = job.String()
+		} else {
+			names[i] = jobFlagNames[j]
+		}
+	}
+	return strings.Join(names, ",")
+}
+
 // machineDoc represents the internal state of a machine in MongoDB.
 // Note the correspondence with MachineInfo in state/api/params.
 type machineDoc struct {
@@ -65,6 +113,9 @@ type machineDoc struct {
 	Jobs          []MachineJob
 	PasswordHash  string
 	Clean         bool
+	Addresses     []string
+	// Manual is true for machines added by AddManualMachine.
+	Manual bool
 	// Deprecated. InstanceId, now lives on instanceData.
 	// This attribute is retained so that data from existing machines can be read.
 	// SCHEMACHANGE
@@ -116,6 +167,7 @@ type instanceData struct {
 	InstanceId instance.Id `bson:"instanceid"`
 	Arch       *string     `bson:"arch,omitempty"`
 	Mem        *uint64     `bson:"mem,omitempty"`
+	RootDisk   *uint64     `bson:"rootdisk,omitempty"`
 	CpuCores   *uint64     `bson:"cpucpores,omitempty"`
 	CpuPower   *uint64     `bson:"cpupower,omitempty"`
 	TxnRevno   int64       `bson:"txn-revno"`
@@ -130,6 +182,7 @@ func (m *Machine) HardwareCharacteristics() (*instance.HardwareCharacteristics,
 	}
 	hc.Arch = instData.Arch
 	hc.Mem = instData.Mem
+	hc.RootDisk = instData.RootDisk
 	hc.CpuCores = instData.CpuCores
 	hc.CpuPower = instData.CpuPower
 	return hc, nil
@@ -214,6 +267,30 @@ func (m *Machine) SetAgentTools(t *Tools) (err error) {
 	return nil
 }
 
+// Addresses returns the network addresses last reported by the
+// machine's agent.
+func (m *Machine) Addresses() []string {
+	return append([]string(nil), m.doc.Addresses...)
+}
+
+// SetAddresses records the network addresses of the machine, as
+// discovered by its agent, replacing any previously set.
+func (m *Machine) SetAddresses(addresses []string) (err error) {
+	defer utils.ErrorContextf(&err, "cannot set addresses of machine %v", m)
+	addresses = append([]string(nil), addresses...)
+	ops := []txn.Op{{
+		C:      m.st.machines.Name,
+		Id:     m.doc.Id,
+		Assert: notDeadDoc,
+		Update: D{{"$set", D{{"addresses", addresses}}}},
+	}}
+	if err := m.st.runTransaction(ops); err != nil {
+		return onAbort(err, errDead)
+	}
+	m.doc.Addresses = addresses
+	return nil
+}
+
 // SetMongoPassword sets the password the agent responsible for the machine
 // should use to communicate with the state servers.  Previous passwords
 // are invalidated.
@@ -270,6 +347,10 @@ func (e *HasAssignedUnitsError) Error() string {
 	return fmt.Sprintf("machine %s has unit %q assigned", e.MachineId, e.UnitNames[0])
 }
 
+func (e *HasAssignedUnitsError) ErrorCode() string {
+	return params.CodeHasAssignedUnits
+}
+
 func IsHasAssignedUnitsError(err error) bool {
 	_, ok := err.(*HasAssignedUnitsError)
 	return ok
@@ -420,6 +501,14 @@ func (m *Machine) Remove() (err error) {
 	if m.doc.Life != Dead {
 		return fmt.Errorf("machine is not dead")
 	}
+	// The only abort conditions in play indicate that the machine has already
+	// been removed.
+	return onAbort(m.st.runTransaction(m.removeOps()), nil)
+}
+
+// removeOps returns the operations that remove the machine
+// and all the documents that belong to it.
+func (m *Machine) removeOps() []txn.Op {
 	ops := []txn.Op{
 		{
 			C:      m.st.machines.Name,
@@ -436,10 +525,7 @@ func (m *Machine) Remove() (err error) {
 		removeConstraintsOp(m.st, m.globalKey()),
 		annotationRemoveOp(m.st, m.globalKey()),
 	}
-	ops = append(ops, removeContainerRefOps(m.st, m.Id())...)
-	// The only abort conditions in play indicate that the machine has already
-	// been removed.
-	return onAbort(m.st.runTransaction(ops), nil)
+	return append(ops, removeContainerRefOps(m.st, m.Id())...)
 }
 
 // Refresh refreshes the contents of the machine from the underlying
@@ -553,6 +639,7 @@ func (m *Machine) SetProvisioned(id instance.Id, nonce string, characteristics *
 		InstanceId: id,
 		Arch:       characteristics.Arch,
 		Mem:        characteristics.Mem,
+		RootDisk:   characteristics.RootDisk,
 		CpuCores:   characteristics.CpuCores,
 		CpuPower:   characteristics.CpuPower,
 	}
@@ -674,6 +761,50 @@ func (m *Machine) Status() (status params.Status, info string, err error) {
 
 // SetStatus sets the status of the machine.
 func (m *Machine) SetStatus(status params.Status, info string) error {
+	if err := m.st.runTransaction(m.setStatusOps(status, info)); err != nil {
+		return fmt.Errorf("cannot set status of machine %q: %v", m, onAbort(err, errNotAlive))
+	}
+	return nil
+}
+
+// RetryProvisioning clears the error status of a machine that could
+// not be provisioned, so that the provisioner will try to start an
+// instance for it again.
+func (m *Machine) RetryProvisioning() (err error) {
+	defer utils.ErrorContextf(&err, "cannot retry provisioning of machine %v", m)
+	ops := []txn.Op{{
+		C:      m.st.machines.Name,
+		Id:     m.doc.Id,
+		Assert: notDeadDoc,
+	}, {
+		C:      m.st.instanceData.Name,
+		Id:     m.doc.Id,
+		Assert: txn.DocMissing,
+	}, {
+		C:      m.st.statuses.Name,
+		Id:     m.globalKey(),
+		Assert: D{{"status", params.StatusError}},
+		Update: D{{"$set", statusDoc{Status: params.StatusPending}}},
+	}}
+	if err := m.st.runTransaction(ops); err != txn.ErrAborted {
+		return err
+	}
+	if _, err := m.InstanceId(); err == nil {
+		return fmt.Errorf("machine is already provisioned")
+	} else if !IsNotProvisionedError(err) {
+		return err
+	}
+	if status, _, err := m.Status(); err != nil {
+		return err
+	} else if status != params.StatusError {
+		return fmt.Errorf("machine is not in an error state")
+	}
+	return errDead
+}
+
+// setStatusOps returns the operations needed to set the status
+// of the machine, asserting that the machine is not dead.
+func (m *Machine) setStatusOps(status params.Status, info string) []txn.Op {
 	if status == params.StatusError && info == "" {
 		panic("machine error status with no info")
 	}
@@ -684,19 +815,47 @@ func (m *Machine) SetStatus(status params.Status, info string) error {
 		Status:     status,
 		StatusInfo: info,
 	}
-	ops := []txn.Op{{
+	return []txn.Op{{
 		C:      m.st.machines.Name,
 		Id:     m.doc.Id,
 		Assert: notDeadDoc,
 	},
 		updateStatusOp(m.st, m.globalKey(), doc),
 	}
-	if err := m.st.runTransaction(ops); err != nil {
-		return fmt.Errorf("cannot set status of machine %q: %v", m, onAbort(err, errNotAlive))
+}
+
+// MachineStatus holds a status to be set on a machine
+// by SetMachineStatuses.
+type MachineStatus struct {
+	Machine *Machine
+	Status  params.Status
+	Info    string
+}
+
+// SetMachineStatuses sets the status of each given machine in a single
+// transaction, so that either every status is set or none is.
+func (st *State) SetMachineStatuses(statuses []MachineStatus) error {
+	var ops []txn.Op
+	var ids []string
+	for _, s := range statuses {
+		ops = append(ops, s.Machine.setStatusOps(s.Status, s.Info)...)
+		ids = append(ids, s.Machine.Id())
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	if err := st.runTransaction(ops); err != nil {
+		return fmt.Errorf("cannot set status of machines %s: %v", strings.Join(ids, ", "), onAbort(err, errNotAlive))
 	}
 	return nil
 }
 
+// IsManual returns whether the machine was added for an existing host
+// with AddManualMachine, rather than being started by the environment.
+func (m *Machine) IsManual() bool {
+	return m.doc.Manual
+}
+
 // Clean returns true if the machine does not have any deployed units or containers.
 func (m *Machine) Clean() bool {
 	return m.doc.Clean
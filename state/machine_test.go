@@ -5,6 +5,7 @@ package state_test
 
 import (
 	"sort"
+	"strings"
 	"time"
 
 	. "launchpad.net/gocheck"
@@ -15,6 +16,7 @@ import (
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/testing"
+	coretesting "launchpad.net/juju-core/testing"
 	"launchpad.net/juju-core/testing/checkers"
 	"launchpad.net/juju-core/version"
 )
@@ -57,6 +59,48 @@ func (s *MachineSuite) TestParentId(c *C) {
 	c.Assert(ok, Equals, true)
 }
 
+var parseMachineJobsTests = []struct {
+	arg  string
+	jobs []state.MachineJob
+	err  string
+}{{
+	arg:  "host-units",
+	jobs: []state.MachineJob{state.JobHostUnits},
+}, {
+	arg:  "host-units,manage-environ",
+	jobs: []state.MachineJob{state.JobHostUnits, state.JobManageEnviron},
+}, {
+	arg:  "manage-environ, manage-state",
+	jobs: []state.MachineJob{state.JobManageEnviron, state.JobManageState},
+}, {
+	arg: "",
+	err: `unknown machine job ""`,
+}, {
+	arg: "host-units,",
+	err: `unknown machine job ""`,
+}, {
+	arg: "host-units,walk-dog",
+	err: `unknown machine job "walk-dog"`,
+}, {
+	arg: "JobHostUnits",
+	err: `unknown machine job "JobHostUnits"`,
+}}
+
+func (s *MachineSuite) TestParseMachineJobs(c *C) {
+	for i, t := range parseMachineJobsTests {
+		c.Logf("test %d: %q", i, t.arg)
+		jobs, err := state.ParseMachineJobs(t.arg)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err)
+			c.Check(jobs, IsNil)
+			continue
+		}
+		c.Check(err, IsNil)
+		c.Check(jobs, DeepEquals, t.jobs)
+		c.Check(state.FormatMachineJobs(jobs), Equals, strings.Replace(t.arg, " ", "", -1))
+	}
+}
+
 func (s *MachineSuite) TestLifeJobManageEnviron(c *C) {
 	// A JobManageEnviron machine must never advance lifecycle.
 	m, err := s.State.AddMachine("series", state.JobManageEnviron)
@@ -222,6 +266,75 @@ func (s *MachineSuite) TestDestroyMachines(c *C) {
 	assertLife(m2, state.Dying)
 }
 
+func (s *MachineSuite) TestRemoveMachines(c *C) {
+	m0 := s.machine
+	m1, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	m2, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	m3, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	sch := s.AddTestingCharm(c, "wordpress")
+	wordpress, err := s.State.AddService("wordpress", sch)
+	c.Assert(err, IsNil)
+	u, err := wordpress.AddUnit()
+	c.Assert(err, IsNil)
+	err = u.AssignToMachine(m2)
+	c.Assert(err, IsNil)
+
+	for _, m := range []*state.Machine{m0, m1} {
+		err = m.EnsureDead()
+		c.Assert(err, IsNil)
+	}
+	// A machine with a unit assigned cannot be made dead.
+	err = m2.EnsureDead()
+	c.Assert(err, NotNil)
+
+	errs, err := s.State.RemoveMachines("0", "1", "2", "3", "0", "42")
+	c.Assert(err, IsNil)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["2"], ErrorMatches, "cannot remove machine 2: machine is not dead")
+	c.Assert(errs["3"], ErrorMatches, "cannot remove machine 3: machine is not dead")
+	for _, m := range []*state.Machine{m0, m1} {
+		err = m.Refresh()
+		c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+		_, err = m.HardwareCharacteristics()
+		c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+		_, err = m.Containers()
+		c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+	}
+	for _, m := range []*state.Machine{m2, m3} {
+		err = m.Refresh()
+		c.Assert(err, IsNil)
+		c.Assert(m.Life(), Equals, state.Alive)
+	}
+
+	// Removing machines that are already gone is not an error.
+	errs, err = s.State.RemoveMachines("0", "1")
+	c.Assert(err, IsNil)
+	c.Assert(errs, HasLen, 0)
+}
+
+func (s *MachineSuite) TestRemoveMachinesAbort(c *C) {
+	m0 := s.machine
+	m1, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	for _, m := range []*state.Machine{m0, m1} {
+		err = m.EnsureDead()
+		c.Assert(err, IsNil)
+	}
+
+	defer state.SetBeforeHooks(c, s.State, func() {
+		c.Assert(m0.Remove(), IsNil)
+	}).Check()
+	errs, err := s.State.RemoveMachines("0", "1")
+	c.Assert(err, IsNil)
+	c.Assert(errs, HasLen, 0)
+	err = m1.Refresh()
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
 func (s *MachineSuite) TestMachineSetAgentAlive(c *C) {
 	alive, err := s.machine.AgentAlive()
 	c.Assert(err, IsNil)
@@ -359,9 +472,11 @@ func (s *MachineSuite) TestMachineSetProvisionedUpdatesCharacteristics(c *C) {
 	c.Assert(errors.IsNotFoundError(err), Equals, true)
 	arch := "amd64"
 	mem := uint64(4096)
+	rootDisk := uint64(8192)
 	expected := &instance.HardwareCharacteristics{
-		Arch: &arch,
-		Mem:  &mem,
+		Arch:     &arch,
+		Mem:      &mem,
+		RootDisk: &rootDisk,
 	}
 	err = s.machine.SetProvisioned("umbrella/0", "fake_nonce", expected)
 	c.Assert(err, IsNil)
@@ -877,6 +992,24 @@ func (s *MachineSuite) TestConstraintsLifecycle(c *C) {
 	c.Assert(err, ErrorMatches, `constraints not found`)
 }
 
+func (s *MachineSuite) TestSetAddresses(c *C) {
+	c.Assert(s.machine.Addresses(), HasLen, 0)
+
+	addresses := []string{"10.0.0.1", "example.com"}
+	err := s.machine.SetAddresses(addresses)
+	c.Assert(err, IsNil)
+	c.Assert(s.machine.Addresses(), DeepEquals, addresses)
+
+	m, err := s.State.Machine(s.machine.Id())
+	c.Assert(err, IsNil)
+	c.Assert(m.Addresses(), DeepEquals, addresses)
+
+	err = m.EnsureDead()
+	c.Assert(err, IsNil)
+	err = m.SetAddresses([]string{"10.0.0.2"})
+	c.Assert(err, ErrorMatches, `cannot set addresses of machine 0: not found or dead`)
+}
+
 func (s *MachineSuite) TestGetSetStatusWhileAlive(c *C) {
 	failError := func() { s.machine.SetStatus(params.StatusError, "") }
 	c.Assert(failError, PanicMatches, "machine error status with no info")
@@ -903,6 +1036,42 @@ func (s *MachineSuite) TestGetSetStatusWhileAlive(c *C) {
 	c.Assert(info, Equals, "provisioning failed")
 }
 
+func (s *MachineSuite) TestSetMachineStatuses(c *C) {
+	m1, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	err = s.State.SetMachineStatuses([]state.MachineStatus{
+		{Machine: s.machine, Status: params.StatusStarted},
+		{Machine: m1, Status: params.StatusError, Info: "broken"},
+	})
+	c.Assert(err, IsNil)
+	status, info, err := s.machine.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusStarted)
+	c.Assert(info, Equals, "")
+	status, info, err = m1.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+	c.Assert(info, Equals, "broken")
+}
+
+func (s *MachineSuite) TestSetMachineStatusesAllOrNothing(c *C) {
+	m1, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	defer state.SetBeforeHooks(c, s.State, func() {
+		c.Assert(m1.EnsureDead(), IsNil)
+	}).Check()
+	err = s.State.SetMachineStatuses([]state.MachineStatus{
+		{Machine: s.machine, Status: params.StatusStarted},
+		{Machine: m1, Status: params.StatusStarted},
+	})
+	c.Assert(err, ErrorMatches, `cannot set status of machines 0, 1: not found or not alive`)
+
+	// The status of the live machine was not set either.
+	status, _, err := s.machine.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusPending)
+}
+
 func (s *MachineSuite) TestGetSetStatusWhileNotAlive(c *C) {
 	// When Dying set/get should work.
 	err := s.machine.Destroy()
@@ -931,3 +1100,100 @@ func (s *MachineSuite) TestGetSetStatusWhileNotAlive(c *C) {
 	_, _, err = s.machine.Status()
 	c.Assert(err, ErrorMatches, "status not found")
 }
+
+func (s *MachineSuite) TestRetryProvisioning(c *C) {
+	err := s.machine.RetryProvisioning()
+	c.Assert(err, ErrorMatches, `cannot retry provisioning of machine 0: machine is not in an error state`)
+
+	err = s.machine.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	err = s.machine.RetryProvisioning()
+	c.Assert(err, IsNil)
+	status, info, err := s.machine.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusPending)
+	c.Assert(info, Equals, "")
+
+	err = s.machine.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	err = s.machine.SetProvisioned("umbrella/0", "fake_nonce", nil)
+	c.Assert(err, IsNil)
+	err = s.machine.RetryProvisioning()
+	c.Assert(err, ErrorMatches, `cannot retry provisioning of machine 0: machine is already provisioned`)
+}
+
+func (s *MachineSuite) TestRetryProvisioningWhenDead(c *C) {
+	err := s.machine.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	err = s.machine.EnsureDead()
+	c.Assert(err, IsNil)
+	err = s.machine.RetryProvisioning()
+	c.Assert(err, ErrorMatches, `cannot retry provisioning of machine 0: not found or dead`)
+}
+
+func (s *MachineSuite) TestWatchEnvironMachineRetries(c *C) {
+	w := s.State.WatchEnvironMachineRetries()
+	defer testing.AssertStop(c, w)
+	wc := testing.NewStringsWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	// Adding machines and setting errors are not retries.
+	m1, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	err = s.machine.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	err = m1.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	wc.AssertNoChange()
+
+	err = s.machine.RetryProvisioning()
+	c.Assert(err, IsNil)
+	wc.AssertOneChange(s.machine.Id())
+
+	// Containers are not reported.
+	addParams := state.AddMachineParams{
+		ParentId:      m1.Id(),
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	}
+	container, err := s.State.AddMachineWithConstraints(&addParams)
+	c.Assert(err, IsNil)
+	err = container.SetStatus(params.StatusError, "cannot start instance")
+	c.Assert(err, IsNil)
+	err = container.RetryProvisioning()
+	c.Assert(err, IsNil)
+	wc.AssertNoChange()
+
+	err = m1.RetryProvisioning()
+	c.Assert(err, IsNil)
+	wc.AssertOneChange(m1.Id())
+}
+
+func (s *MachineSuite) TestWatchEnvironMachineRetriesStopsWhenStateDies(c *C) {
+	proxy := coretesting.NewTCPProxy(c, coretesting.MgoAddr)
+	defer proxy.Close()
+	info := state.TestingStateInfo()
+	info.Addrs = []string{proxy.Addr()}
+	st, err := state.Open(info, state.TestingDialOpts())
+	c.Assert(err, IsNil)
+	defer st.Close()
+	w := st.WatchEnvironMachineRetries()
+	select {
+	case _, ok := <-w.Changes():
+		c.Assert(ok, Equals, true)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("watcher did not send its initial event")
+	}
+
+	// Break the connection, so that the state watcher dies.
+	c.Assert(proxy.Close(), IsNil)
+	st.StartSync()
+	select {
+	case _, ok := <-w.Changes():
+		c.Assert(ok, Equals, false)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("watcher did not stop when the state watcher died")
+	}
+	c.Assert(w.Err(), NotNil)
+}
@@ -169,6 +169,23 @@ var indexes = []struct {
 	{"units", []string{"principal"}},
 	{"units", []string{"machineid"}},
 	{"users", []string{"name"}},
+	{"settingshistory", []string{"key", "seq"}},
+}
+
+// EnsureIndexes creates the indexes used by the state in the juju
+// database reached through session, unless they already exist, so
+// calling it more than once has no further effect. It is called
+// whenever a state is opened, including by Initialize, so that
+// databases created by older versions gain any indexes added since.
+func EnsureIndexes(session *mgo.Session) error {
+	db := session.DB("juju")
+	for _, item := range indexes {
+		index := mgo.Index{Key: item.key}
+		if err := db.C(item.collection).EnsureIndex(index); err != nil {
+			return fmt.Errorf("cannot create database index: %v", err)
+		}
+	}
+	return nil
 }
 
 // The capped collection used for transaction logs defaults to 10MB.
@@ -211,26 +228,27 @@ func newState(session *mgo.Session, info *Info) (*State, error) {
 		}
 	}
 	st := &State{
-		info:           info,
-		db:             db,
-		environments:   db.C("environments"),
-		charms:         db.C("charms"),
-		machines:       db.C("machines"),
-		containerRefs:  db.C("containerRefs"),
-		instanceData:   db.C("instanceData"),
-		relations:      db.C("relations"),
-		relationScopes: db.C("relationscopes"),
-		services:       db.C("services"),
-		minUnits:       db.C("minunits"),
-		settings:       db.C("settings"),
-		settingsrefs:   db.C("settingsrefs"),
-		constraints:    db.C("constraints"),
-		units:          db.C("units"),
-		users:          db.C("users"),
-		presence:       pdb.C("presence"),
-		cleanups:       db.C("cleanups"),
-		annotations:    db.C("annotations"),
-		statuses:       db.C("statuses"),
+		info:            info,
+		db:              db,
+		environments:    db.C("environments"),
+		charms:          db.C("charms"),
+		machines:        db.C("machines"),
+		containerRefs:   db.C("containerRefs"),
+		instanceData:    db.C("instanceData"),
+		relations:       db.C("relations"),
+		relationScopes:  db.C("relationscopes"),
+		services:        db.C("services"),
+		minUnits:        db.C("minunits"),
+		settings:        db.C("settings"),
+		settingsrefs:    db.C("settingsrefs"),
+		settingsHistory: db.C("settingshistory"),
+		constraints:     db.C("constraints"),
+		units:           db.C("units"),
+		users:           db.C("users"),
+		presence:        pdb.C("presence"),
+		cleanups:        db.C("cleanups"),
+		annotations:     db.C("annotations"),
+		statuses:        db.C("statuses"),
 	}
 	log := db.C("txns.log")
 	logInfo := mgo.CollectionInfo{Capped: true, MaxBytes: logSize}
@@ -244,11 +262,8 @@ func newState(session *mgo.Session, info *Info) (*State, error) {
 	st.runner.ChangeLog(db.C("txns.log"))
 	st.watcher = watcher.New(db.C("txns.log"))
 	st.pwatcher = presence.NewWatcher(pdb.C("presence"))
-	for _, item := range indexes {
-		index := mgo.Index{Key: item.key}
-		if err := db.C(item.collection).EnsureIndex(index); err != nil {
-			return nil, fmt.Errorf("cannot create database index: %v", err)
-		}
+	if err := EnsureIndexes(session); err != nil {
+		return nil, err
 	}
 	st.transactionHooks = make(chan ([]transactionHook), 1)
 	st.transactionHooks <- nil
@@ -288,6 +303,21 @@ func (st *State) CACert() (cert []byte) {
 	return append(cert, st.info.CACert...)
 }
 
+// Dead returns a channel that is closed when the state stops delivering
+// watcher events, either because it has been closed or because its
+// connection to the database has been lost. Workers can wait on it to
+// stop promptly instead of failing on their next operation.
+func (st *State) Dead() <-chan struct{} {
+	return st.watcher.Dead()
+}
+
+// Err returns the reason why the channel returned by Dead was closed:
+// nil if the state was closed, or the error that broke its connection.
+// It returns tomb.ErrStillAlive if the state is still alive.
+func (st *State) Err() error {
+	return st.watcher.Err()
+}
+
 func (st *State) Close() error {
 	err1 := st.watcher.Stop()
 	err2 := st.pwatcher.Stop()
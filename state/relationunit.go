@@ -4,7 +4,6 @@
 package state
 
 import (
-	stderrors "errors"
 	"fmt"
 	"strings"
 
@@ -13,6 +12,7 @@ import (
 
 	"launchpad.net/juju-core/charm"
 	errors "launchpad.net/juju-core/errors"
+	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/utils"
 )
 
@@ -44,12 +44,12 @@ func (ru *RelationUnit) PrivateAddress() (string, bool) {
 
 // ErrCannotEnterScope indicates that a relation unit failed to enter its scope
 // due to either the unit or the relation not being Alive.
-var ErrCannotEnterScope = stderrors.New("cannot enter scope: unit or relation is not alive")
+var ErrCannotEnterScope error = &codedError{"cannot enter scope: unit or relation is not alive", params.CodeCannotEnterScope}
 
 // ErrCannotEnterScopeYet indicates that a relation unit failed to enter its
 // scope due to a required and pre-existing subordinate unit that is not Alive.
 // Once that subordinate has been removed, a new one can be created.
-var ErrCannotEnterScopeYet = stderrors.New("cannot enter scope yet: non-alive subordinate unit has not been removed")
+var ErrCannotEnterScopeYet error = &codedError{"cannot enter scope yet: non-alive subordinate unit has not been removed", params.CodeCannotEnterScopeYet}
 
 // EnterScope ensures that the unit has entered its scope in the relation.
 // When the unit has already entered its relation scope, EnterScope will report
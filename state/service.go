@@ -620,7 +620,7 @@ func (s *Service) AddUnit() (unit *Unit, err error) {
 	return s.Unit(name)
 }
 
-var ErrExcessiveContention = stderrors.New("state changing too quickly; try again soon")
+var ErrExcessiveContention error = &codedError{"state changing too quickly; try again soon", params.CodeExcessiveContention}
 
 // removeUnitOps returns the operations necessary to remove the supplied unit,
 // assuming the supplied asserts apply to the unit document.
@@ -713,6 +713,22 @@ func (s *Service) AllUnits() (units []*Unit, err error) {
 	return units, nil
 }
 
+// UnitCount returns the number of units of the service, without
+// loading them.
+func (s *Service) UnitCount() (int, error) {
+	var doc struct {
+		UnitCount int
+	}
+	err := s.st.services.FindId(s.doc.Name).Select(D{{"unitcount", 1}}).One(&doc)
+	if err == mgo.ErrNotFound {
+		return 0, errors.NotFoundf("service %q", s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot count units of service %q: %v", s, err)
+	}
+	return doc.UnitCount, nil
+}
+
 // Relations returns a Relation for every relation the service is in.
 func (s *Service) Relations() (relations []*Relation, err error) {
 	defer utils.ErrorContextf(&err, "can't get relations for service %q", s)
@@ -740,6 +756,17 @@ func (s *Service) ConfigSettings() (charm.Settings, error) {
 // UpdateConfigSettings changes a service's charm config settings. Values set
 // to nil will be deleted; unknown and invalid values will return an error.
 func (s *Service) UpdateConfigSettings(changes charm.Settings) error {
+	return s.updateConfigSettings(changes, "", false)
+}
+
+// UpdateConfigSettingsAudited is like UpdateConfigSettings, but also
+// records the changes, and the tag of the entity that made them, in
+// the service's config history. See ConfigHistory.
+func (s *Service) UpdateConfigSettingsAudited(changes charm.Settings, actorTag string) error {
+	return s.updateConfigSettings(changes, actorTag, true)
+}
+
+func (s *Service) updateConfigSettings(changes charm.Settings, actorTag string, audit bool) error {
 	charm, _, err := s.Charm()
 	if err != nil {
 		return err
@@ -763,10 +790,25 @@ func (s *Service) UpdateConfigSettings(changes charm.Settings) error {
 			node.Set(name, value)
 		}
 	}
-	_, err = node.Write()
+	if audit {
+		_, err = node.WriteAudited(actorTag)
+	} else {
+		_, err = node.Write()
+	}
 	return err
 }
 
+// ConfigHistory returns the audited changes made to the service's
+// charm config settings, most recent first. At most limit changes
+// are returned, unless limit is zero.
+func (s *Service) ConfigHistory(limit int) ([]SettingsChange, error) {
+	node, err := readSettings(s.st, s.settingsKey())
+	if err != nil {
+		return nil, err
+	}
+	return node.History(limit)
+}
+
 var ErrSubordinateConstraints = stderrors.New("constraints do not apply to subordinate services")
 
 // Constraints returns the current service constraints.
@@ -827,7 +869,7 @@ func settingsIncRefOp(st *State, serviceName string, curl *charm.URL, canCreate
 // settingsDecRefOps returns a list of operations that decrement the
 // ref count of the service settings identified by serviceName and
 // curl. If the ref count is set to zero, the appropriate setting and
-// ref count documents will both be deleted.
+// ref count documents, and the settings' history, will all be deleted.
 func settingsDecRefOps(st *State, serviceName string, curl *charm.URL) ([]txn.Op, error) {
 	key := serviceSettingsKey(serviceName, curl)
 	var doc settingsRefsDoc
@@ -837,7 +879,11 @@ func settingsDecRefOps(st *State, serviceName string, curl *charm.URL) ([]txn.Op
 		return nil, err
 	}
 	if doc.RefCount == 1 {
-		return []txn.Op{{
+		historyOps, err := removeHistoryOps(st, key)
+		if err != nil {
+			return nil, err
+		}
+		return append([]txn.Op{{
 			C:      st.settingsrefs.Name,
 			Id:     key,
 			Assert: D{{"refcount", 1}},
@@ -846,7 +892,7 @@ func settingsDecRefOps(st *State, serviceName string, curl *charm.URL) ([]txn.Op
 			C:      st.settings.Name,
 			Id:     key,
 			Remove: true,
-		}}, nil
+		}}, historyOps...), nil
 	}
 	return []txn.Op{{
 		C:      st.settingsrefs.Name,
@@ -395,6 +395,32 @@ func (s *ServiceSuite) TestUpdateConfigSettings(c *C) {
 	}
 }
 
+func (s *ServiceSuite) TestUpdateConfigSettingsAudited(c *C) {
+	svc, err := s.State.AddService("dummy-service", s.AddTestingCharm(c, "dummy"))
+	c.Assert(err, IsNil)
+	err = svc.UpdateConfigSettings(charm.Settings{"title": "unaudited"})
+	c.Assert(err, IsNil)
+	err = svc.UpdateConfigSettingsAudited(charm.Settings{"username": "bob"}, "user-admin")
+	c.Assert(err, IsNil)
+	err = svc.UpdateConfigSettingsAudited(charm.Settings{"username": nil}, "user-other")
+	c.Assert(err, IsNil)
+
+	settings, err := svc.ConfigSettings()
+	c.Assert(err, IsNil)
+	c.Assert(settings, DeepEquals, charm.Settings{"title": "unaudited"})
+	history, err := svc.ConfigHistory(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Assert(history[0].ActorTag, Equals, "user-other")
+	c.Assert(history[0].Changes, DeepEquals, []state.ItemChange{
+		{state.ItemDeleted, "username", "bob", nil},
+	})
+	c.Assert(history[1].ActorTag, Equals, "user-admin")
+	c.Assert(history[1].Changes, DeepEquals, []state.ItemChange{
+		{state.ItemAdded, "username", nil, "bob"},
+	})
+}
+
 func (s *ServiceSuite) TestSettingsRefCountWorks(c *C) {
 	oldCh := s.AddConfigCharm(c, "wordpress", emptyConfig, 1)
 	newCh := s.AddConfigCharm(c, "wordpress", emptyConfig, 2)
@@ -464,6 +490,49 @@ func (s *ServiceSuite) TestSettingsRefCountWorks(c *C) {
 	assertNoRef(newCh)
 }
 
+func (s *ServiceSuite) TestSettingsRemovedWithLastRef(c *C) {
+	oldCh := s.AddConfigCharm(c, "wordpress", stringConfig, 1)
+	newCh := s.AddConfigCharm(c, "wordpress", stringConfig, 2)
+	svcName := "mywp"
+	assertSettings := func(sch *state.Charm, exist bool, history int) {
+		ok, err := state.ServiceSettingsExist(s.State, svcName, sch.URL())
+		c.Assert(err, IsNil)
+		c.Assert(ok, Equals, exist)
+		n, err := state.ServiceSettingsHistoryCount(s.State, svcName, sch.URL())
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, history)
+	}
+
+	svc, err := s.State.AddService(svcName, oldCh)
+	c.Assert(err, IsNil)
+	settings, err := state.ServiceSettings(s.State, svcName, oldCh.URL())
+	c.Assert(err, IsNil)
+	settings.Set("key", "value")
+	_, err = settings.WriteAudited("user-admin")
+	c.Assert(err, IsNil)
+	assertSettings(oldCh, true, 1)
+
+	// A unit keeps the old settings alive after the service
+	// moves to the new charm.
+	u, err := svc.AddUnit()
+	c.Assert(err, IsNil)
+	err = u.SetCharmURL(oldCh.URL())
+	c.Assert(err, IsNil)
+	err = svc.SetCharm(newCh, false)
+	c.Assert(err, IsNil)
+	assertSettings(oldCh, true, 1)
+	assertSettings(newCh, true, 0)
+
+	// Dropping the last reference removes the settings and
+	// their history in the same transaction.
+	err = u.SetCharmURL(newCh.URL())
+	c.Assert(err, IsNil)
+	_, err = state.ServiceSettingsRefCount(s.State, svcName, oldCh.URL())
+	c.Assert(err, Equals, mgo.ErrNotFound)
+	assertSettings(oldCh, false, 0)
+	assertSettings(newCh, true, 0)
+}
+
 const mysqlBaseMeta = `
 name: mysql
 summary: "Database engine"
@@ -897,6 +966,36 @@ func (s *ServiceSuite) TestReadUnitWhenDying(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *ServiceSuite) TestUnitCount(c *C) {
+	n, err := s.mysql.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+
+	unit0, err := s.mysql.AddUnit()
+	c.Assert(err, IsNil)
+	_, err = s.mysql.AddUnit()
+	c.Assert(err, IsNil)
+	n, err = s.mysql.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+
+	err = unit0.EnsureDead()
+	c.Assert(err, IsNil)
+	err = unit0.Remove()
+	c.Assert(err, IsNil)
+	n, err = s.mysql.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+
+	// Once the service is gone, its units cannot be counted.
+	removeAllUnits(c, s.mysql)
+	err = s.mysql.Destroy()
+	c.Assert(err, IsNil)
+	_, err = s.mysql.UnitCount()
+	c.Assert(err, ErrorMatches, `service "mysql" not found`)
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
 func (s *ServiceSuite) TestDestroySimple(c *C) {
 	err := s.mysql.Destroy()
 	c.Assert(err, IsNil)
@@ -6,8 +6,10 @@ package state
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
 	"labix.org/v2/mgo/txn"
 
 	"launchpad.net/juju-core/errors"
@@ -20,26 +22,27 @@ const (
 )
 
 // ItemChange represents the change of an item in a settings.
+// Its serialized forms are stable, so that changes can be logged
+// and persisted.
 type ItemChange struct {
-	Type     int
-	Key      string
-	OldValue interface{}
-	NewValue interface{}
+	Type     int         `json:"type" yaml:"type"`
+	Key      string      `json:"key" yaml:"key"`
+	OldValue interface{} `json:"old,omitempty" yaml:"old,omitempty"`
+	NewValue interface{} `json:"new,omitempty" yaml:"new,omitempty"`
 }
 
 // String returns the item change in a readable format.
 func (ic *ItemChange) String() string {
 	switch ic.Type {
 	case ItemAdded:
-		return fmt.Sprintf("setting added: %v = %v", ic.Key, ic.NewValue)
+		return fmt.Sprintf("added %v=%v", ic.Key, ic.NewValue)
 	case ItemModified:
-		return fmt.Sprintf("setting modified: %v = %v (was %v)",
-			ic.Key, ic.NewValue, ic.OldValue)
+		return fmt.Sprintf("modified %v: %v -> %v", ic.Key, ic.OldValue, ic.NewValue)
 	case ItemDeleted:
-		return fmt.Sprintf("setting deleted: %v (was %v)", ic.Key, ic.OldValue)
+		return fmt.Sprintf("deleted %v", ic.Key)
 	}
-	return fmt.Sprintf("unknown setting change type %d: %v = %v (was %v)",
-		ic.Type, ic.Key, ic.NewValue, ic.OldValue)
+	return fmt.Sprintf("unknown change type %d to %v: %v -> %v",
+		ic.Type, ic.Key, ic.OldValue, ic.NewValue)
 }
 
 // itemChangeSlice contains a slice of item changes in a config node.
@@ -129,6 +132,17 @@ func cacheKeys(caches ...map[string]interface{}) map[string]bool {
 // as a delta applied on top of the latest version of the node, to prevent
 // overwriting unrelated changes made to the node since it was last read.
 func (c *Settings) Write() ([]ItemChange, error) {
+	return c.write("", false)
+}
+
+// WriteAudited is like Write, but also records the changes made, along
+// with the time and the tag of the entity that made them, in the
+// node's history. See History.
+func (c *Settings) WriteAudited(actorTag string) ([]ItemChange, error) {
+	return c.write(actorTag, true)
+}
+
+func (c *Settings) write(actorTag string, audit bool) ([]ItemChange, error) {
 	changes := []ItemChange{}
 	updates := map[string]interface{}{}
 	deletions := map[string]int{}
@@ -158,24 +172,222 @@ func (c *Settings) Write() ([]ItemChange, error) {
 		return []ItemChange{}, nil
 	}
 	sort.Sort(itemChangeSlice(changes))
+	update := D{
+		{"$set", updates},
+		{"$unset", deletions},
+	}
+	var err error
+	if audit {
+		err = c.writeAudited(actorTag, update, changes)
+	} else {
+		err = c.st.runTransaction([]txn.Op{{
+			C:      c.st.settings.Name,
+			Id:     c.key,
+			Assert: txn.DocExists,
+			Update: update,
+		}})
+		if err == txn.ErrAborted {
+			err = errors.NotFoundf("settings")
+		}
+	}
+	if errors.IsNotFoundError(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot write settings: %v", err)
+	}
+	c.disk = copyMap(c.core)
+	return changes, nil
+}
+
+// writeAudited applies update to the node and records changes in its
+// history. Every audited write changes the node itself, so asserting
+// that the node is unchanged since its history was read ensures that
+// concurrent writers cannot push the history beyond its bound.
+func (c *Settings) writeAudited(actorTag string, update D, changes []ItemChange) error {
+	seq, err := c.st.sequence("settingshistory")
+	if err != nil {
+		return fmt.Errorf("cannot record settings history: %v", err)
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		_, txnRevno, err := readSettingsDoc(c.st, c.key)
+		if err == mgo.ErrNotFound {
+			return errors.NotFoundf("settings")
+		}
+		if err != nil {
+			return err
+		}
+		ops := []txn.Op{{
+			C:      c.st.settings.Name,
+			Id:     c.key,
+			Assert: D{{"txn-revno", txnRevno}},
+			Update: update,
+		}}
+		historyOps, err := c.historyOps(seq, actorTag, changes)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, historyOps...)
+		if err := c.st.runTransaction(ops); err != txn.ErrAborted {
+			return err
+		}
+	}
+	return ErrExcessiveContention
+}
+
+// CompareAndSet atomically sets key to new, but only if its value as
+// currently stored is expected; a nil expected value means the key must
+// be unset, and a nil new value unsets it. Unlike Write, which merges
+// concurrent changes, it reports false without changing anything when
+// the stored value differs. The node's cached value for key is updated
+// to reflect the outcome.
+func (c *Settings) CompareAndSet(key string, expected, new interface{}) (bool, error) {
+	var assert interface{} = D{{key, expected}}
+	if expected == nil {
+		assert = D{{key, D{{"$exists", false}}}}
+	}
+	var update D
+	if new == nil {
+		update = D{{"$unset", D{{key, 1}}}}
+	} else {
+		update = D{{"$set", D{{key, new}}}}
+	}
 	ops := []txn.Op{{
 		C:      c.st.settings.Name,
 		Id:     c.key,
-		Assert: txn.DocExists,
-		Update: D{
-			{"$set", updates},
-			{"$unset", deletions},
-		},
+		Assert: assert,
+		Update: update,
 	}}
 	err := c.st.runTransaction(ops)
 	if err == txn.ErrAborted {
-		return nil, errors.NotFoundf("settings")
+		config, _, err := readSettingsDoc(c.st, c.key)
+		if err == mgo.ErrNotFound {
+			return false, errors.NotFoundf("settings")
+		}
+		if err != nil {
+			return false, fmt.Errorf("cannot read settings: %v", err)
+		}
+		c.setCached(key, config[key])
+		return false, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("cannot write settings: %v", err)
+		return false, fmt.Errorf("cannot write settings: %v", err)
 	}
-	c.disk = copyMap(c.core)
-	return changes, nil
+	c.setCached(key, new)
+	return true, nil
+}
+
+// setCached records value as both the stored and the
+// local value of key; a nil value means key is unset.
+func (c *Settings) setCached(key string, value interface{}) {
+	if c.disk == nil {
+		c.disk = make(map[string]interface{})
+	}
+	if value == nil {
+		delete(c.disk, key)
+		delete(c.core, key)
+		return
+	}
+	c.disk[key] = value
+	c.core[key] = value
+}
+
+// maxSettingsHistory holds the number of audited writes whose changes
+// are retained in the history of each settings node.
+var maxSettingsHistory = 100
+
+// SettingsChange records the changes made to settings by an
+// audited write.
+type SettingsChange struct {
+	Time     time.Time
+	ActorTag string
+	Changes  []ItemChange
+}
+
+// settingsHistoryDoc holds a SettingsChange made to the settings with
+// the given key. Seq orders the changes made to all settings.
+type settingsHistoryDoc struct {
+	Id       bson.ObjectId `bson:"_id"`
+	Key      string
+	Seq      int
+	Time     time.Time
+	ActorTag string
+	Changes  []ItemChange
+}
+
+// historyOps returns the operations that add the given changes to the
+// node's history, and remove the oldest entries that would no longer
+// be retained.
+func (c *Settings) historyOps(seq int, actorTag string, changes []ItemChange) ([]txn.Op, error) {
+	doc := &settingsHistoryDoc{
+		Id:       bson.NewObjectId(),
+		Key:      c.key,
+		Seq:      seq,
+		Time:     time.Now(),
+		ActorTag: actorTag,
+		Changes:  changes,
+	}
+	ops := []txn.Op{{
+		C:      c.st.settingsHistory.Name,
+		Id:     doc.Id,
+		Assert: txn.DocMissing,
+		Insert: doc,
+	}}
+	var old []settingsHistoryDoc
+	query := c.st.settingsHistory.Find(D{{"key", c.key}}).Sort("-seq")
+	err := query.Skip(maxSettingsHistory - 1).Select(D{{"_id", 1}}).All(&old)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read settings history: %v", err)
+	}
+	for _, doc := range old {
+		ops = append(ops, txn.Op{
+			C:      c.st.settingsHistory.Name,
+			Id:     doc.Id,
+			Remove: true,
+		})
+	}
+	return ops, nil
+}
+
+// removeHistoryOps returns the operations that remove the history of
+// the settings identified by key, for use when the settings
+// themselves are removed.
+func removeHistoryOps(st *State, key string) ([]txn.Op, error) {
+	var docs []settingsHistoryDoc
+	err := st.settingsHistory.Find(D{{"key", key}}).Select(D{{"_id", 1}}).All(&docs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read settings history: %v", err)
+	}
+	ops := make([]txn.Op, len(docs))
+	for i, doc := range docs {
+		ops[i] = txn.Op{
+			C:      st.settingsHistory.Name,
+			Id:     doc.Id,
+			Remove: true,
+		}
+	}
+	return ops, nil
+}
+
+// History returns the changes recorded by audited writes to the
+// node, most recent first. At most limit changes are returned,
+// unless limit is zero. The history of a node is bounded, so only
+// the most recent changes are available.
+func (c *Settings) History(limit int) ([]SettingsChange, error) {
+	var docs []settingsHistoryDoc
+	query := c.st.settingsHistory.Find(D{{"key", c.key}}).Sort("-seq")
+	if err := query.Limit(limit).All(&docs); err != nil {
+		return nil, fmt.Errorf("cannot read settings history: %v", err)
+	}
+	history := make([]SettingsChange, len(docs))
+	for i, doc := range docs {
+		history[i] = SettingsChange{
+			Time:     doc.Time,
+			ActorTag: doc.ActorTag,
+			Changes:  doc.Changes,
+		}
+	}
+	return history, nil
 }
 
 func newSettings(st *State, key string) *Settings {
@@ -4,9 +4,11 @@
 package state
 
 import (
+	"encoding/json"
 	"time"
 
 	. "launchpad.net/gocheck"
+	"launchpad.net/goyaml"
 
 	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/testing"
@@ -402,3 +404,276 @@ func (s *SettingsSuite) TestWriteTwice(c *C) {
 	c.Assert(nodeOne.disk, DeepEquals, nodeTwo.disk)
 	c.Assert(nodeOne.core, DeepEquals, nodeTwo.core)
 }
+
+func (s *SettingsSuite) TestCompareAndSet(c *C) {
+	node, err := createSettings(s.state, s.key, map[string]interface{}{"leader": "a"})
+	c.Assert(err, IsNil)
+
+	ok, err := node.CompareAndSet("leader", "a", "b")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{"leader": "b"})
+
+	other, err := readSettings(s.state, s.key)
+	c.Assert(err, IsNil)
+	c.Assert(other.Map(), DeepEquals, map[string]interface{}{"leader": "b"})
+
+	// A nil new value unsets the key.
+	ok, err = node.CompareAndSet("leader", "b", nil)
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{})
+}
+
+func (s *SettingsSuite) TestCompareAndSetValueChanged(c *C) {
+	nodeOne, err := createSettings(s.state, s.key, map[string]interface{}{"leader": "a"})
+	c.Assert(err, IsNil)
+	nodeTwo, err := readSettings(s.state, s.key)
+	c.Assert(err, IsNil)
+
+	ok, err := nodeTwo.CompareAndSet("leader", "a", "c")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+
+	// nodeOne still believes the leader is "a"; the set must fail
+	// rather than merge, and leave the stored value alone.
+	ok, err = nodeOne.CompareAndSet("leader", "a", "b")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+	c.Assert(nodeOne.Map(), DeepEquals, map[string]interface{}{"leader": "c"})
+
+	err = nodeTwo.Read()
+	c.Assert(err, IsNil)
+	c.Assert(nodeTwo.Map(), DeepEquals, map[string]interface{}{"leader": "c"})
+}
+
+func (s *SettingsSuite) TestCompareAndSetMissingKey(c *C) {
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+
+	ok, err := node.CompareAndSet("leader", "a", "b")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{})
+
+	ok, err = node.CompareAndSet("leader", nil, "a")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{"leader": "a"})
+
+	ok, err = node.CompareAndSet("leader", nil, "b")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{"leader": "a"})
+}
+
+func (s *SettingsSuite) TestCompareAndSetMissingSettings(c *C) {
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+	err = removeSettings(s.state, s.key)
+	c.Assert(err, IsNil)
+
+	ok, err := node.CompareAndSet("leader", nil, "a")
+	c.Assert(err, ErrorMatches, "settings not found")
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *SettingsSuite) TestWriteAuditedRecordsHistory(c *C) {
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+	history, err := node.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 0)
+
+	before := time.Now().Add(-time.Second)
+	node.Set("alpha", "beta")
+	node.Set("one", 1)
+	_, err = node.WriteAudited("user-admin")
+	c.Assert(err, IsNil)
+
+	// Unaudited writes are not recorded.
+	node.Set("one", 2)
+	_, err = node.Write()
+	c.Assert(err, IsNil)
+
+	node.Delete("alpha")
+	_, err = node.WriteAudited("unit-wordpress-0")
+	c.Assert(err, IsNil)
+
+	// Writes with no changes are not recorded.
+	_, err = node.WriteAudited("unit-wordpress-0")
+	c.Assert(err, IsNil)
+
+	history, err = node.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Assert(history[0].ActorTag, Equals, "unit-wordpress-0")
+	c.Assert(history[0].Changes, DeepEquals, []ItemChange{
+		{ItemDeleted, "alpha", "beta", nil},
+	})
+	c.Assert(history[1].ActorTag, Equals, "user-admin")
+	c.Assert(history[1].Changes, DeepEquals, []ItemChange{
+		{ItemAdded, "alpha", nil, "beta"},
+		{ItemAdded, "one", nil, 1},
+	})
+	for _, change := range history {
+		c.Assert(change.Time.After(before), Equals, true)
+	}
+	c.Assert(history[0].Time.Before(history[1].Time), Equals, false)
+
+	// History is kept per node.
+	other, err := createSettings(s.state, "other", nil)
+	c.Assert(err, IsNil)
+	history, err = other.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 0)
+}
+
+func (s *SettingsSuite) TestHistoryIsBounded(c *C) {
+	defer func(old int) { maxSettingsHistory = old }(maxSettingsHistory)
+	maxSettingsHistory = 3
+
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+	for i := 0; i < 5; i++ {
+		node.Set("count", i)
+		_, err = node.WriteAudited("user-admin")
+		c.Assert(err, IsNil)
+	}
+
+	history, err := node.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 3)
+	for i, change := range history {
+		c.Assert(change.Changes, HasLen, 1)
+		c.Assert(change.Changes[0].NewValue, Equals, 4-i)
+	}
+	count, err := s.state.settingsHistory.Find(D{{"key", s.key}}).Count()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 3)
+
+	history, err = node.History(2)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Assert(history[0].Changes[0].NewValue, Equals, 4)
+	c.Assert(history[1].Changes[0].NewValue, Equals, 3)
+}
+
+func (s *SettingsSuite) TestConcurrentWriteAuditedKeepsHistoryBounded(c *C) {
+	defer func(old int) { maxSettingsHistory = old }(maxSettingsHistory)
+	maxSettingsHistory = 2
+
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+	for i := 0; i < 2; i++ {
+		node.Set("count", i)
+		_, err = node.WriteAudited("user-admin")
+		c.Assert(err, IsNil)
+	}
+
+	// Another writer records a change after node has read the
+	// history entries it would trim.
+	defer SetBeforeHooks(c, s.state, func() {
+		other, err := readSettings(s.state, s.key)
+		c.Assert(err, IsNil)
+		other.Set("other", true)
+		_, err = other.WriteAudited("user-other")
+		c.Assert(err, IsNil)
+	}).Check()
+	node.Set("count", 2)
+	_, err = node.WriteAudited("user-admin")
+	c.Assert(err, IsNil)
+
+	count, err := s.state.settingsHistory.Find(D{{"key", s.key}}).Count()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 2)
+	history, err := node.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history[0].ActorTag, Equals, "user-admin")
+	c.Assert(history[1].ActorTag, Equals, "user-other")
+	err = node.Read()
+	c.Assert(err, IsNil)
+	c.Assert(node.Map(), DeepEquals, map[string]interface{}{"count": 2, "other": true})
+}
+
+func (s *SettingsSuite) TestWriteAuditedMissing(c *C) {
+	node, err := createSettings(s.state, s.key, nil)
+	c.Assert(err, IsNil)
+	err = removeSettings(s.state, s.key)
+	c.Assert(err, IsNil)
+
+	node.Set("foo", "bar")
+	_, err = node.WriteAudited("user-admin")
+	c.Assert(err, ErrorMatches, "settings not found")
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+	history, err := node.History(0)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 0)
+}
+
+type ItemChangeSuite struct{}
+
+var _ = Suite(&ItemChangeSuite{})
+
+var itemChangeTests = []struct {
+	change ItemChange
+	str    string
+	json   string
+	yaml   string
+}{{
+	change: ItemChange{ItemAdded, "alpha", nil, "beta"},
+	str:    "added alpha=beta",
+	json:   `{"type":0,"key":"alpha","new":"beta"}`,
+	yaml:   "type: 0\nkey: alpha\nnew: beta\n",
+}, {
+	change: ItemChange{ItemModified, "one", 1, "two"},
+	str:    "modified one: 1 -> two",
+	json:   `{"type":1,"key":"one","old":1,"new":"two"}`,
+	yaml:   "type: 1\nkey: one\nold: 1\nnew: two\n",
+}, {
+	change: ItemChange{ItemDeleted, "x", "gone", nil},
+	str:    "deleted x",
+	json:   `{"type":2,"key":"x","old":"gone"}`,
+	yaml:   "type: 2\nkey: x\nold: gone\n",
+}}
+
+func (*ItemChangeSuite) TestString(c *C) {
+	for i, t := range itemChangeTests {
+		c.Logf("test %d: %s", i, t.str)
+		c.Assert(t.change.String(), Equals, t.str)
+	}
+	change := ItemChange{42, "x", 1, 2}
+	c.Assert(change.String(), Equals, "unknown change type 42 to x: 1 -> 2")
+}
+
+func (*ItemChangeSuite) TestJSON(c *C) {
+	for i, t := range itemChangeTests {
+		c.Logf("test %d: %s", i, t.str)
+		data, err := json.Marshal(t.change)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, t.json)
+
+		var change ItemChange
+		err = json.Unmarshal(data, &change)
+		c.Assert(err, IsNil)
+		// JSON numbers always decode as float64.
+		c.Assert(change.String(), Equals, t.str)
+		c.Assert(change.Type, Equals, t.change.Type)
+		c.Assert(change.Key, Equals, t.change.Key)
+	}
+}
+
+func (*ItemChangeSuite) TestYAML(c *C) {
+	for i, t := range itemChangeTests {
+		c.Logf("test %d: %s", i, t.str)
+		data, err := goyaml.Marshal(t.change)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, t.yaml)
+
+		var change ItemChange
+		err = goyaml.Unmarshal(data, &change)
+		c.Assert(err, IsNil)
+		c.Assert(change, DeepEquals, t.change)
+	}
+}
@@ -112,6 +112,7 @@ type State struct {
 	minUnits         *mgo.Collection
 	settings         *mgo.Collection
 	settingsrefs     *mgo.Collection
+	settingsHistory  *mgo.Collection
 	constraints      *mgo.Collection
 	units            *mgo.Collection
 	users            *mgo.Collection
@@ -226,7 +227,6 @@ func (st *State) AddMachine(series string, jobs ...MachineJob) (m *Machine, err
 // supplied series. The machine's constraints and other configuration will be taken from
 // the supplied params struct.
 func (st *State) AddMachineWithConstraints(params *AddMachineParams) (m *Machine, err error) {
-
 	// TODO(wallyworld) - if a container is required, and when the actual machine characteristics
 	// are made available, we need to check the machine constraints to ensure the container can be
 	// created on the specifed machine.
@@ -246,6 +246,28 @@ func (st *State) InjectMachine(series string, cons constraints.Value, instanceId
 	return st.addMachine(&AddMachineParams{Series: series, Constraints: cons, instanceId: instanceId, nonce: BootstrapNonce, Jobs: jobs})
 }
 
+// AddManualMachine adds a new machine for an already running host that
+// was not started by the environment, such as one added over SSH. The
+// machine is recorded as provisioned, with the supplied instance id and
+// nonce, so that the provisioner does not try to start an instance for
+// it. The machine's series, constraints and jobs are taken from params,
+// which must not describe a container.
+func (st *State) AddManualMachine(params AddMachineParams, instanceId instance.Id, nonce string) (m *Machine, err error) {
+	if instanceId == "" {
+		return nil, fmt.Errorf("cannot add a manual machine without an instance id")
+	}
+	if nonce == "" {
+		return nil, fmt.Errorf("cannot add a manual machine without a nonce")
+	}
+	if params.ParentId != "" || params.ContainerType != "" {
+		return nil, fmt.Errorf("cannot add a manual machine as a container")
+	}
+	params.instanceId = instanceId
+	params.nonce = nonce
+	params.manual = true
+	return st.addMachine(&params)
+}
+
 // containerRefParams specify how a machineContainers document is to be created.
 type containerRefParams struct {
 	hostId      string
@@ -255,22 +277,6 @@ type containerRefParams struct {
 }
 
 func (st *State) addMachineOps(mdoc *machineDoc, metadata *instanceData, cons constraints.Value, containerParams *containerRefParams) (*machineDoc, []txn.Op, error) {
-	if mdoc.Series == "" {
-		return nil, nil, fmt.Errorf("no series specified")
-	}
-	if len(mdoc.Jobs) == 0 {
-		return nil, nil, fmt.Errorf("no jobs specified")
-	}
-	if containerParams.hostId != "" && mdoc.ContainerType == "" {
-		return nil, nil, fmt.Errorf("no container type specified")
-	}
-	jset := make(map[MachineJob]bool)
-	for _, j := range mdoc.Jobs {
-		if jset[j] {
-			return nil, nil, fmt.Errorf("duplicate job: %s", j)
-		}
-		jset[j] = true
-	}
 	if containerParams.hostId == "" {
 		// we are creating a new machine instance (not a container).
 		seq, err := st.sequence("machine")
@@ -329,9 +335,40 @@ type AddMachineParams struct {
 	ContainerType instance.ContainerType
 	instanceId    instance.Id
 	nonce         string
+	manual        bool
 	Jobs          []MachineJob
 }
 
+// Validate returns an error if the parameters do not describe
+// a machine or container that can be added to the environment.
+func (p *AddMachineParams) Validate() error {
+	if p.Series == "" {
+		return fmt.Errorf("no series specified")
+	}
+	if len(p.Jobs) == 0 {
+		return fmt.Errorf("no jobs specified")
+	}
+	if p.ParentId != "" {
+		if !IsMachineId(p.ParentId) {
+			return fmt.Errorf("invalid parent machine id %q", p.ParentId)
+		}
+		if p.ContainerType == "" {
+			return fmt.Errorf("no container type specified")
+		}
+	}
+	jset := make(map[MachineJob]bool)
+	for _, j := range p.Jobs {
+		if jset[j] {
+			return fmt.Errorf("duplicate job: %s", j)
+		}
+		if p.ContainerType != "" && (j == JobManageEnviron || j == JobManageState) {
+			return fmt.Errorf("cannot run %s job in a container", j)
+		}
+		jset[j] = true
+	}
+	return nil
+}
+
 // addMachineContainerOps returns txn operations and associated Mongo records used to create a new machine,
 // accounting for the fact that a machine may require a container and may require instance data.
 // This method exists to cater for:
@@ -365,11 +402,15 @@ func (st *State) addMachineContainerOps(params *AddMachineParams, cons constrain
 			containerParams.hostId = mdoc.Id
 			containerParams.newHost = true
 		} else {
-			// If a parent machine is specified, make sure it exists.
-			_, err := st.Machine(containerParams.hostId)
+			// If a parent machine is specified, make sure it exists
+			// and runs the same series as the new container.
+			host, err := st.Machine(containerParams.hostId)
 			if err != nil {
 				return nil, nil, nil, err
 			}
+			if params.Series != "" && params.Series != host.Series() {
+				return nil, nil, nil, fmt.Errorf("series %q does not match host machine %s series %q", params.Series, host.Id(), host.Series())
+			}
 		}
 	}
 	return ops, instData, containerParams, nil
@@ -382,6 +423,9 @@ func (st *State) addMachine(params *AddMachineParams) (m *Machine, err error) {
 		msg = "cannot add a new container"
 	}
 	defer utils.ErrorContextf(&err, msg)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 
 	cons, err := st.EnvironConstraints()
 	if err != nil {
@@ -402,6 +446,7 @@ func (st *State) addMachine(params *AddMachineParams) (m *Machine, err error) {
 	if mdoc.ContainerType == "" {
 		mdoc.InstanceId = params.instanceId
 		mdoc.Nonce = params.nonce
+		mdoc.Manual = params.manual
 	}
 	mdoc, machineOps, err := st.addMachineOps(mdoc, instData, cons, containerParams)
 	if err != nil {
@@ -431,6 +476,21 @@ func onAbort(txnErr, err error) error {
 	return txnErr
 }
 
+// codedError is an error that carries the API error code
+// reported for it to clients.
+type codedError struct {
+	message string
+	code    string
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+func (e *codedError) ErrorCode() string {
+	return e.code
+}
+
 // AllMachines returns all machines in the environment
 // ordered by id.
 func (st *State) AllMachines() (machines []*Machine, err error) {
@@ -446,6 +506,28 @@ func (st *State) AllMachines() (machines []*Machine, err error) {
 	return
 }
 
+// MachineAndContainers returns the machine with the given id followed
+// by all its containers, including nested ones, ordered by id.
+func (st *State) MachineAndContainers(id string) (machines []*Machine, err error) {
+	mdocs := machineDocSlice{}
+	sel := D{{"$or", []D{
+		{{"_id", id}},
+		{{"_id", D{{"$regex", "^" + id + "/"}}}},
+	}}}
+	err = st.machines.Find(sel).All(&mdocs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get machine %s and its containers: %v", id, err)
+	}
+	sort.Sort(mdocs)
+	if len(mdocs) == 0 || mdocs[0].Id != id {
+		return nil, errors.NotFoundf("machine %s", id)
+	}
+	for _, doc := range mdocs {
+		machines = append(machines, newMachine(st, &doc))
+	}
+	return machines, nil
+}
+
 type machineDocSlice []machineDoc
 
 func (ms machineDocSlice) Len() int      { return len(ms) }
@@ -826,6 +908,16 @@ func (st *State) AllServices() (services []*Service, err error) {
 	return services, nil
 }
 
+// UnitCount returns the number of units of all services in the
+// environment, without loading them.
+func (st *State) UnitCount() (int, error) {
+	n, err := st.units.Count()
+	if err != nil {
+		return 0, fmt.Errorf("cannot count units: %v", err)
+	}
+	return n, nil
+}
+
 // InferEndpoints returns the endpoints corresponding to the supplied names.
 // There must be 1 or 2 supplied names, of the form <service>[:<relation>].
 // If the supplied names uniquely specify a possible relation, or if they
@@ -1129,6 +1221,57 @@ func (st *State) DestroyMachines(ids ...string) (err error) {
 	return destroyErr("machines", ids, errs)
 }
 
+// RemoveMachines removes the dead machines with the given ids, and
+// all the documents that belong to them, in a single transaction
+// where possible. Machines that have already been removed are ignored.
+// The returned map holds an error for each machine that could not be
+// removed, such as one that is not dead because it still has units
+// assigned; the other machines are removed regardless.
+func (st *State) RemoveMachines(ids ...string) (map[string]error, error) {
+	errs := make(map[string]error)
+	var machines []*Machine
+	var ops []txn.Op
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		m, err := st.Machine(id)
+		if errors.IsNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			errs[id] = err
+			continue
+		}
+		if m.Life() != Dead {
+			errs[id] = fmt.Errorf("cannot remove machine %s: machine is not dead", id)
+			continue
+		}
+		machines = append(machines, m)
+		ops = append(ops, m.removeOps()...)
+	}
+	if len(ops) == 0 {
+		return errs, nil
+	}
+	err := st.runTransaction(ops)
+	if err == txn.ErrAborted {
+		// Some machine was removed concurrently, so remove
+		// each of the others on its own.
+		for _, m := range machines {
+			if err := m.Remove(); err != nil {
+				errs[m.Id()] = err
+			}
+		}
+		return errs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot remove machines: %v", err)
+	}
+	return errs, nil
+}
+
 func destroyErr(desc string, ids, errs []string) error {
 	if len(errs) == 0 {
 		return nil
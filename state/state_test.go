@@ -6,13 +6,17 @@ package state_test
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"labix.org/v2/mgo"
 	"labix.org/v2/mgo/bson"
 	. "launchpad.net/gocheck"
+	"launchpad.net/tomb"
 
+	"launchpad.net/juju-core/cert"
 	"launchpad.net/juju-core/charm"
 	"launchpad.net/juju-core/constraints"
 	"launchpad.net/juju-core/environs/config"
@@ -53,6 +57,106 @@ func (s *StateSuite) TestDialAgain(c *C) {
 	}
 }
 
+func (s *StateSuite) TestDeadOnClose(c *C) {
+	st, err := state.Open(state.TestingStateInfo(), state.TestingDialOpts())
+	c.Assert(err, IsNil)
+	select {
+	case <-st.Dead():
+		c.Fatalf("state is dead before being closed")
+	case <-time.After(testing.ShortWait):
+	}
+	c.Assert(st.Err(), Equals, tomb.ErrStillAlive)
+
+	c.Assert(st.Close(), IsNil)
+	select {
+	case <-st.Dead():
+	case <-time.After(testing.LongWait):
+		c.Fatalf("state is not dead after being closed")
+	}
+	c.Assert(st.Err(), IsNil)
+}
+
+func (s *StateSuite) TestDeadOnConnectionLost(c *C) {
+	proxy := testing.NewTCPProxy(c, testing.MgoAddr)
+	defer proxy.Close()
+	info := state.TestingStateInfo()
+	info.Addrs = []string{proxy.Addr()}
+	st, err := state.Open(info, state.TestingDialOpts())
+	c.Assert(err, IsNil)
+	defer st.Close()
+	select {
+	case <-st.Dead():
+		c.Fatalf("state is dead before the connection is lost")
+	case <-time.After(testing.ShortWait):
+	}
+
+	// Break the connection, and make the state notice at once.
+	c.Assert(proxy.Close(), IsNil)
+	st.StartSync()
+	select {
+	case <-st.Dead():
+	case <-time.After(testing.LongWait):
+		c.Fatalf("state is not dead after losing its connection")
+	}
+	c.Assert(st.Err(), NotNil)
+	c.Assert(st.Err(), Not(Equals), tomb.ErrStillAlive)
+}
+
+func (s *StateSuite) TestCloseTwice(c *C) {
+	st, err := state.Open(state.TestingStateInfo(), state.TestingDialOpts())
+	c.Assert(err, IsNil)
+	c.Assert(st.Close(), IsNil)
+	c.Assert(st.Close(), IsNil)
+}
+
+func (s *StateSuite) TestCloseStopsWatchers(c *C) {
+	st, err := state.Open(state.TestingStateInfo(), state.TestingDialOpts())
+	c.Assert(err, IsNil)
+	w := st.WatchMachines()
+	wc := statetesting.NewStringsWatcherC(c, st, w)
+	wc.AssertOneChange()
+
+	c.Assert(st.Close(), IsNil)
+	select {
+	case _, ok := <-w.Changes():
+		c.Assert(ok, Equals, false)
+	case <-time.After(testing.LongWait):
+		c.Fatalf("watcher not closed")
+	}
+	c.Assert(w.Err(), ErrorMatches, "state has been closed")
+	c.Assert(w.Stop(), ErrorMatches, "state has been closed")
+}
+
+func indexKeys(c *C, coll *mgo.Collection) []string {
+	indexes, err := coll.Indexes()
+	c.Assert(err, IsNil)
+	var keys []string
+	for _, index := range indexes {
+		keys = append(keys, strings.Join(index.Key, ","))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *StateSuite) TestEnsureIndexes(c *C) {
+	db := s.Session.DB("juju")
+	expected := map[string][]string{
+		"relations":       {"_id", "endpoints.relationname", "endpoints.servicename"},
+		"units":           {"_id", "machineid", "principal", "service"},
+		"users":           {"_id", "name"},
+		"settingshistory": {"_id", "key,seq"},
+	}
+	// The indexes were created when the state was opened;
+	// ensuring them again changes nothing.
+	for i := 0; i < 2; i++ {
+		err := state.EnsureIndexes(s.Session)
+		c.Assert(err, IsNil)
+		for name, keys := range expected {
+			c.Check(indexKeys(c, db.C(name)), DeepEquals, keys, Commentf("collection %q", name))
+		}
+	}
+}
+
 func (s *StateSuite) TestStateInfo(c *C) {
 	info := state.TestingStateInfo()
 	stateAddr, err := s.State.Addresses()
@@ -304,6 +408,126 @@ func (s *StateSuite) TestAddContainerErrors(c *C) {
 	c.Assert(err, ErrorMatches, "cannot add a new container: no container type specified")
 }
 
+var addMachineParamsValidateTests = []struct {
+	about  string
+	params state.AddMachineParams
+	err    string
+}{{
+	about:  "machine",
+	params: state.AddMachineParams{Series: "series", Jobs: []state.MachineJob{state.JobHostUnits}},
+}, {
+	about: "container on a new machine",
+	params: state.AddMachineParams{
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	},
+}, {
+	about: "container on an existing container",
+	params: state.AddMachineParams{
+		ParentId:      "0/lxc/1",
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	},
+}, {
+	about:  "no series",
+	params: state.AddMachineParams{Jobs: []state.MachineJob{state.JobHostUnits}},
+	err:    "no series specified",
+}, {
+	about:  "no jobs",
+	params: state.AddMachineParams{Series: "series"},
+	err:    "no jobs specified",
+}, {
+	about: "duplicate jobs",
+	params: state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits, state.JobHostUnits},
+	},
+	err: "duplicate job: JobHostUnits",
+}, {
+	about: "parent without container type",
+	params: state.AddMachineParams{
+		ParentId: "0",
+		Series:   "series",
+		Jobs:     []state.MachineJob{state.JobHostUnits},
+	},
+	err: "no container type specified",
+}, {
+	about: "invalid parent id",
+	params: state.AddMachineParams{
+		ParentId:      "0/lxc",
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	},
+	err: `invalid parent machine id "0/lxc"`,
+}, {
+	about: "manage job in container",
+	params: state.AddMachineParams{
+		ContainerType: instance.LXC,
+		Series:        "series",
+		Jobs:          []state.MachineJob{state.JobManageEnviron},
+	},
+	err: "cannot run JobManageEnviron job in a container",
+}}
+
+func (s *StateSuite) TestAddMachineParamsValidate(c *C) {
+	for i, test := range addMachineParamsValidateTests {
+		c.Logf("test %d: %s", i, test.about)
+		err := test.params.Validate()
+		if test.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, test.err)
+		}
+	}
+}
+
+func (s *StateSuite) TestAddMachineWithConstraintsValidates(c *C) {
+	params := state.AddMachineParams{Jobs: []state.MachineJob{state.JobHostUnits}}
+	_, err := s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, ErrorMatches, "cannot add a new machine: no series specified")
+	s.AssertMachineCount(c, 0)
+}
+
+func (s *StateSuite) TestAddContainerManageJob(c *C) {
+	m0, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	for _, job := range []state.MachineJob{state.JobManageEnviron, state.JobManageState} {
+		params := state.AddMachineParams{
+			ParentId:      m0.Id(),
+			ContainerType: instance.LXC,
+			Series:        "series",
+			Jobs:          []state.MachineJob{state.JobHostUnits, job},
+		}
+		_, err = s.State.AddMachineWithConstraints(&params)
+		c.Assert(err, ErrorMatches, fmt.Sprintf("cannot add a new container: cannot run %s job in a container", job))
+
+		// The job is also refused when a new host is created.
+		params.ParentId = ""
+		_, err = s.State.AddMachineWithConstraints(&params)
+		c.Assert(err, ErrorMatches, fmt.Sprintf("cannot add a new container: cannot run %s job in a container", job))
+	}
+	s.assertMachineContainers(c, m0, nil)
+	_, err = s.State.Machine("1")
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
+}
+
+func (s *StateSuite) TestAddContainerSeriesMismatch(c *C) {
+	m0, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	params := state.AddMachineParams{
+		ParentId:      m0.Id(),
+		ContainerType: instance.LXC,
+		Series:        "other",
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	}
+	_, err = s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, ErrorMatches, `cannot add a new container: series "other" does not match host machine 0 series "series"`)
+	s.assertMachineContainers(c, m0, nil)
+}
+
 func (s *StateSuite) TestInjectMachineErrors(c *C) {
 	_, err := s.State.InjectMachine("", emptyCons, instance.Id("i-minvalid"), state.JobHostUnits)
 	c.Assert(err, ErrorMatches, "cannot add a new machine: no series specified")
@@ -329,6 +553,48 @@ func (s *StateSuite) TestInjectMachine(c *C) {
 	c.Assert(m.CheckProvisioned(state.BootstrapNonce), Equals, true)
 }
 
+func (s *StateSuite) TestAddManualMachine(c *C) {
+	cons := constraints.MustParse("mem=4G")
+	m, err := s.State.AddManualMachine(state.AddMachineParams{
+		Series:      "series",
+		Constraints: cons,
+		Jobs:        []state.MachineJob{state.JobHostUnits},
+	}, instance.Id("manual:example.com"), "manual:nonce")
+	c.Assert(err, IsNil)
+	c.Assert(m.Series(), Equals, "series")
+	c.Assert(m.Jobs(), DeepEquals, []state.MachineJob{state.JobHostUnits})
+	instanceId, err := m.InstanceId()
+	c.Assert(err, IsNil)
+	c.Assert(instanceId, Equals, instance.Id("manual:example.com"))
+	mcons, err := m.Constraints()
+	c.Assert(err, IsNil)
+	c.Assert(mcons, DeepEquals, cons)
+	c.Assert(m.CheckProvisioned("manual:nonce"), Equals, true)
+	c.Assert(m.IsManual(), Equals, true)
+
+	m, err = s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+	c.Assert(m.IsManual(), Equals, false)
+}
+
+func (s *StateSuite) TestAddManualMachineErrors(c *C) {
+	params := state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}
+	_, err := s.State.AddManualMachine(params, "", "manual:nonce")
+	c.Assert(err, ErrorMatches, "cannot add a manual machine without an instance id")
+	_, err = s.State.AddManualMachine(params, "manual:example.com", "")
+	c.Assert(err, ErrorMatches, "cannot add a manual machine without a nonce")
+	params.ContainerType = instance.LXC
+	_, err = s.State.AddManualMachine(params, "manual:example.com", "manual:nonce")
+	c.Assert(err, ErrorMatches, "cannot add a manual machine as a container")
+	params.ContainerType = ""
+	params.Series = ""
+	_, err = s.State.AddManualMachine(params, "manual:example.com", "manual:nonce")
+	c.Assert(err, ErrorMatches, "cannot add a new machine: no series specified")
+}
+
 func (s *StateSuite) TestAddContainerToInjectedMachine(c *C) {
 	oneJob := []state.MachineJob{state.JobHostUnits}
 	m0, err := s.State.InjectMachine("series", emptyCons, instance.Id("i-mindustrious"), state.JobHostUnits, state.JobManageEnviron)
@@ -388,6 +654,73 @@ func (s *StateSuite) TestMachineIdLessThan(c *C) {
 	c.Assert(state.MachineIdLessThan("0/lxc/0", "1"), Equals, true)
 	c.Assert(state.MachineIdLessThan("0/lxc/0/lxc/1", "0/lxc/0"), Equals, false)
 	c.Assert(state.MachineIdLessThan("0/kvm/0", "0/lxc/0"), Equals, true)
+	// Numeric id components are compared as numbers, not strings.
+	c.Assert(state.MachineIdLessThan("2", "10"), Equals, true)
+	c.Assert(state.MachineIdLessThan("0/lxc/2", "0/lxc/10"), Equals, true)
+	c.Assert(state.MachineIdLessThan("0/lxc/10", "0/lxc/2"), Equals, false)
+	c.Assert(state.MachineIdLessThan("2/lxc/0", "10/lxc/0"), Equals, true)
+	c.Assert(state.MachineIdLessThan("10/lxc/0", "2"), Equals, false)
+	c.Assert(state.MachineIdLessThan("0/lxc/2/lxc/0", "0/lxc/10"), Equals, true)
+}
+
+func (s *StateSuite) TestAllMachinesOrdersContainerIds(c *C) {
+	params := state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}
+	host, err := s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, IsNil)
+	params.ParentId = host.Id()
+	params.ContainerType = instance.LXC
+	for i := 0; i < 11; i++ {
+		_, err := s.State.AddMachineWithConstraints(&params)
+		c.Assert(err, IsNil)
+	}
+	machines, err := s.State.AllMachines()
+	c.Assert(err, IsNil)
+	var ids []string
+	for _, m := range machines {
+		ids = append(ids, m.Id())
+	}
+	expected := []string{"0"}
+	for i := 0; i < 11; i++ {
+		expected = append(expected, fmt.Sprintf("0/lxc/%d", i))
+	}
+	c.Assert(ids, DeepEquals, expected)
+}
+
+func (s *StateSuite) TestMachineAndContainers(c *C) {
+	params := state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}
+	for i := 0; i < 2; i++ {
+		_, err := s.State.AddMachineWithConstraints(&params)
+		c.Assert(err, IsNil)
+	}
+	for _, parentId := range []string{"0", "0/lxc/0", "0", "1"} {
+		params.ParentId = parentId
+		params.ContainerType = instance.LXC
+		_, err := s.State.AddMachineWithConstraints(&params)
+		c.Assert(err, IsNil)
+	}
+	for id, expected := range map[string][]string{
+		"0":       {"0", "0/lxc/0", "0/lxc/0/lxc/0", "0/lxc/1"},
+		"0/lxc/0": {"0/lxc/0", "0/lxc/0/lxc/0"},
+		"1":       {"1", "1/lxc/0"},
+		"1/lxc/0": {"1/lxc/0"},
+	} {
+		machines, err := s.State.MachineAndContainers(id)
+		c.Assert(err, IsNil)
+		var ids []string
+		for _, m := range machines {
+			ids = append(ids, m.Id())
+		}
+		c.Check(ids, DeepEquals, expected)
+	}
+	_, err := s.State.MachineAndContainers("2")
+	c.Assert(err, ErrorMatches, "machine 2 not found")
+	c.Assert(err, checkers.Satisfies, errors.IsNotFoundError)
 }
 
 func (s *StateSuite) TestAllMachines(c *C) {
@@ -479,6 +812,35 @@ func (s *StateSuite) TestAllServices(c *C) {
 	c.Assert(services[1].Name(), Equals, "mysql")
 }
 
+func (s *StateSuite) TestUnitCount(c *C) {
+	charm := s.AddTestingCharm(c, "dummy")
+	n, err := s.State.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+
+	wordpress, err := s.State.AddService("wordpress", charm)
+	c.Assert(err, IsNil)
+	mysql, err := s.State.AddService("mysql", charm)
+	c.Assert(err, IsNil)
+	unit, err := wordpress.AddUnit()
+	c.Assert(err, IsNil)
+	_, err = mysql.AddUnit()
+	c.Assert(err, IsNil)
+	_, err = mysql.AddUnit()
+	c.Assert(err, IsNil)
+	n, err = s.State.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 3)
+
+	err = unit.EnsureDead()
+	c.Assert(err, IsNil)
+	err = unit.Remove()
+	c.Assert(err, IsNil)
+	n, err = s.State.UnitCount()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+}
+
 var inferEndpointsTests = []struct {
 	summary string
 	inputs  [][]string
@@ -898,6 +1260,47 @@ func (s *StateSuite) TestWatchMachinesIgnoresContainers(c *C) {
 	wc.AssertNoChange()
 }
 
+func (s *StateSuite) TestWatchAllMachines(c *C) {
+	machine0, err := s.State.AddMachine("series", state.JobHostUnits)
+	c.Assert(err, IsNil)
+
+	// Initial event holds all existing machines.
+	w := s.State.WatchMachines()
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewStringsWatcherC(c, s.State, w)
+	wc.AssertOneChange("0")
+
+	// Add a machine: reported.
+	params := state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}
+	machine1, err := s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange("1")
+
+	// Add a container: reported.
+	params.ParentId = machine1.Id()
+	params.ContainerType = instance.LXC
+	container, err := s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange("1/lxc/0")
+
+	// Make the container and a machine Dead: both reported.
+	err = container.EnsureDead()
+	c.Assert(err, IsNil)
+	err = machine0.EnsureDead()
+	c.Assert(err, IsNil)
+	wc.AssertOneChange("0", "1/lxc/0")
+
+	// Remove them: not reported.
+	err = container.Remove()
+	c.Assert(err, IsNil)
+	err = machine0.Remove()
+	c.Assert(err, IsNil)
+	wc.AssertNoChange()
+}
+
 func (s *StateSuite) TestWatchContainerLifecycle(c *C) {
 	// Add a host machine.
 	params := state.AddMachineParams{
@@ -1271,6 +1674,20 @@ func (s *StateSuite) TestOpenBadAddress(c *C) {
 	c.Assert(err, ErrorMatches, "no reachable servers")
 }
 
+func (s *StateSuite) TestOpenRejectsCertFromOtherCA(c *C) {
+	otherCACert, _, err := cert.NewCA("other", time.Now().AddDate(1, 0, 0))
+	c.Assert(err, IsNil)
+	info := state.TestingStateInfo()
+	info.CACert = otherCACert
+	st, err := state.Open(info, state.DialOpts{
+		Timeout: 1 * time.Second,
+	})
+	if err == nil {
+		st.Close()
+	}
+	c.Assert(err, ErrorMatches, "no reachable servers")
+}
+
 func (s *StateSuite) TestOpenDelaysRetryBadAddress(c *C) {
 	// Default mgo retry delay
 	retryDelay := 500 * time.Millisecond
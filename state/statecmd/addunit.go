@@ -7,23 +7,30 @@ package statecmd
 
 import (
 	"errors"
+	"launchpad.net/juju-core/constraints"
 	"launchpad.net/juju-core/juju"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 )
 
 // AddServiceUnits adds a given number of units to a service.
-func AddServiceUnits(state *state.State, args params.AddServiceUnits) ([]*state.Unit, error) {
-	conn, err := juju.NewConnFromState(state)
+func AddServiceUnits(st *state.State, args params.AddServiceUnits) ([]*state.Unit, error) {
+	conn, err := juju.NewConnFromState(st)
 	if err != nil {
 		return nil, err
 	}
-	service, err := state.Service(args.ServiceName)
+	service, err := st.Service(args.ServiceName)
 	if err != nil {
 		return nil, err
 	}
 	if args.NumUnits < 1 {
 		return nil, errors.New("must add at least one unit")
 	}
-	return conn.AddUnits(service, args.NumUnits, "")
+	if args.Constraints != (constraints.Value{}) {
+		if args.ToMachineSpec != "" {
+			return nil, errors.New("cannot use constraints when placing a unit on a specific machine")
+		}
+		return conn.AddUnitsWithConstraints(service, args.NumUnits, args.Constraints)
+	}
+	return conn.AddUnits(service, args.NumUnits, state.AssignNew, args.ToMachineSpec)
 }
@@ -0,0 +1,65 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// Code shared by commands that apply the same operation to several
+// targets over a single connection.
+
+package statecmd
+
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/juju-core/juju"
+	"launchpad.net/juju-core/state"
+)
+
+// BulkFunc applies an operation to a single target.
+type BulkFunc func(st *state.State, target string) error
+
+// BulkResult holds the outcome of applying a BulkFunc to a single target.
+type BulkResult struct {
+	Target string
+	Error  error
+}
+
+// Bulk connects to the named environment, or the default environment if
+// environName is empty, calls f for each of the targets in order and
+// closes the connection. A failure for one target does not prevent f
+// from being called for the others; the outcome for each target is
+// reported in the returned results. An error is returned only if the
+// connection cannot be made.
+func Bulk(environName string, targets []string, f BulkFunc) ([]BulkResult, error) {
+	conn, err := juju.NewConnFromName(environName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	results := make([]BulkResult, len(targets))
+	for i, target := range targets {
+		results[i] = BulkResult{
+			Target: target,
+			Error:  f(conn.State, target),
+		}
+	}
+	return results, nil
+}
+
+// BulkError returns an error describing every failed result, or nil if
+// all of them succeeded. When there is only a single result, its error
+// is returned unchanged.
+func BulkError(results []BulkResult) error {
+	if len(results) == 1 {
+		return results[0].Error
+	}
+	var errs []string
+	for _, result := range results {
+		if result.Error != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", result.Target, result.Error))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d operations failed: %s", len(errs), len(results), strings.Join(errs, "; "))
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package statecmd_test
+
+import (
+	"fmt"
+
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/juju/testing"
+	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/state/statecmd"
+)
+
+type BulkSuite struct {
+	testing.JujuConnSuite
+}
+
+var _ = Suite(&BulkSuite{})
+
+func (s *BulkSuite) exposeService(st *state.State, name string) error {
+	svc, err := st.Service(name)
+	if err != nil {
+		return err
+	}
+	return svc.SetExposed()
+}
+
+func (s *BulkSuite) TestBulkAllSuccess(c *C) {
+	charm := s.AddTestingCharm(c, "dummy")
+	for _, name := range []string{"one", "two"} {
+		_, err := s.State.AddService(name, charm)
+		c.Assert(err, IsNil)
+	}
+	results, err := statecmd.Bulk("", []string{"one", "two"}, s.exposeService)
+	c.Assert(err, IsNil)
+	c.Assert(results, DeepEquals, []statecmd.BulkResult{
+		{Target: "one"},
+		{Target: "two"},
+	})
+	c.Assert(statecmd.BulkError(results), IsNil)
+	for _, name := range []string{"one", "two"} {
+		svc, err := s.State.Service(name)
+		c.Assert(err, IsNil)
+		c.Assert(svc.IsExposed(), Equals, true)
+	}
+}
+
+func (s *BulkSuite) TestBulkPartialFailure(c *C) {
+	charm := s.AddTestingCharm(c, "dummy")
+	for _, name := range []string{"one", "three"} {
+		_, err := s.State.AddService(name, charm)
+		c.Assert(err, IsNil)
+	}
+	results, err := statecmd.Bulk("", []string{"one", "two", "three"}, s.exposeService)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 3)
+	c.Assert(results[0], DeepEquals, statecmd.BulkResult{Target: "one"})
+	c.Assert(results[1].Target, Equals, "two")
+	c.Assert(results[1].Error, ErrorMatches, `service "two" not found`)
+	c.Assert(results[2], DeepEquals, statecmd.BulkResult{Target: "three"})
+	c.Assert(statecmd.BulkError(results), ErrorMatches, `1 of 3 operations failed: two: service "two" not found`)
+
+	// The failure does not prevent later targets from being processed.
+	svc, err := s.State.Service("three")
+	c.Assert(err, IsNil)
+	c.Assert(svc.IsExposed(), Equals, true)
+}
+
+func (s *BulkSuite) TestBulkSingleFailure(c *C) {
+	results, err := statecmd.Bulk("", []string{"one"}, s.exposeService)
+	c.Assert(err, IsNil)
+	c.Assert(statecmd.BulkError(results), ErrorMatches, `service "one" not found`)
+}
+
+func (s *BulkSuite) TestBulkConnectionError(c *C) {
+	called := false
+	f := func(*state.State, string) error {
+		called = true
+		return fmt.Errorf("unexpected call")
+	}
+	results, err := statecmd.Bulk("nonexistent", []string{"one"}, f)
+	c.Assert(err, ErrorMatches, `unknown environment "nonexistent"`)
+	c.Assert(results, IsNil)
+	c.Assert(called, Equals, false)
+}
@@ -336,7 +336,7 @@ func (u *Unit) removeOps(asserts D) ([]txn.Op, error) {
 	return svc.removeUnitOps(u, asserts)
 }
 
-var ErrUnitHasSubordinates = stderrors.New("unit has subordinates")
+var ErrUnitHasSubordinates error = &codedError{"unit has subordinates", params.CodeUnitHasSubordinates}
 
 var unitHasNoSubordinates = D{{
 	"$or", []D{
@@ -494,6 +494,9 @@ func (u *Unit) SetStatus(status params.Status, info string) error {
 func (u *Unit) OpenPort(protocol string, number int) (err error) {
 	port := instance.Port{Protocol: protocol, Number: number}
 	defer utils.ErrorContextf(&err, "cannot open port %v for unit %q", port, u)
+	if err := port.Validate(); err != nil {
+		return err
+	}
 	ops := []txn.Op{{
 		C:      u.st.units.Name,
 		Id:     u.doc.Name,
@@ -507,6 +510,7 @@ func (u *Unit) OpenPort(protocol string, number int) (err error) {
 	found := false
 	for _, p := range u.doc.Ports {
 		if p == port {
+			found = true
 			break
 		}
 	}
@@ -517,14 +521,23 @@ func (u *Unit) OpenPort(protocol string, number int) (err error) {
 }
 
 // ClosePort sets the policy of the port with protocol and number to be closed.
+// The protocol is matched case-insensitively, so that ports opened before
+// protocols were normalized to lower case can still be closed.
 func (u *Unit) ClosePort(protocol string, number int) (err error) {
 	port := instance.Port{Protocol: protocol, Number: number}
 	defer utils.ErrorContextf(&err, "cannot close port %v for unit %q", port, u)
+	if err := port.Validate(); err != nil {
+		return err
+	}
+	match := D{
+		{"protocol", D{{"$regex", "^" + port.Protocol + "$"}, {"$options", "i"}}},
+		{"number", port.Number},
+	}
 	ops := []txn.Op{{
 		C:      u.st.units.Name,
 		Id:     u.doc.Name,
 		Assert: notDeadDoc,
-		Update: D{{"$pull", D{{"ports", port}}}},
+		Update: D{{"$pull", D{{"ports", match}}}},
 	}}
 	err = u.st.runTransaction(ops)
 	if err != nil {
@@ -532,7 +545,7 @@ func (u *Unit) ClosePort(protocol string, number int) (err error) {
 	}
 	newPorts := make([]instance.Port, 0, len(u.doc.Ports))
 	for _, p := range u.doc.Ports {
-		if p != port {
+		if p.Number != port.Number || strings.ToLower(p.Protocol) != port.Protocol {
 			newPorts = append(newPorts, p)
 		}
 	}
@@ -674,6 +687,10 @@ func (e *NotAssignedError) Error() string {
 	return fmt.Sprintf("unit %q is not assigned to a machine", e.Unit)
 }
 
+func (e *NotAssignedError) ErrorCode() string {
+	return params.CodeNotAssigned
+}
+
 func IsNotAssigned(err error) bool {
 	_, ok := err.(*NotAssignedError)
 	return ok
@@ -798,6 +815,13 @@ func (u *Unit) AssignToMachine(m *Machine) (err error) {
 	return u.assignToMachine(m, false)
 }
 
+// AssignToUnusedMachine assigns this unit to a given machine, which
+// must still be clean when the assignment is made.
+func (u *Unit) AssignToUnusedMachine(m *Machine) (err error) {
+	defer assignContextf(&err, u, fmt.Sprintf("machine %s", m))
+	return u.assignToMachine(m, true)
+}
+
 // AssignToNewMachine assigns the unit to a new machine, with constraints
 // determined according to the service and environment constraints at the
 // time of unit creation.
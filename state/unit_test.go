@@ -780,6 +780,59 @@ func (s *UnitSuite) TestOpenedPorts(c *C) {
 	})
 }
 
+func (s *UnitSuite) TestOpenClosePortValidation(c *C) {
+	// Protocols are normalized to lower case.
+	err := s.unit.OpenPort("TCP", 80)
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}})
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}})
+
+	// Opening the same port again does not duplicate it.
+	err = s.unit.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}})
+
+	// Invalid ports are rejected.
+	err = s.unit.OpenPort("sctp", 80)
+	c.Assert(err, ErrorMatches, `cannot open port sctp:80 for unit "wordpress/0": invalid protocol "sctp", expected "tcp" or "udp"`)
+	err = s.unit.OpenPort("tcp", 0)
+	c.Assert(err, ErrorMatches, `cannot open port tcp:0 for unit "wordpress/0": port number 0 out of range \[1, 65535\]`)
+	err = s.unit.ClosePort("udp", 65536)
+	c.Assert(err, ErrorMatches, `cannot close port udp:65536 for unit "wordpress/0": port number 65536 out of range \[1, 65535\]`)
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{{Protocol: "tcp", Number: 80}})
+
+	// Closing is normalized too.
+	err = s.unit.ClosePort("Tcp", 80)
+	c.Assert(err, IsNil)
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), HasLen, 0)
+}
+
+func (s *UnitSuite) TestClosePortOpenedWithUpperCaseProtocol(c *C) {
+	// Ports opened before protocols were normalized may be
+	// stored in upper case.
+	err := s.units.Update(
+		D{{"_id", s.unit.Name()}},
+		D{{"$set", D{{"ports", []instance.Port{{Protocol: "TCP", Number: 80}}}}}},
+	)
+	c.Assert(err, IsNil)
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{{Protocol: "TCP", Number: 80}})
+
+	err = s.unit.ClosePort("tcp", 80)
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), HasLen, 0)
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), HasLen, 0)
+}
+
 func (s *UnitSuite) TestOpenClosePortWhenDying(c *C) {
 	preventUnitDestroyRemove(c, s.unit)
 	testWhenDying(c, s.unit, noErr, deadErr, func() error {
@@ -1053,6 +1106,57 @@ func (s *UnitSuite) TestWatchUnit(c *C) {
 	testing.NewNotifyWatcherC(c, s.State, w).AssertOneChange()
 }
 
+func (s *UnitSuite) TestWatchPorts(c *C) {
+	preventUnitDestroyRemove(c, s.unit)
+	w := s.unit.WatchPorts()
+	defer testing.AssertStop(c, w)
+
+	// Initial event.
+	wc := testing.NewNotifyWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	// Open a port (on a separate instance), check one event.
+	unit, err := s.State.Unit(s.unit.Name())
+	c.Assert(err, IsNil)
+	err = unit.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange()
+
+	// Open another and close the first, check one event.
+	err = unit.OpenPort("udp", 53)
+	c.Assert(err, IsNil)
+	err = unit.ClosePort("tcp", 80)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange()
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), DeepEquals, []instance.Port{
+		{Protocol: "udp", Number: 53},
+	})
+
+	// Opening an already opened port, closing an unopened one, and
+	// other unit changes, cause no event.
+	err = unit.OpenPort("udp", 53)
+	c.Assert(err, IsNil)
+	err = unit.ClosePort("tcp", 443)
+	c.Assert(err, IsNil)
+	err = unit.SetPublicAddress("example.foobar.com")
+	c.Assert(err, IsNil)
+	wc.AssertNoChange()
+
+	// Close the last port, check one event.
+	err = unit.ClosePort("udp", 53)
+	c.Assert(err, IsNil)
+	wc.AssertOneChange()
+	err = s.unit.Refresh()
+	c.Assert(err, IsNil)
+	c.Assert(s.unit.OpenedPorts(), HasLen, 0)
+
+	// Stop, check closed.
+	testing.AssertStop(c, w)
+	wc.AssertClosed()
+}
+
 func (s *UnitSuite) TestAnnotatorForUnit(c *C) {
 	testAnnotator(c, func() (state.Annotator, error) {
 		return s.State.Unit("wordpress/0")
@@ -16,12 +16,28 @@ import (
 	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/instance"
+	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/watcher"
 	"launchpad.net/juju-core/utils/set"
 )
 
 var watchLogger = loggo.GetLogger("juju.state.watch")
 
+// errStateClosed is the error with which watchers stop when their
+// state is closed while they are running.
+var errStateClosed = fmt.Errorf("state has been closed")
+
+// stateWatcherDeadError returns the error with which a watcher should
+// stop when the state's underlying watcher has stopped with err. That
+// watcher stops cleanly when the state is closed, but the watchers
+// that rely on it must still report an error.
+func stateWatcherDeadError(err error) error {
+	if err != nil {
+		return err
+	}
+	return errStateClosed
+}
+
 // NotifyWatcher generates signals when something changes, but it does not
 // return any content for those changes
 type NotifyWatcher interface {
@@ -141,6 +157,13 @@ func (s *Service) WatchRelations() StringsWatcher {
 	return newLifecycleWatcher(s.st, s.st.relations, members, filter)
 }
 
+// WatchMachines returns a StringsWatcher that notifies of changes to
+// the lifecycles of all machines in the environment, including
+// containers. The initial event holds the ids of all current machines.
+func (st *State) WatchMachines() StringsWatcher {
+	return newLifecycleWatcher(st, st.machines, nil, nil)
+}
+
 // WatchEnvironMachines returns a StringsWatcher that notifies of changes to
 // the lifecycles of the machines (but not containers) in the environment.
 func (st *State) WatchEnvironMachines() StringsWatcher {
@@ -166,6 +189,23 @@ func (m *Machine) WatchContainers(ctype instance.ContainerType) StringsWatcher {
 	return newLifecycleWatcher(m.st, m.st.machines, members, filter)
 }
 
+// WatchEnvironMachineRetries returns a StringsWatcher that notifies
+// when provisioning is to be retried for machines (but not containers)
+// in the environment.
+func (st *State) WatchEnvironMachineRetries() StringsWatcher {
+	return newMachineRetryWatcher(st, func(id string) bool {
+		return !strings.Contains(id, "/")
+	})
+}
+
+// WatchContainerRetries returns a StringsWatcher that notifies when
+// provisioning is to be retried for containers on a machine.
+func (m *Machine) WatchContainerRetries(ctype instance.ContainerType) StringsWatcher {
+	match := fmt.Sprintf("^%s/%s/%s$", m.doc.Id, ctype, numberSnippet)
+	child := regexp.MustCompile(match)
+	return newMachineRetryWatcher(m.st, child.MatchString)
+}
+
 func newLifecycleWatcher(st *State, coll *mgo.Collection, members D, filter func(key interface{}) bool) StringsWatcher {
 	w := &lifecycleWatcher{
 		commonWatcher: commonWatcher{st: st},
@@ -269,7 +309,7 @@ func (w *lifecycleWatcher) loop() (err error) {
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case ch := <-in:
 			updates, ok := collect(ch, in, w.tomb.Dying())
 			if !ok {
@@ -363,7 +403,7 @@ func (w *minUnitsWatcher) loop() (err error) {
 			return tomb.ErrDying
 		case change, ok := <-ch:
 			if !ok {
-				return watcher.MustErr(w.st.watcher)
+				return stateWatcherDeadError(w.st.watcher.Err())
 			}
 			if err = w.merge(serviceNames, change); err != nil {
 				return err
@@ -383,6 +423,119 @@ func (w *minUnitsWatcher) Changes() <-chan []string {
 	return w.out
 }
 
+// machineRetryWatcher notifies when machines that failed to be
+// provisioned have had their error status cleared by RetryProvisioning.
+// The first event returned by the watcher is always empty.
+type machineRetryWatcher struct {
+	commonWatcher
+	filter func(id string) bool
+	known  map[string]params.Status
+	out    chan []string
+}
+
+func newMachineRetryWatcher(st *State, filter func(id string) bool) StringsWatcher {
+	w := &machineRetryWatcher{
+		commonWatcher: commonWatcher{st: st},
+		filter:        filter,
+		known:         make(map[string]params.Status),
+		out:           make(chan []string),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop())
+	}()
+	return w
+}
+
+// machineIdFromStatusKey returns the id of the machine whose status
+// is stored with the given key, and whether the key is that of a
+// machine status.
+func machineIdFromStatusKey(key string) (string, bool) {
+	const prefix = "m#"
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	return key[len(prefix):], true
+}
+
+func (w *machineRetryWatcher) initial() error {
+	var doc struct {
+		Key    string `bson:"_id"`
+		Status params.Status
+	}
+	iter := w.st.statuses.Find(D{{"_id", D{{"$regex", "^m#"}}}}).Iter()
+	for iter.Next(&doc) {
+		if id, ok := machineIdFromStatusKey(doc.Key); ok && w.filter(id) {
+			w.known[id] = doc.Status
+		}
+	}
+	return iter.Err()
+}
+
+func (w *machineRetryWatcher) merge(ids *set.Strings, updates map[string]bool) error {
+	for key, exists := range updates {
+		id, ok := machineIdFromStatusKey(key)
+		if !ok || !w.filter(id) {
+			continue
+		}
+		if !exists {
+			delete(w.known, id)
+			continue
+		}
+		doc, err := getStatus(w.st, key)
+		if errors.IsNotFoundError(err) {
+			delete(w.known, id)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if w.known[id] == params.StatusError && doc.Status == params.StatusPending {
+			ids.Add(id)
+		}
+		w.known[id] = doc.Status
+	}
+	return nil
+}
+
+func (w *machineRetryWatcher) loop() (err error) {
+	in := make(chan watcher.Change)
+	w.st.watcher.WatchCollection(w.st.statuses.Name, in)
+	defer w.st.watcher.UnwatchCollection(w.st.statuses.Name, in)
+	if err := w.initial(); err != nil {
+		return err
+	}
+	ids := new(set.Strings)
+	out := w.out
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case <-w.st.watcher.Dead():
+			return stateWatcherDeadError(w.st.watcher.Err())
+		case ch := <-in:
+			updates, ok := collect(ch, in, w.tomb.Dying())
+			if !ok {
+				return tomb.ErrDying
+			}
+			if err := w.merge(ids, updates); err != nil {
+				return err
+			}
+			if !ids.IsEmpty() {
+				out = w.out
+			}
+		case out <- ids.SortedValues():
+			out = nil
+			ids = new(set.Strings)
+		}
+	}
+	return nil
+}
+
+func (w *machineRetryWatcher) Changes() <-chan []string {
+	return w.out
+}
+
 // RelationScopeWatcher observes changes to the set of units
 // in a particular relation scope.
 type RelationScopeWatcher struct {
@@ -478,7 +631,7 @@ func (w *RelationScopeWatcher) loop() error {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case c := <-ch:
@@ -629,7 +782,7 @@ func (w *RelationUnitsWatcher) loop() (err error) {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case c, ok := <-w.sw.Changes():
@@ -835,7 +988,7 @@ func (w *unitsWatcher) loop(coll, id string, revno int64) error {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case c := <-w.in:
@@ -901,7 +1054,7 @@ func (w *EnvironConfigWatcher) loop() (err error) {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case settings, ok := <-sw.Changes():
@@ -968,7 +1121,7 @@ func (w *settingsWatcher) loop(key string) (err error) {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case <-ch:
@@ -1068,7 +1221,7 @@ func (w *entityWatcher) loop(coll *mgo.Collection, key string) (err error) {
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case ch := <-in:
 			if _, ok := collect(ch, in, w.tomb.Dying()); !ok {
 				return tomb.ErrDying
@@ -1081,6 +1234,106 @@ func (w *entityWatcher) loop(coll *mgo.Collection, key string) (err error) {
 	return nil
 }
 
+// unitPortsWatcher notifies when the ports opened by a unit change.
+type unitPortsWatcher struct {
+	commonWatcher
+	unit *Unit
+	out  chan struct{}
+}
+
+// WatchPorts returns a watcher for observing changes to the ports
+// opened by the unit. The first event is sent immediately; further
+// events are sent only when the set of opened ports changes, and not
+// for other changes to the unit.
+func (u *Unit) WatchPorts() NotifyWatcher {
+	return newUnitPortsWatcher(u)
+}
+
+func newUnitPortsWatcher(u *Unit) NotifyWatcher {
+	w := &unitPortsWatcher{
+		commonWatcher: commonWatcher{st: u.st},
+		unit:          u,
+		out:           make(chan struct{}),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop())
+	}()
+	return w
+}
+
+// Changes returns the event channel for w.
+func (w *unitPortsWatcher) Changes() <-chan struct{} {
+	return w.out
+}
+
+// ports returns the unit's currently opened ports, and the revno
+// of its document; a removed unit has no ports and a revno of -1.
+func (w *unitPortsWatcher) ports() ([]instance.Port, int64, error) {
+	doc := &struct {
+		TxnRevno int64           `bson:"txn-revno"`
+		Ports    []instance.Port `bson:"ports"`
+	}{}
+	fields := D{{"txn-revno", 1}, {"ports", 1}}
+	err := w.st.units.FindId(w.unit.doc.Name).Select(fields).One(doc)
+	if err == mgo.ErrNotFound {
+		return nil, -1, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	SortPorts(doc.Ports)
+	return doc.Ports, doc.TxnRevno, nil
+}
+
+// samePorts returns whether the sorted port slices a and b hold
+// the same ports.
+func samePorts(a, b []instance.Port) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *unitPortsWatcher) loop() error {
+	ports, revno, err := w.ports()
+	if err != nil {
+		return err
+	}
+	in := make(chan watcher.Change)
+	w.st.watcher.Watch(w.st.units.Name, w.unit.doc.Name, revno, in)
+	defer w.st.watcher.Unwatch(w.st.units.Name, w.unit.doc.Name, in)
+	out := w.out
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case <-w.st.watcher.Dead():
+			return stateWatcherDeadError(w.st.watcher.Err())
+		case ch := <-in:
+			if _, ok := collect(ch, in, w.tomb.Dying()); !ok {
+				return tomb.ErrDying
+			}
+			newPorts, _, err := w.ports()
+			if err != nil {
+				return err
+			}
+			if !samePorts(newPorts, ports) {
+				ports = newPorts
+				out = w.out
+			}
+		case out <- struct{}{}:
+			out = nil
+		}
+	}
+	return nil
+}
+
 // machineUnitsWatcher notifies about assignments and lifecycle changes
 // for all units of a machine.
 //
@@ -1203,7 +1456,7 @@ func (w *machineUnitsWatcher) loop() (err error) {
 	for {
 		select {
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case <-machineCh:
@@ -1271,7 +1524,7 @@ func (w *cleanupWatcher) loop() (err error) {
 		case <-w.tomb.Dying():
 			return tomb.ErrDying
 		case <-w.st.watcher.Dead():
-			return watcher.MustErr(w.st.watcher)
+			return stateWatcherDeadError(w.st.watcher.Err())
 		case <-in:
 			// Simply emit event for each change.
 			out = w.out
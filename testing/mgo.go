@@ -47,6 +47,10 @@ var (
 // MongoDB server.
 type MgoSuite struct {
 	Session *mgo.Session
+
+	// KeepDatabases names the databases, other than the system
+	// databases, that are not dropped after each test.
+	KeepDatabases []string
 }
 
 // startMgoServer starts a MongoDB server in a temporary directory.
@@ -168,6 +172,24 @@ func (s *MgoSuite) TearDownSuite(c *C) {
 
 // MgoDial returns a new connection to the shared MongoDB server.
 func MgoDial() *mgo.Session {
+	return MgoDialWithOpts(MgoDialOpts{})
+}
+
+// MgoDialOpts holds the options used by MgoDialWithOpts.
+type MgoDialOpts struct {
+	// Monotonic selects mgo's Monotonic consistency mode for the
+	// session. Otherwise the session uses Strong mode, like those
+	// returned by MgoDial.
+	Monotonic bool
+
+	// SocketTimeout, if non-zero, overrides the time the session
+	// waits for a response from the server before failing.
+	SocketTimeout time.Duration
+}
+
+// MgoDialWithOpts returns a new connection to the shared MongoDB
+// server, set up as described by opts.
+func MgoDialWithOpts(opts MgoDialOpts) *mgo.Session {
 	pool := x509.NewCertPool()
 	xcert, err := cert.ParseCert([]byte(CACert))
 	if err != nil {
@@ -187,6 +209,12 @@ func MgoDial() *mgo.Session {
 	if err != nil {
 		panic(err)
 	}
+	if opts.Monotonic {
+		session.SetMode(mgo.Monotonic, true)
+	}
+	if opts.SocketTimeout != 0 {
+		session.SetSocketTimeout(opts.SocketTimeout)
+	}
 	return session
 }
 
@@ -195,8 +223,9 @@ func (s *MgoSuite) SetUpTest(c *C) {
 	s.Session = MgoDial()
 }
 
-// MgoReset deletes all content from the shared MongoDB server.
-func MgoReset() {
+// MgoReset deletes all content from the shared MongoDB server,
+// except for the system databases and any named in keep.
+func MgoReset(keep ...string) {
 	session := MgoDial()
 	defer session.Close()
 	dbnames, err := session.DatabaseNames()
@@ -214,14 +243,17 @@ func MgoReset() {
 	if err != nil {
 		panic(err)
 	}
+	kept := map[string]bool{"admin": true, "local": true, "config": true}
+	for _, name := range keep {
+		kept[name] = true
+	}
 	for _, name := range dbnames {
-		switch name {
-		case "admin", "local", "config":
-		default:
-			err = session.DB(name).DropDatabase()
-			if err != nil {
-				panic(fmt.Errorf("Cannot drop MongoDB database %v: %v", name, err))
-			}
+		if kept[name] {
+			continue
+		}
+		err = session.DB(name).DropDatabase()
+		if err != nil {
+			panic(fmt.Errorf("Cannot drop MongoDB database %v: %v", name, err))
 		}
 	}
 }
@@ -236,7 +268,7 @@ func isUnauthorized(err error) bool {
 }
 
 func (s *MgoSuite) TearDownTest(c *C) {
-	MgoReset()
+	MgoReset(s.KeepDatabases...)
 	s.Session.Close()
 	for i := 0; ; i++ {
 		stats := mgo.GetStats()
@@ -5,7 +5,9 @@ package testing_test
 
 import (
 	stdtesting "testing"
+	"time"
 
+	"labix.org/v2/mgo"
 	"labix.org/v2/mgo/bson"
 	. "launchpad.net/gocheck"
 
@@ -77,3 +79,96 @@ func (s *mgoSuite) TestMgoStartAndClean(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(morefood, HasLen, 0)
 }
+
+func (s *mgoSuite) TestMgoDialWithOpts(c *C) {
+	session := testing.MgoDial()
+	defer session.Close()
+	c.Assert(session.Mode(), Equals, mgo.Strong)
+
+	monotonic := testing.MgoDialWithOpts(testing.MgoDialOpts{
+		Monotonic:     true,
+		SocketTimeout: 5 * time.Second,
+	})
+	defer monotonic.Close()
+	c.Assert(monotonic.Mode(), Equals, mgo.Monotonic)
+
+	// A monotonic session reads its own writes.
+	menu := monotonic.DB("food").C("menu")
+	for i := 0; i < 10; i++ {
+		err := menu.Insert(bson.D{{"course", i}})
+		c.Assert(err, IsNil)
+		n, err := menu.Count()
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, i+1)
+	}
+}
+
+func (s *mgoSuite) TestMgoResetKeepsNamedDatabases(c *C) {
+	session := testing.MgoDial()
+	defer session.Close()
+	for _, name := range []string{"fixtures", "scratch"} {
+		err := session.DB(name).C("things").Insert(bson.D{{"name", name}})
+		c.Assert(err, IsNil)
+	}
+
+	testing.MgoReset("fixtures")
+	n, err := session.DB("fixtures").C("things").Count()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+	n, err = session.DB("scratch").C("things").Count()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+
+	// Without a keep set, every database is dropped.
+	testing.MgoReset()
+	n, err = session.DB("fixtures").C("things").Count()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+}
+
+type mgoKeepSuite struct {
+	testing.LoggingSuite
+	testing.MgoSuite
+}
+
+var _ = Suite(&mgoKeepSuite{
+	MgoSuite: testing.MgoSuite{KeepDatabases: []string{"fixtures"}},
+})
+
+func (s *mgoKeepSuite) SetUpSuite(c *C) {
+	s.LoggingSuite.SetUpSuite(c)
+	s.MgoSuite.SetUpSuite(c)
+}
+
+func (s *mgoKeepSuite) TearDownSuite(c *C) {
+	s.LoggingSuite.TearDownSuite(c)
+	s.MgoSuite.TearDownSuite(c)
+}
+
+func (s *mgoKeepSuite) SetUpTest(c *C) {
+	s.LoggingSuite.SetUpTest(c)
+	s.MgoSuite.SetUpTest(c)
+}
+
+func (s *mgoKeepSuite) TearDownTest(c *C) {
+	s.LoggingSuite.TearDownTest(c)
+	s.MgoSuite.TearDownTest(c)
+
+	// The kept database survives the reset; the others do not.
+	session := testing.MgoDial()
+	defer session.Close()
+	n, err := session.DB("fixtures").C("things").Count()
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 1)
+	n, err = session.DB("scratch").C("things").Count()
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 0)
+	testing.MgoReset()
+}
+
+func (s *mgoKeepSuite) TestTearDownKeepsDatabases(c *C) {
+	for _, name := range []string{"fixtures", "scratch"} {
+		err := s.Session.DB(name).C("things").Insert(bson.D{{"name", name}})
+		c.Assert(err, IsNil)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package testing
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	. "launchpad.net/gocheck"
+)
+
+// TCPProxy forwards TCP connections to a remote address. Closing it
+// breaks every connection it has forwarded, which simulates the loss
+// of a network connection.
+type TCPProxy struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	closed bool
+	conns  []net.Conn
+}
+
+// NewTCPProxy runs a proxy that forwards connections to remoteAddr.
+func NewTCPProxy(c *C, remoteAddr string) *TCPProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	p := &TCPProxy{listener: listener}
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", remoteAddr)
+			if err != nil {
+				client.Close()
+				continue
+			}
+			if !p.addConns(client, server) {
+				return
+			}
+			go copyAndClose(client, server)
+			go copyAndClose(server, client)
+		}
+	}()
+	return p
+}
+
+// addConns records conns so that they are closed with the proxy. It
+// closes them and returns false if the proxy is already closed.
+func (p *TCPProxy) addConns(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	p.conns = append(p.conns, conns...)
+	return true
+}
+
+func copyAndClose(dst, src net.Conn) {
+	io.Copy(dst, src)
+	dst.Close()
+	src.Close()
+}
+
+// Addr returns the address that the proxy is listening on.
+func (p *TCPProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Close stops the proxy accepting connections and closes all the
+// connections it has forwarded.
+func (p *TCPProxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	return p.listener.Close()
+}
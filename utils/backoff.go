@@ -0,0 +1,79 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy represents a strategy for retrying an action with
+// exponentially increasing delays between tries.
+type BackoffStrategy struct {
+	Min      time.Duration // delay before the second try.
+	Max      time.Duration // maximum delay between tries; unlimited if zero.
+	Factor   float64       // growth of the delay after each try; 2 if zero.
+	Jitter   float64       // fraction of each delay that is randomised.
+	Attempts int           // maximum number of tries; unlimited if zero.
+}
+
+// Backoff represents a sequence of tries made according to a
+// BackoffStrategy.
+type Backoff struct {
+	strategy BackoffStrategy
+	count    int
+}
+
+// Start begins a new sequence of tries for the given strategy.
+func (s BackoffStrategy) Start() *Backoff {
+	return &Backoff{
+		strategy: s,
+	}
+}
+
+// Delay returns the time to wait after the given number of tries
+// before trying again. Without jitter, the delay is Min after the
+// first try, and grows by Factor after each subsequent one, up to
+// Max. Jitter reduces each delay by a random amount up to the given
+// fraction of it, so that many clients backing off at once do not
+// retry in lockstep.
+func (s BackoffStrategy) Delay(tries int) time.Duration {
+	factor := s.Factor
+	if factor == 0 {
+		factor = 2
+	}
+	delay := float64(s.Min)
+	for i := 1; i < tries; i++ {
+		delay *= factor
+		if s.Max > 0 && delay >= float64(s.Max) {
+			break
+		}
+	}
+	if s.Max > 0 && delay > float64(s.Max) {
+		delay = float64(s.Max)
+	}
+	if s.Jitter > 0 {
+		delay -= delay * s.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// Next waits until it is time to perform the next try or returns
+// false if it is time to stop trying.
+func (b *Backoff) Next() bool {
+	// we always make at least one try.
+	if b.count > 0 {
+		if b.strategy.Attempts > 0 && b.count >= b.strategy.Attempts {
+			return false
+		}
+		time.Sleep(b.strategy.Delay(b.count))
+	}
+	b.count++
+	return true
+}
+
+// Count returns the number of tries made so far.
+func (b *Backoff) Count() int {
+	return b.count
+}
@@ -0,0 +1,73 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package utils_test
+
+import (
+	"time"
+
+	. "launchpad.net/gocheck"
+
+	"launchpad.net/juju-core/utils"
+)
+
+type backoffSuite struct{}
+
+var _ = Suite(backoffSuite{})
+
+func (backoffSuite) TestDelay(c *C) {
+	s := utils.BackoffStrategy{
+		Min: 100 * time.Millisecond,
+		Max: time.Second,
+	}
+	want := []time.Duration{0.1e9, 0.2e9, 0.4e9, 0.8e9, 1e9, 1e9}
+	for i, delay := range want {
+		c.Check(s.Delay(i+1), Equals, delay)
+	}
+	s.Factor = 3
+	c.Assert(s.Delay(3), Equals, 900*time.Millisecond)
+	c.Assert(s.Delay(100), Equals, time.Second)
+}
+
+func (backoffSuite) TestDelayJitter(c *C) {
+	s := utils.BackoffStrategy{
+		Min:    100 * time.Millisecond,
+		Max:    time.Second,
+		Jitter: 0.5,
+	}
+	varied := false
+	for i := 0; i < 20; i++ {
+		delay := s.Delay(2)
+		c.Assert(delay >= 100*time.Millisecond, Equals, true)
+		c.Assert(delay <= 200*time.Millisecond, Equals, true)
+		if delay != s.Delay(2) {
+			varied = true
+		}
+	}
+	c.Assert(varied, Equals, true)
+}
+
+func (backoffSuite) TestBackoffTiming(c *C) {
+	const delta = 0.01e9
+	testBackoff := utils.BackoffStrategy{
+		Min:      0.02e9,
+		Max:      0.1e9,
+		Attempts: 5,
+	}
+	want := []time.Duration{0, 0.02e9, 0.06e9, 0.14e9, 0.24e9}
+	got := make([]time.Duration, 0, len(want)) // avoid allocation when testing timing
+	t0 := time.Now()
+	b := testBackoff.Start()
+	for b.Next() {
+		got = append(got, time.Now().Sub(t0))
+	}
+	c.Assert(b.Count(), Equals, 5)
+	c.Assert(got, HasLen, len(want))
+	for i, got := range got {
+		lo := want[i] - delta
+		hi := want[i] + delta
+		if got < lo || got > hi {
+			c.Errorf("attempt %d want %g got %g", i, want[i].Seconds(), got.Seconds())
+		}
+	}
+}
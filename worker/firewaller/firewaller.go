@@ -64,6 +64,11 @@ func (fw *Firewaller) loop() error {
 	if err != nil {
 		return err
 	}
+	if !environs.SupportsFirewalling(fw.environ) {
+		log.Warningf("worker/firewaller: firewalling not supported by this provider; ports will not be managed")
+		<-fw.tomb.Dying()
+		return tomb.ErrDying
+	}
 	if fw.environ.Config().FirewallMode() == config.FwGlobal {
 		fw.globalMode = true
 		fw.globalPortRef = make(map[instance.Port]int)
@@ -470,7 +475,7 @@ func (fw *Firewaller) flushInstancePorts(machined *machineData, toOpen, toClose
 
 // machineLifeChanged starts watching new machines when the firewaller
 // is starting, or when new machines come to life, and stops watching
-// machines that are dying.
+// machines that are dying. Manual machines are never watched.
 func (fw *Firewaller) machineLifeChanged(id string) error {
 	m, err := fw.st.Machine(id)
 	found := !errors.IsNotFoundError(err)
@@ -483,6 +488,12 @@ func (fw *Firewaller) machineLifeChanged(id string) error {
 		return fw.forgetMachine(machined)
 	}
 	if !known && !dead {
+		if m.IsManual() {
+			// The environment did not start the machine's
+			// host, so it cannot manage the host's ports.
+			log.Debugf("worker/firewaller: not watching manual machine %s", id)
+			return nil
+		}
 		err = fw.startMachine(id)
 		if err != nil {
 			return err
@@ -639,7 +650,7 @@ type unitData struct {
 // watchLoop watches the unit for port changes.
 func (ud *unitData) watchLoop(latestPorts []instance.Port) {
 	defer ud.tomb.Done()
-	w := ud.unit.Watch()
+	w := ud.unit.WatchPorts()
 	defer watcher.Stop(w, &ud.tomb)
 	for {
 		select {
@@ -14,6 +14,7 @@ import (
 	"launchpad.net/juju-core/worker"
 	"launchpad.net/juju-core/worker/firewaller"
 	"reflect"
+	"strings"
 	stdtesting "testing"
 	"time"
 )
@@ -95,7 +96,7 @@ func (s *FirewallerSuite) TestStartStop(c *C) {
 }
 
 func (s *FirewallerSuite) addUnit(c *C, svc *state.Service) (*state.Unit, *state.Machine) {
-	units, err := s.Conn.AddUnits(svc, 1, "")
+	units, err := s.Conn.AddUnits(svc, 1, state.AssignNew, "")
 	c.Assert(err, IsNil)
 	u := units[0]
 	id, err := u.AssignedMachineId()
@@ -139,6 +140,46 @@ func (s *FirewallerSuite) startInstance(c *C, m *state.Machine) instance.Instanc
 	return inst
 }
 
+func (s *FirewallerSuite) TestFirewallingNotSupported(c *C) {
+	dummy.SetSupportsFirewalling(false)
+	fw := firewaller.NewFirewaller(s.State)
+	defer func() { c.Assert(fw.Stop(), IsNil) }()
+
+	// Wait for the firewaller to give up on the environment.
+	warning := "WARNING juju worker/firewaller: firewalling not supported by this provider; ports will not be managed"
+	timeout := time.After(coretesting.LongWait)
+	for !strings.Contains(c.GetTestLog(), warning) {
+		select {
+		case <-timeout:
+			c.Fatalf("timed out waiting for warning")
+		case <-time.After(coretesting.ShortWait):
+		}
+	}
+
+	svc, err := s.State.AddService("wordpress", s.charm)
+	c.Assert(err, IsNil)
+	err = svc.SetExposed()
+	c.Assert(err, IsNil)
+	u, m := s.addUnit(c, svc)
+	inst := s.startInstance(c, m)
+	err = u.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+
+	// No ports are ever opened.
+	s.State.StartSync()
+	timeout = time.After(coretesting.ShortWait)
+	for {
+		ports, err := inst.Ports(m.Id())
+		c.Assert(err, IsNil)
+		c.Assert(ports, HasLen, 0)
+		select {
+		case <-timeout:
+			return
+		case <-time.After(coretesting.ShortWait / 5):
+		}
+	}
+}
+
 func (s *FirewallerSuite) TestNotExposedService(c *C) {
 	fw := firewaller.NewFirewaller(s.State)
 	defer func() { c.Assert(fw.Stop(), IsNil) }()
@@ -186,6 +227,37 @@ func (s *FirewallerSuite) TestExposedService(c *C) {
 	s.assertPorts(c, inst, m.Id(), []instance.Port{{"tcp", 8080}})
 }
 
+func (s *FirewallerSuite) TestManualMachine(c *C) {
+	fw := firewaller.NewFirewaller(s.State)
+	defer func() { c.Assert(fw.Stop(), IsNil) }()
+
+	svc, err := s.State.AddService("wordpress", s.charm)
+	c.Assert(err, IsNil)
+	err = svc.SetExposed()
+	c.Assert(err, IsNil)
+
+	// The ports of a unit on a manual machine are not managed.
+	m1, err := s.State.AddManualMachine(state.AddMachineParams{
+		Series: "series",
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}, instance.Id("manual:example.com"), "manual:nonce")
+	c.Assert(err, IsNil)
+	u1, err := svc.AddUnit()
+	c.Assert(err, IsNil)
+	err = u1.AssignToMachine(m1)
+	c.Assert(err, IsNil)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+
+	// The firewaller still manages the ports of other machines.
+	u2, m2 := s.addUnit(c, svc)
+	inst2 := s.startInstance(c, m2)
+	err = u2.OpenPort("tcp", 8080)
+	c.Assert(err, IsNil)
+
+	s.assertPorts(c, inst2, m2.Id(), []instance.Port{{"tcp", 8080}})
+}
+
 func (s *FirewallerSuite) TestMultipleExposedServices(c *C) {
 	fw := firewaller.NewFirewaller(s.State)
 	defer func() { c.Assert(fw.Stop(), IsNil) }()
@@ -638,6 +710,94 @@ func (s *FirewallerSuite) TestGlobalModeStartWithUnexposedService(c *C) {
 	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}})
 }
 
+func (s *FirewallerSuite) TestGlobalModeSetClearExposedService(c *C) {
+	// Change configuration.
+	restore := s.setGlobalMode(c)
+	defer restore(c)
+
+	fw := firewaller.NewFirewaller(s.State)
+	defer func() { c.Assert(fw.Stop(), IsNil) }()
+
+	svc1, err := s.State.AddService("wordpress", s.charm)
+	c.Assert(err, IsNil)
+	u1, m1 := s.addUnit(c, svc1)
+	s.startInstance(c, m1)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+	err = u1.OpenPort("tcp", 8080)
+	c.Assert(err, IsNil)
+
+	svc2, err := s.State.AddService("moinmoin", s.charm)
+	c.Assert(err, IsNil)
+	err = svc2.SetExposed()
+	c.Assert(err, IsNil)
+	u2, m2 := s.addUnit(c, svc2)
+	s.startInstance(c, m2)
+	err = u2.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+
+	// Only the exposed service's port is open.
+	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}})
+
+	// Exposing the first service opens the ports it opened earlier.
+	err = svc1.SetExposed()
+	c.Assert(err, IsNil)
+	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}, {"tcp", 8080}})
+
+	// Unexposing it closes only the ports no other exposed
+	// service is using.
+	err = svc1.ClearExposed()
+	c.Assert(err, IsNil)
+	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}})
+
+	// Unexposing the second service closes everything.
+	err = svc2.ClearExposed()
+	c.Assert(err, IsNil)
+	s.assertEnvironPorts(c, nil)
+}
+
+func (s *FirewallerSuite) TestGlobalModeRemoveUnit(c *C) {
+	// Change configuration.
+	restore := s.setGlobalMode(c)
+	defer restore(c)
+
+	fw := firewaller.NewFirewaller(s.State)
+	defer func() { c.Assert(fw.Stop(), IsNil) }()
+
+	svc, err := s.State.AddService("wordpress", s.charm)
+	c.Assert(err, IsNil)
+	err = svc.SetExposed()
+	c.Assert(err, IsNil)
+
+	u1, m1 := s.addUnit(c, svc)
+	s.startInstance(c, m1)
+	err = u1.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+
+	u2, m2 := s.addUnit(c, svc)
+	s.startInstance(c, m2)
+	err = u2.OpenPort("tcp", 80)
+	c.Assert(err, IsNil)
+	err = u2.OpenPort("tcp", 8080)
+	c.Assert(err, IsNil)
+
+	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}, {"tcp", 8080}})
+
+	// Removing a unit keeps ports that another unit still uses.
+	err = u2.EnsureDead()
+	c.Assert(err, IsNil)
+	err = u2.Remove()
+	c.Assert(err, IsNil)
+	s.assertEnvironPorts(c, []instance.Port{{"tcp", 80}})
+
+	// Removing the last unit using a port closes it.
+	err = u1.EnsureDead()
+	c.Assert(err, IsNil)
+	err = u1.Remove()
+	c.Assert(err, IsNil)
+	s.assertEnvironPorts(c, nil)
+}
+
 func (s *FirewallerSuite) TestGlobalModeRestart(c *C) {
 	// Change configuration.
 	restore := s.setGlobalMode(c)
@@ -0,0 +1,80 @@
+// Copyright 2013 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package provisioner
+
+import (
+	"launchpad.net/juju-core/state/watcher"
+)
+
+// StopReason describes why a Provisioner stopped.
+type StopReason int
+
+const (
+	// Shutdown means that the provisioner stopped because it was
+	// asked to.
+	Shutdown StopReason = iota
+
+	// StateConnectionLost means that the provisioner stopped because
+	// its state connection was closed or lost.
+	StateConnectionLost
+
+	// InternalFailure means that the provisioner stopped because of
+	// an error in the provisioner itself or in the environment.
+	InternalFailure
+)
+
+var stopReasonNames = []string{
+	Shutdown:            "shutdown",
+	StateConnectionLost: "state connection lost",
+	InternalFailure:     "internal failure",
+}
+
+func (r StopReason) String() string {
+	if r < 0 || int(r) >= len(stopReasonNames) {
+		return "unknown"
+	}
+	return stopReasonNames[r]
+}
+
+// StopError is the error returned by Provisioner.Wait and
+// Provisioner.Stop when the provisioner stops for any reason other
+// than being asked to. Its message is that of the underlying error.
+type StopError struct {
+	Reason StopReason
+	Err    error
+}
+
+func (e *StopError) Error() string {
+	return e.Err.Error()
+}
+
+// Reason returns why a provisioner stopped, given the error returned
+// from its Wait method.
+func Reason(err error) StopReason {
+	if err == nil {
+		return Shutdown
+	}
+	if err, ok := err.(*StopError); ok {
+		return err.Reason
+	}
+	return InternalFailure
+}
+
+// stopError returns err as a *StopError, classifying it as an internal
+// failure unless it has already been classified.
+func stopError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*StopError); ok {
+		return err
+	}
+	return &StopError{InternalFailure, err}
+}
+
+// stateConnectionLost returns the error with which the given state
+// watcher died, classified as a lost state connection.
+func stateConnectionLost(w watcher.Errer) error {
+	return &StopError{StateConnectionLost, watcher.MustErr(w)}
+}
@@ -6,10 +6,23 @@ package provisioner
 import (
 	"launchpad.net/juju-core/environs/config"
 	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/utils"
 )
 
-// exported so we can manually close the Provisioners underlying
-// state connection.
+// SetStartInstanceStrategy sets the strategy used to retry starting
+// instances, and returns a function that restores the original one.
+func SetStartInstanceStrategy(s utils.BackoffStrategy) (restore func()) {
+	old := startInstanceStrategy
+	startInstanceStrategy = s
+	return func() {
+		startInstanceStrategy = old
+	}
+}
+
+// CloseState closes the Provisioner's underlying state connection,
+// simulating its loss; unlike Stop, it does not ask the Provisioner to
+// stop, which instead fails with a StateConnectionLost StopError. It
+// may be called more than once, and before or after Stop.
 func (p *Provisioner) CloseState() error {
 	return p.st.Close()
 }
@@ -86,8 +86,10 @@ func (p *Provisioner) loop() error {
 
 	var err error
 	p.environ, err = worker.WaitForEnviron(environWatcher, p.tomb.Dying())
-	if err != nil {
+	if err == tomb.ErrDying {
 		return err
+	} else if err != nil {
+		return &StopError{StateConnectionLost, err}
 	}
 
 	auth, err := NewSimpleAuthenticator(p.environ)
@@ -107,13 +109,22 @@ func (p *Provisioner) loop() error {
 	if err != nil {
 		return err
 	}
+	retryWatcher, err := p.getRetryWatcher()
+	if err != nil {
+		return err
+	}
 	environmentProvisioner := NewProvisionerTask(
 		p.machineId,
+		p.environ.Config().ProvisionerEnabled(),
+		p.environ.Config().SafeMode(),
 		p.st,
 		machineWatcher,
+		retryWatcher,
 		instanceBroker,
 		auth)
 	defer watcher.Stop(environmentProvisioner, &p.tomb)
+	cfg := p.environ.Config()
+	environmentProvisioner.SetStartInstanceAttempts(cfg.StartInstanceAttempts())
 
 	for {
 		select {
@@ -125,11 +136,15 @@ func (p *Provisioner) loop() error {
 			return err
 		case cfg, ok := <-environWatcher.Changes():
 			if !ok {
-				return watcher.MustErr(environWatcher)
+				return stateConnectionLost(environWatcher)
 			}
 			if err := p.setConfig(cfg); err != nil {
 				logger.Errorf("loaded invalid environment configuration: %v", err)
+				break
 			}
+			environmentProvisioner.SetEnabled(cfg.ProvisionerEnabled())
+			environmentProvisioner.SetSafeMode(cfg.SafeMode())
+			environmentProvisioner.SetStartInstanceAttempts(cfg.StartInstanceAttempts())
 		}
 	}
 	panic("not reached")
@@ -160,6 +175,20 @@ func (p *Provisioner) getWatcher() (Watcher, error) {
 	return nil, fmt.Errorf("unknown provisioner type")
 }
 
+func (p *Provisioner) getRetryWatcher() (Watcher, error) {
+	switch p.pt {
+	case ENVIRON:
+		return p.st.WatchEnvironMachineRetries(), nil
+	case LXC:
+		machine, err := p.getMachine()
+		if err != nil {
+			return nil, err
+		}
+		return machine.WatchContainerRetries(instance.LXC), nil
+	}
+	return nil, fmt.Errorf("unknown provisioner type")
+}
+
 func (p *Provisioner) getBroker() (Broker, error) {
 	switch p.pt {
 	case ENVIRON:
@@ -206,9 +235,21 @@ func (p *Provisioner) Kill() {
 	p.tomb.Kill(nil)
 }
 
-// Wait implements worker.Worker.Wait.
+// Wait implements worker.Worker.Wait. It returns nil if the
+// provisioner was asked to stop, and otherwise a *StopError
+// describing why it stopped.
 func (p *Provisioner) Wait() error {
-	return p.tomb.Wait()
+	err := p.tomb.Wait()
+	if err == nil {
+		return nil
+	}
+	// When the state connection is lost every state watcher fails,
+	// and whichever is stopped first gives the raw error that the
+	// tomb keeps, so classify by looking at the state itself.
+	if _, ok := err.(*StopError); !ok && p.st.Err() != tomb.ErrStillAlive {
+		return &StopError{StateConnectionLost, err}
+	}
+	return stopError(err)
 }
 
 func (p *Provisioner) String() string {
@@ -216,8 +257,8 @@ func (p *Provisioner) String() string {
 }
 
 // Stop stops the Provisioner and returns any error encountered while
-// provisioning.
+// provisioning, as a *StopError.
 func (p *Provisioner) Stop() error {
 	p.tomb.Kill(nil)
-	return p.tomb.Wait()
+	return p.Wait()
 }
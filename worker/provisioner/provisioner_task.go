@@ -5,13 +5,18 @@ package provisioner
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
+	"launchpad.net/juju-core/environs/config"
+	"launchpad.net/juju-core/environs/tools"
 	"launchpad.net/juju-core/errors"
 	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/state"
 	"launchpad.net/juju-core/state/api/params"
 	"launchpad.net/juju-core/state/watcher"
 	"launchpad.net/juju-core/utils"
+	"launchpad.net/juju-core/utils/set"
 	"launchpad.net/juju-core/worker"
 	"launchpad.net/tomb"
 )
@@ -21,6 +26,21 @@ type ProvisionerTask interface {
 	Stop() error
 	Dying() <-chan struct{}
 	Err() error
+
+	// SetEnabled pauses or resumes provisioning. While provisioning
+	// is paused, changes to machines are noted but no instances are
+	// started or stopped; when it resumes, those machines are
+	// processed as usual.
+	SetEnabled(enabled bool)
+
+	// SetSafeMode sets whether the task is in safe mode. In safe
+	// mode, instances that have no corresponding machine are left
+	// running rather than being stopped.
+	SetSafeMode(safeMode bool)
+
+	// SetStartInstanceAttempts sets how many times the task tries
+	// to start an instance for a machine before giving up.
+	SetStartInstanceAttempts(attempts int)
 }
 
 type Watcher interface {
@@ -31,22 +51,35 @@ type Watcher interface {
 
 type MachineGetter interface {
 	Machine(id string) (*state.Machine, error)
+	RemoveMachines(ids ...string) (map[string]error, error)
 }
 
 func NewProvisionerTask(
 	machineId string,
+	enabled bool,
+	safeMode bool,
 	machineGetter MachineGetter,
 	watcher Watcher,
+	retryWatcher Watcher,
 	broker Broker,
 	auth AuthenticationProvider,
 ) ProvisionerTask {
 	task := &provisionerTask{
-		machineId:      machineId,
-		machineGetter:  machineGetter,
-		machineWatcher: watcher,
-		broker:         broker,
-		auth:           auth,
-		machines:       make(map[string]*state.Machine),
+		machineId:         machineId,
+		machineGetter:     machineGetter,
+		machineWatcher:    watcher,
+		retryWatcher:      retryWatcher,
+		broker:            broker,
+		auth:              auth,
+		machines:          make(map[string]*state.Machine),
+		enabled:           enabled,
+		enabledChan:       make(chan bool),
+		pausedIds:         set.NewStrings(),
+		safeMode:          safeMode,
+		safeModeChan:      make(chan bool),
+		startAttempts:     config.DefaultStartInstanceAttempts,
+		startAttemptsChan: make(chan int),
+		retries:           make(map[string]*startRetry),
 	}
 	go func() {
 		defer task.tomb.Done()
@@ -59,6 +92,7 @@ type provisionerTask struct {
 	machineId      string
 	machineGetter  MachineGetter
 	machineWatcher Watcher
+	retryWatcher   Watcher
 	broker         Broker
 	tomb           tomb.Tomb
 	auth           AuthenticationProvider
@@ -67,6 +101,32 @@ type provisionerTask struct {
 	instances map[instance.Id]instance.Instance
 	// machine id -> machine
 	machines map[string]*state.Machine
+
+	enabled     bool
+	enabledChan chan bool
+	// pausedIds holds the ids of machines that changed while
+	// provisioning was paused.
+	pausedIds set.Strings
+
+	safeMode     bool
+	safeModeChan chan bool
+
+	startAttempts     int
+	startAttemptsChan chan int
+
+	// retries holds the machines for which starting an instance
+	// failed and will be tried again. It is guarded by retriesMutex
+	// because machines are started concurrently.
+	retriesMutex sync.Mutex
+	retries      map[string]*startRetry
+}
+
+// startRetry records the tries made to start an instance for a
+// machine, and when the next try is due. A zero due time means the
+// next try is being made now.
+type startRetry struct {
+	tries int
+	due   time.Time
 }
 
 // Kill implements worker.Worker.Kill.
@@ -92,33 +152,123 @@ func (task *provisionerTask) Err() error {
 	return task.tomb.Err()
 }
 
+// SetEnabled implements ProvisionerTask.SetEnabled.
+func (task *provisionerTask) SetEnabled(enabled bool) {
+	select {
+	case task.enabledChan <- enabled:
+	case <-task.tomb.Dying():
+	}
+}
+
+// SetStartInstanceAttempts implements ProvisionerTask.SetStartInstanceAttempts.
+func (task *provisionerTask) SetStartInstanceAttempts(attempts int) {
+	select {
+	case task.startAttemptsChan <- attempts:
+	case <-task.tomb.Dying():
+	}
+}
+
+// SetSafeMode implements ProvisionerTask.SetSafeMode.
+func (task *provisionerTask) SetSafeMode(safeMode bool) {
+	select {
+	case task.safeModeChan <- safeMode:
+	case <-task.tomb.Dying():
+	}
+}
+
 func (task *provisionerTask) loop() error {
 	logger.Infof("Starting up provisioner task %s", task.machineId)
 	defer watcher.Stop(task.machineWatcher, &task.tomb)
+	defer watcher.Stop(task.retryWatcher, &task.tomb)
 
 	// When the watcher is started, it will have the initial changes be all
 	// the machines that are relevant. Also, since this is available straight
 	// away, we know there will be some changes right off the bat.
+	if !task.enabled {
+		logger.Infof("provisioning is paused")
+	}
 	for {
+		var retryTimer <-chan time.Time
+		if due, ok := task.nextRetry(); ok {
+			retryTimer = time.After(due.Sub(time.Now()))
+		}
 		select {
 		case <-task.tomb.Dying():
 			logger.Infof("Shutting down provisioner task %s", task.machineId)
 			return tomb.ErrDying
+		case <-retryTimer:
+			ids := task.dueRetries()
+			logger.Infof("retrying start of instances for machines %v", ids)
+			err := task.machinesChanged(ids)
+			task.forgetRetries(ids)
+			if err != nil {
+				return err
+			}
 		case ids, ok := <-task.machineWatcher.Changes():
 			if !ok {
-				return watcher.MustErr(task.machineWatcher)
+				return stateConnectionLost(task.machineWatcher)
 			}
 			// TODO(dfc; lp:1042717) fire process machines periodically to shut down unknown
 			// instances.
+			if err := task.machinesChanged(ids); err != nil {
+				return err
+			}
+		case ids, ok := <-task.retryWatcher.Changes():
+			if !ok {
+				return stateConnectionLost(task.retryWatcher)
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			logger.Infof("retrying provisioning of machines %v", ids)
+			if err := task.machinesChanged(ids); err != nil {
+				return err
+			}
+		case enabled := <-task.enabledChan:
+			if enabled == task.enabled {
+				continue
+			}
+			task.enabled = enabled
+			if !enabled {
+				logger.Infof("provisioning is paused")
+				continue
+			}
+			logger.Infof("provisioning resumed")
+			ids := task.pausedIds.SortedValues()
+			task.pausedIds = set.NewStrings()
 			if err := task.processMachines(ids); err != nil {
 				logger.Errorf("Process machines failed: %v", err)
 				return err
 			}
+		case attempts := <-task.startAttemptsChan:
+			task.startAttempts = attempts
+		case safeMode := <-task.safeModeChan:
+			if safeMode != task.safeMode {
+				logger.Infof("safe mode changed to %v", safeMode)
+				task.safeMode = safeMode
+			}
 		}
 	}
 	panic("not reached")
 }
 
+// machinesChanged processes the machines with the given ids, or
+// notes them for later if provisioning is paused.
+func (task *provisionerTask) machinesChanged(ids []string) error {
+	if !task.enabled {
+		logger.Infof("provisioning is paused; not processing machines %v", ids)
+		for _, id := range ids {
+			task.pausedIds.Add(id)
+		}
+		return nil
+	}
+	if err := task.processMachines(ids); err != nil {
+		logger.Errorf("Process machines failed: %v", err)
+		return err
+	}
+	return nil
+}
+
 func (task *provisionerTask) processMachines(ids []string) error {
 	logger.Tracef("processMachines(%v)", ids)
 	// Populate the tasks maps of current instances and machines.
@@ -141,6 +291,10 @@ func (task *provisionerTask) processMachines(ids []string) error {
 	if err != nil {
 		return err
 	}
+	if task.safeMode && len(unknown) > 0 {
+		logger.Infof("safe mode: not stopping unknown instances %v", unknown)
+		unknown = nil
+	}
 
 	// It's important that we stop unknown instances before starting
 	// pending ones, because if we start an instance and then fail to
@@ -187,6 +341,7 @@ func (task *provisionerTask) populateMachineMaps(ids []string) error {
 // pendingOrDead looks up machines with ids and retuns those that do not
 // have an instance id assigned yet, and also those that are dead.
 func (task *provisionerTask) pendingOrDead(ids []string) (pending, dead []*state.Machine, err error) {
+	var removing []*state.Machine
 	for _, id := range ids {
 		machine, found := task.machines[id]
 		if !found {
@@ -208,14 +363,14 @@ func (task *provisionerTask) pendingOrDead(ids []string) (pending, dead []*state
 			}
 			fallthrough
 		case state.Dead:
-			dead = append(dead, machine)
-			logger.Infof("removing dead machine %q", machine)
-			if err := machine.Remove(); err != nil {
-				logger.Errorf("failed to remove dead machine %q", machine)
-				return nil, nil, err
+			if machine.IsManual() {
+				// The environment did not start the machine's
+				// host, so it must not try to stop it.
+				logger.Infof("not stopping host of dead manual machine %q", machine)
+			} else {
+				dead = append(dead, machine)
 			}
-			// now remove it from the machines map
-			delete(task.machines, machine.Id())
+			removing = append(removing, machine)
 			continue
 		}
 		if instId, err := machine.InstanceId(); err != nil {
@@ -237,11 +392,41 @@ func (task *provisionerTask) pendingOrDead(ids []string) (pending, dead []*state
 			logger.Infof("machine %v already started as instance %q", machine, instId)
 		}
 	}
+	if err := task.removeMachines(removing); err != nil {
+		return nil, nil, err
+	}
 	logger.Tracef("pending machines: %v", pending)
 	logger.Tracef("dead machines: %v", dead)
 	return
 }
 
+// removeMachines removes the given dead machines from the state in a
+// single transaction where possible, and forgets about them.
+func (task *provisionerTask) removeMachines(machines []*state.Machine) error {
+	if len(machines) == 0 {
+		return nil
+	}
+	ids := make([]string, len(machines))
+	for i, machine := range machines {
+		logger.Infof("removing dead machine %q", machine)
+		ids[i] = machine.Id()
+	}
+	errs, err := task.machineGetter.RemoveMachines(ids...)
+	if err != nil {
+		logger.Errorf("failed to remove dead machines %v: %v", ids, err)
+		return err
+	}
+	for _, id := range ids {
+		if err := errs[id]; err != nil {
+			logger.Errorf("failed to remove dead machine %q: %v", id, err)
+			return err
+		}
+		// now remove it from the machines map
+		delete(task.machines, id)
+	}
+	return nil
+}
+
 // findUnknownInstances finds instances which are not associated with a machine.
 func (task *provisionerTask) findUnknownInstances(stopping []instance.Instance) ([]instance.Instance, error) {
 	// Make a copy of the instances we know about.
@@ -302,10 +487,133 @@ func (task *provisionerTask) stopInstances(instances []instance.Instance) error
 	return nil
 }
 
+// startInstanceStrategy governs how long the task waits between
+// tries to start an instance for a machine. The number of tries is
+// set by SetStartInstanceAttempts.
+var startInstanceStrategy = utils.BackoffStrategy{
+	Min:    time.Second,
+	Max:    30 * time.Second,
+	Jitter: 0.2,
+}
+
+// isTransient reports whether an error returned by StartInstance
+// might not recur if the call is retried. Errors reporting that
+// nothing matches the machine's requirements, such as its series or
+// constraints, or that the provider refused our credentials, will.
+func isTransient(err error) bool {
+	switch err {
+	case tools.ErrNoTools, tools.ErrNoMatches:
+		return false
+	}
+	return !errors.IsNotFoundError(err) && !errors.IsUnauthorizedError(err)
+}
+
+// maxConcurrentStarts limits the number of instances the task
+// starts at once.
+var maxConcurrentStarts = 10
+
+// nextRetry returns when the earliest pending retry to start an
+// instance is due, and whether there is one.
+func (task *provisionerTask) nextRetry() (due time.Time, ok bool) {
+	task.retriesMutex.Lock()
+	defer task.retriesMutex.Unlock()
+	for _, r := range task.retries {
+		if r.due.IsZero() {
+			continue
+		}
+		if !ok || r.due.Before(due) {
+			due, ok = r.due, true
+		}
+	}
+	return due, ok
+}
+
+// dueRetries returns the ids of the machines whose retries are due,
+// and marks those retries as being made now.
+func (task *provisionerTask) dueRetries() []string {
+	task.retriesMutex.Lock()
+	defer task.retriesMutex.Unlock()
+	now := time.Now()
+	var ids []string
+	for id, r := range task.retries {
+		if !r.due.IsZero() && !r.due.After(now) {
+			r.due = time.Time{}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// forgetRetries forgets the retries for the given machines that
+// were due but were not made, because the machines were no longer
+// pending or provisioning was paused.
+func (task *provisionerTask) forgetRetries(ids []string) {
+	task.retriesMutex.Lock()
+	defer task.retriesMutex.Unlock()
+	for _, id := range ids {
+		if r, ok := task.retries[id]; ok && r.due.IsZero() {
+			delete(task.retries, id)
+		}
+	}
+}
+
+// startTries returns the number of tries already made to start an
+// instance for the machine with the given id, and whether a retry
+// is waiting to be made.
+func (task *provisionerTask) startTries(id string) (tries int, waiting bool) {
+	task.retriesMutex.Lock()
+	defer task.retriesMutex.Unlock()
+	if r, ok := task.retries[id]; ok {
+		return r.tries, !r.due.IsZero()
+	}
+	return 0, false
+}
+
+// setStartTries records the tries made to start an instance for the
+// machine with the given id. If retry is true, the next try is
+// scheduled after a delay; otherwise the tries are forgotten.
+func (task *provisionerTask) setStartTries(id string, tries int, retry bool) {
+	task.retriesMutex.Lock()
+	defer task.retriesMutex.Unlock()
+	if !retry {
+		delete(task.retries, id)
+		return
+	}
+	task.retries[id] = &startRetry{
+		tries: tries,
+		due:   time.Now().Add(startInstanceStrategy.Delay(tries)),
+	}
+}
+
+// startMachines starts instances for the given machines, up to
+// maxConcurrentStarts at a time. A failure to start one machine does
+// not prevent the others from being started; the first such failure
+// is returned once all have been attempted.
 func (task *provisionerTask) startMachines(machines []*state.Machine) error {
-	for _, m := range machines {
-		if err := task.startMachine(m); err != nil {
-			return fmt.Errorf("cannot start machine %v: %v", m, err)
+	errs := make([]error, len(machines))
+	sem := make(chan struct{}, maxConcurrentStarts)
+	var wg sync.WaitGroup
+	for i, m := range machines {
+		if _, waiting := task.startTries(m.Id()); waiting {
+			logger.Debugf("machine %q is waiting to retry starting an instance", m)
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, m *state.Machine) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := task.startMachine(m); err != nil {
+				errs[i] = fmt.Errorf("cannot start machine %v: %v", m, err)
+			}
+		}(i, m)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -330,7 +638,17 @@ func (task *provisionerTask) startMachine(machine *state.Machine) error {
 	// part is a badge, specifying the tag of the machine the provisioner
 	// is running on, while the second part is a random UUID.
 	nonce := fmt.Sprintf("%s:%s", state.MachineTag(task.machineId), uuid.String())
+	tries, _ := task.startTries(machine.Id())
+	tries++
 	inst, metadata, err := task.broker.StartInstance(machine.Id(), nonce, machine.Series(), cons, stateInfo, apiInfo)
+	retry := err != nil && isTransient(err) && tries < task.startAttempts
+	task.setStartTries(machine.Id(), tries, retry)
+	if retry {
+		// The loop starts the next try once the delay has passed,
+		// so that it stays responsive in the meantime.
+		logger.Warningf("cannot start instance for machine %q (attempt %d): %v", machine, tries, err)
+		return nil
+	}
 	if err != nil {
 		// Set the state to error, so the machine will be skipped next
 		// time until the error is resolved, but don't return an
@@ -5,7 +5,9 @@ package provisioner_test
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	stdtesting "testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"launchpad.net/juju-core/instance"
 	"launchpad.net/juju-core/juju/testing"
 	"launchpad.net/juju-core/state"
+	"launchpad.net/juju-core/state/api"
 	"launchpad.net/juju-core/state/api/params"
 	coretesting "launchpad.net/juju-core/testing"
 	"launchpad.net/juju-core/testing/checkers"
@@ -37,6 +40,7 @@ type CommonProvisionerSuite struct {
 	cfg *config.Config
 	//  // defaultConstraints are used when adding a machine and then later in test assertions.
 	defaultConstraints constraints.Value
+	restoreStrategy    func()
 }
 
 type ProvisionerSuite struct {
@@ -68,6 +72,16 @@ func (s *CommonProvisionerSuite) SetUpTest(c *C) {
 	cfg, err := s.State.EnvironConfig()
 	c.Assert(err, IsNil)
 	s.cfg = cfg
+
+	// Don't wait around when an instance fails to start.
+	s.restoreStrategy = provisioner.SetStartInstanceStrategy(utils.BackoffStrategy{
+		Min: time.Millisecond,
+	})
+}
+
+func (s *CommonProvisionerSuite) TearDownTest(c *C) {
+	s.restoreStrategy()
+	s.JujuConnSuite.TearDownTest(c)
 }
 
 // breakDummyProvider changes the environment config in state in a way
@@ -287,6 +301,98 @@ func (s *ProvisionerSuite) TestProvisionerStartStop(c *C) {
 	c.Assert(p.Stop(), IsNil)
 }
 
+func (s *ProvisionerSuite) TestProvisionerStopReasonShutdown(c *C) {
+	p := s.newEnvironProvisioner("0")
+	err := p.Stop()
+	c.Assert(err, IsNil)
+	c.Assert(provisioner.Reason(err), Equals, provisioner.Shutdown)
+	c.Assert(p.Wait(), IsNil)
+}
+
+func (s *ProvisionerSuite) TestProvisionerStopReasonInternalFailure(c *C) {
+	// An lxc provisioner cannot start without agent tools.
+	p := provisioner.NewProvisioner(provisioner.LXC, s.State, "0", c.MkDir())
+	err := p.Wait()
+	c.Assert(err, FitsTypeOf, (*provisioner.StopError)(nil))
+	c.Assert(err, ErrorMatches, "cannot read URL in tools directory: .*")
+	c.Assert(provisioner.Reason(err), Equals, provisioner.InternalFailure)
+	c.Assert(p.Stop(), DeepEquals, err)
+}
+
+// newRunningProvisioner returns an environ provisioner using its own
+// state connection, once it has provisioned a machine and so is known
+// to be running.
+func (s *ProvisionerSuite) newRunningProvisioner(c *C) *provisioner.Provisioner {
+	return s.newRunningProvisionerWithInfo(c, s.StateInfo(c))
+}
+
+// newRunningProvisionerWithInfo is like newRunningProvisioner, but
+// connects to the state with the given info.
+func (s *ProvisionerSuite) newRunningProvisionerWithInfo(c *C, info *state.Info) *provisioner.Provisioner {
+	st, err := state.Open(info, state.DefaultDialOpts())
+	c.Assert(err, IsNil)
+	p := provisioner.NewProvisioner(provisioner.ENVIRON, st, "0", "")
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	inst := s.checkStartInstance(c, m)
+	s.waitInstanceId(c, m, inst.Id())
+	return p
+}
+
+func (s *ProvisionerSuite) TestProvisionerCloseStateThenStop(c *C) {
+	p := s.newRunningProvisioner(c)
+	c.Assert(p.CloseState(), IsNil)
+	err := p.Wait()
+	c.Assert(err, ErrorMatches, "state has been closed")
+	c.Assert(provisioner.Reason(err), Equals, provisioner.StateConnectionLost)
+	c.Assert(p.Stop(), DeepEquals, err)
+}
+
+func (s *ProvisionerSuite) TestProvisionerStopThenCloseState(c *C) {
+	p := s.newRunningProvisioner(c)
+	c.Assert(p.Stop(), IsNil)
+	c.Assert(p.CloseState(), IsNil)
+	c.Assert(p.Wait(), IsNil)
+}
+
+func (s *ProvisionerSuite) TestProvisionerCloseStateTwice(c *C) {
+	p := s.newRunningProvisioner(c)
+	c.Assert(p.CloseState(), IsNil)
+	c.Assert(p.CloseState(), IsNil)
+	err := p.Wait()
+	c.Assert(err, ErrorMatches, "state has been closed")
+	c.Assert(provisioner.Reason(err), Equals, provisioner.StateConnectionLost)
+	c.Assert(p.Stop(), DeepEquals, err)
+}
+
+func (s *ProvisionerSuite) TestProvisionerStopReasonStateConnectionLost(c *C) {
+	proxy := coretesting.NewTCPProxy(c, coretesting.MgoAddr)
+	defer proxy.Close()
+	info := s.StateInfo(c)
+	info.Addrs = []string{proxy.Addr()}
+	p := s.newRunningProvisionerWithInfo(c, info)
+	defer p.CloseState()
+
+	c.Assert(proxy.Close(), IsNil)
+	err := p.Wait()
+	c.Assert(err, FitsTypeOf, (*provisioner.StopError)(nil))
+	c.Assert(provisioner.Reason(err), Equals, provisioner.StateConnectionLost)
+}
+
+func (s *ProvisionerSuite) TestStopReason(c *C) {
+	c.Assert(provisioner.Reason(nil), Equals, provisioner.Shutdown)
+	c.Assert(provisioner.Reason(fmt.Errorf("boom")), Equals, provisioner.InternalFailure)
+	for _, reason := range []provisioner.StopReason{
+		provisioner.StateConnectionLost,
+		provisioner.InternalFailure,
+	} {
+		err := &provisioner.StopError{Reason: reason, Err: fmt.Errorf("watcher iteration error: EOF")}
+		c.Assert(provisioner.Reason(err), Equals, reason)
+		c.Assert(err, ErrorMatches, "watcher iteration error: EOF")
+	}
+	c.Assert(provisioner.StateConnectionLost.String(), Equals, "state connection lost")
+}
+
 func (s *ProvisionerSuite) addMachine() (*state.Machine, error) {
 	params := state.AddMachineParams{
 		Series:      config.DefaultSeries,
@@ -325,6 +431,25 @@ func (s *ProvisionerSuite) TestConstraints(c *C) {
 	s.checkStartInstanceCustom(c, m, "pork", cons)
 }
 
+func (s *ProvisionerSuite) TestConstraintsWithEnvironFallbacks(c *C) {
+	err := s.State.SetEnvironConstraints(constraints.MustParse("arch=amd64 mem=8G"))
+	c.Assert(err, IsNil)
+
+	// Create a machine that only constrains cpu-cores.
+	m, err := s.State.AddMachineWithConstraints(&state.AddMachineParams{
+		Series:      config.DefaultSeries,
+		Jobs:        []state.MachineJob{state.JobHostUnits},
+		Constraints: constraints.MustParse("cpu-cores=4"),
+	})
+	c.Assert(err, IsNil)
+
+	// Start a provisioner and check the environment's
+	// constraints fill in the rest.
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+	s.checkStartInstanceCustom(c, m, "pork", constraints.MustParse("arch=amd64 mem=8G cpu-cores=4"))
+}
+
 func (s *ProvisionerSuite) TestProvisionerSetsErrorStatusWhenStartInstanceFailed(c *C) {
 	brokenMsg := breakDummyProvider(c, s.State, "StartInstance")
 	p := s.newEnvironProvisioner("0")
@@ -352,6 +477,300 @@ func (s *ProvisionerSuite) TestProvisionerSetsErrorStatusWhenStartInstanceFailed
 	s.checkNoOperations(c)
 }
 
+func (s *ProvisionerSuite) TestProvisionerSetsErrorStatusWhenNoToolsMatch(c *C) {
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	// No tools are available for the machine's series.
+	m, err := s.State.AddMachineWithConstraints(&state.AddMachineParams{
+		Series:      "nosuchseries",
+		Jobs:        []state.MachineJob{state.JobHostUnits},
+		Constraints: s.defaultConstraints,
+	})
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+
+	status, info, err := m.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+	c.Assert(info, Equals, "no matching tools available")
+
+	// A retry fails in the same way.
+	err = m.RetryProvisioning()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+	status, _, err = m.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+}
+
+func (s *ProvisionerSuite) TestProvisionerRetriesMachineInError(c *C) {
+	breakDummyProvider(c, s.State, "StartInstance")
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+	status, _, err := m.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+
+	// Once the environ is fixed, asking for a retry
+	// provisions the machine.
+	err = s.fixEnvironment()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+	err = m.RetryProvisioning()
+	c.Assert(err, IsNil)
+	s.checkStartInstance(c, m)
+}
+
+// flakyBroker is a Broker whose StartInstance fails a number
+// of times before passing calls on to the underlying broker.
+type flakyBroker struct {
+	provisioner.Broker
+	failures int
+	// err, if not nil, is returned by the failing calls.
+	err error
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+// Calls returns the times at which StartInstance was called.
+func (b *flakyBroker) Calls() []time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]time.Time(nil), b.calls...)
+}
+
+func (b *flakyBroker) StartInstance(machineId, machineNonce string, series string, cons constraints.Value,
+	info *state.Info, apiInfo *api.Info) (instance.Instance, *instance.HardwareCharacteristics, error) {
+	b.mu.Lock()
+	b.calls = append(b.calls, time.Now())
+	n := len(b.calls)
+	b.mu.Unlock()
+	if n <= b.failures {
+		if b.err != nil {
+			return nil, nil, b.err
+		}
+		return nil, nil, fmt.Errorf("flaky failure %d", n)
+	}
+	return b.Broker.StartInstance(machineId, machineNonce, series, cons, info, apiInfo)
+}
+
+func (s *ProvisionerSuite) newProvisionerTask(c *C, broker provisioner.Broker) provisioner.ProvisionerTask {
+	auth, err := provisioner.NewSimpleAuthenticator(s.Conn.Environ)
+	c.Assert(err, IsNil)
+	return provisioner.NewProvisionerTask(
+		"0", true, false, s.State,
+		s.State.WatchEnvironMachines(),
+		s.State.WatchEnvironMachineRetries(),
+		broker, auth)
+}
+
+func (s *ProvisionerSuite) TestProvisionerRetriesStartInstanceWithBackoff(c *C) {
+	strategy := utils.BackoffStrategy{
+		Min: 20 * time.Millisecond,
+		Max: time.Second,
+	}
+	defer provisioner.SetStartInstanceStrategy(strategy)()
+	broker := &flakyBroker{Broker: s.Conn.Environ, failures: 3}
+	task := s.newProvisionerTask(c, broker)
+	defer stop(c, task)
+
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkStartInstance(c, m)
+
+	// Each retry waits longer than the last.
+	calls := broker.Calls()
+	c.Assert(calls, HasLen, 4)
+	for i := 1; i < len(calls); i++ {
+		delay := strategy.Delay(i)
+		c.Assert(delay > strategy.Delay(i-1), Equals, true)
+		gap := calls[i].Sub(calls[i-1])
+		c.Assert(gap >= delay, Equals, true, Commentf("try %d after %v, want %v", i+1, gap, delay))
+	}
+}
+
+func (s *ProvisionerSuite) TestProvisionerGivesUpStartInstance(c *C) {
+	broker := &flakyBroker{Broker: s.Conn.Environ, failures: 10}
+	task := s.newProvisionerTask(c, broker)
+	defer stop(c, task)
+	task.SetStartInstanceAttempts(3)
+
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+
+	// The machine is put into an error state with the last error.
+	c.Assert(broker.Calls(), HasLen, 3)
+	status, info, err := m.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+	c.Assert(info, Equals, "flaky failure 3")
+}
+
+func (s *ProvisionerSuite) TestProvisionerDoesNotRetryPermanentErrors(c *C) {
+	broker := &flakyBroker{
+		Broker:   s.Conn.Environ,
+		failures: 10,
+		err:      errors.NotFoundf("instance type"),
+	}
+	task := s.newProvisionerTask(c, broker)
+	defer stop(c, task)
+
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+
+	c.Assert(broker.Calls(), HasLen, 1)
+	status, info, err := m.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, params.StatusError)
+	c.Assert(info, Equals, "instance type not found")
+}
+
+func (s *ProvisionerSuite) TestProvisionerStopsWhileWaitingToRetry(c *C) {
+	defer provisioner.SetStartInstanceStrategy(utils.BackoffStrategy{Min: time.Hour})()
+	broker := &flakyBroker{Broker: s.Conn.Environ, failures: 10}
+	task := s.newProvisionerTask(c, broker)
+
+	_, err := s.addMachine()
+	c.Assert(err, IsNil)
+	timeout := time.After(coretesting.LongWait)
+	for len(broker.Calls()) == 0 {
+		s.State.StartSync()
+		select {
+		case <-timeout:
+			c.Fatalf("StartInstance never called")
+		case <-time.After(coretesting.ShortWait):
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- task.Stop()
+	}()
+	select {
+	case err := <-done:
+		c.Assert(err, IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("task did not stop while waiting to retry")
+	}
+	c.Assert(broker.Calls(), HasLen, 1)
+}
+
+func (s *ProvisionerSuite) TestProvisionerRespondsWhileWaitingToRetry(c *C) {
+	defer provisioner.SetStartInstanceStrategy(utils.BackoffStrategy{Min: time.Hour})()
+	broker := &flakyBroker{Broker: s.Conn.Environ, failures: 10}
+	task := s.newProvisionerTask(c, broker)
+	defer stop(c, task)
+
+	_, err := s.addMachine()
+	c.Assert(err, IsNil)
+	timeout := time.After(coretesting.LongWait)
+	for len(broker.Calls()) == 0 {
+		s.State.StartSync()
+		select {
+		case <-timeout:
+			c.Fatalf("StartInstance never called")
+		case <-time.After(coretesting.ShortWait):
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		task.SetEnabled(false)
+		task.SetSafeMode(true)
+		task.SetStartInstanceAttempts(5)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("task did not respond while waiting to retry")
+	}
+	c.Assert(broker.Calls(), HasLen, 1)
+}
+
+// concurrencyCounter records the greatest number of calls that
+// were in progress at the same time.
+type concurrencyCounter struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (c *concurrencyCounter) enter() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+}
+
+func (c *concurrencyCounter) leave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current--
+}
+
+// slowBroker is a Broker whose StartInstance takes a while,
+// and which records how many calls are in progress at once.
+type slowBroker struct {
+	provisioner.Broker
+	counter *concurrencyCounter
+}
+
+func (b *slowBroker) StartInstance(machineId, machineNonce string, series string, cons constraints.Value,
+	info *state.Info, apiInfo *api.Info) (instance.Instance, *instance.HardwareCharacteristics, error) {
+	b.counter.enter()
+	defer b.counter.leave()
+	time.Sleep(50 * time.Millisecond)
+	return b.Broker.StartInstance(machineId, machineNonce, series, cons, info, apiInfo)
+}
+
+func (s *ProvisionerSuite) TestProvisionerStartsMachinesConcurrently(c *C) {
+	// Add the machines before the task starts, so that it
+	// sees them all at once.
+	machineIds := set.NewStrings()
+	for i := 0; i < 5; i++ {
+		m, err := s.addMachine()
+		c.Assert(err, IsNil)
+		machineIds.Add(m.Id())
+	}
+	counter := &concurrencyCounter{}
+	task := s.newProvisionerTask(c, &slowBroker{s.Conn.Environ, counter})
+	defer stop(c, task)
+
+	s.State.StartSync()
+	started := set.NewStrings()
+	for started.Size() < machineIds.Size() {
+		select {
+		case o := <-s.op:
+			switch o := o.(type) {
+			case dummy.OpStartInstance:
+				c.Assert(started.Contains(o.MachineId), Equals, false)
+				started.Add(o.MachineId)
+			default:
+				c.Fatalf("unexpected operation %#v", o)
+			}
+		case <-time.After(2 * time.Second):
+			c.Fatalf("only started instances for machines %v", started.SortedValues())
+		}
+	}
+	c.Assert(started.SortedValues(), DeepEquals, machineIds.SortedValues())
+	s.checkNoOperations(c)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	c.Assert(counter.max > 1, Equals, true)
+}
+
 func (s *ProvisionerSuite) TestProvisioningDoesNotOccurForContainers(c *C) {
 	p := s.newEnvironProvisioner("0")
 	defer stop(c, p)
@@ -382,6 +801,135 @@ func (s *ProvisionerSuite) TestProvisioningDoesNotOccurForContainers(c *C) {
 	s.waitRemoved(c, m)
 }
 
+func (s *ProvisionerSuite) TestProvisioningIgnoresExistingContainers(c *C) {
+	// Add a host machine with a container before the PA starts, so
+	// that both appear in the initial machine changes.
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	params := state.AddMachineParams{
+		ParentId:      m.Id(),
+		ContainerType: instance.LXC,
+		Series:        config.DefaultSeries,
+		Jobs:          []state.MachineJob{state.JobHostUnits},
+	}
+	container, err := s.State.AddMachineWithConstraints(&params)
+	c.Assert(err, IsNil)
+
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	// Only the host gets an instance; the container is left to the
+	// container provisioner.
+	s.checkStartInstance(c, m)
+	s.checkNoOperations(c)
+	_, err = container.InstanceId()
+	c.Assert(state.IsNotProvisionedError(err), Equals, true)
+}
+
+// setProvisionerEnabled changes the environment config in state to
+// pause or resume provisioning.
+func (s *CommonProvisionerSuite) setProvisionerEnabled(c *C, enabled bool) {
+	cfg, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	cfg, err = cfg.Apply(map[string]interface{}{"provisioner-enabled": enabled})
+	c.Assert(err, IsNil)
+	err = s.State.SetEnvironConfig(cfg)
+	c.Assert(err, IsNil)
+}
+
+func (s *ProvisionerSuite) TestProvisioningPaused(c *C) {
+	s.setProvisionerEnabled(c, false)
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	// The machine is observed, but no instance is started for it.
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+
+	// Resuming provisioning starts the pending machine.
+	s.setProvisionerEnabled(c, true)
+	s.checkStartInstance(c, m)
+}
+
+func (s *ProvisionerSuite) TestProvisioningPausedWhileRunning(c *C) {
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	m0, err := s.addMachine()
+	c.Assert(err, IsNil)
+	i0 := s.checkStartInstance(c, m0)
+
+	// Pause provisioning and wait for the change to be noticed.
+	cfgObserver := make(chan *config.Config, 1)
+	p.SetObserver(cfgObserver)
+	s.setProvisionerEnabled(c, false)
+	s.State.StartSync()
+	select {
+	case <-cfgObserver:
+	case <-time.After(200 * time.Millisecond):
+		c.Fatalf("PA did not action config change")
+	}
+	p.SetObserver(nil)
+
+	// Neither new nor dead machines are acted upon.
+	m1, err := s.addMachine()
+	c.Assert(err, IsNil)
+	c.Assert(m0.EnsureDead(), IsNil)
+	s.checkNoOperations(c)
+
+	// Once resumed, the provisioner catches up.
+	s.setProvisionerEnabled(c, true)
+	s.checkStopInstances(c, i0)
+	s.waitRemoved(c, m0)
+	s.checkStartInstance(c, m1)
+}
+
+// startUnknownInstance starts an instance in the environment that
+// corresponds to no machine in state.
+func (s *CommonProvisionerSuite) startUnknownInstance(c *C) instance.Instance {
+	inst, _ := testing.StartInstance(c, s.Conn.Environ, "999")
+	select {
+	case o := <-s.op:
+		c.Assert(o, FitsTypeOf, dummy.OpStartInstance{})
+	case <-time.After(2 * time.Second):
+		c.Fatalf("instance was not started")
+	}
+	return inst
+}
+
+func (s *ProvisionerSuite) TestProvisioningStopsUnknownInstances(c *C) {
+	inst := s.startUnknownInstance(c)
+
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+	s.checkStopInstances(c, inst)
+}
+
+func (s *CommonProvisionerSuite) setSafeMode(c *C, safeMode bool) {
+	cfg, err := s.State.EnvironConfig()
+	c.Assert(err, IsNil)
+	cfg, err = cfg.Apply(map[string]interface{}{"safe-mode": safeMode})
+	c.Assert(err, IsNil)
+	err = s.State.SetEnvironConfig(cfg)
+	c.Assert(err, IsNil)
+}
+
+func (s *ProvisionerSuite) TestProvisioningSafeModeLeavesUnknownInstances(c *C) {
+	s.setSafeMode(c, true)
+	s.startUnknownInstance(c)
+
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+	s.checkNoOperations(c)
+
+	// Machines are still started in safe mode.
+	m, err := s.addMachine()
+	c.Assert(err, IsNil)
+	s.checkStartInstance(c, m)
+	s.checkNoOperations(c)
+}
+
 func (s *ProvisionerSuite) TestProvisioningDoesNotOccurWithAnInvalidEnvironment(c *C) {
 	err := s.invalidateEnvironment(c)
 	c.Assert(err, IsNil)
@@ -491,6 +1039,110 @@ func (s *ProvisionerSuite) TestProvisioningStopsInstances(c *C) {
 	s.waitRemoved(c, m0)
 }
 
+// recordingMachineGetter records the ids passed to each call of
+// RemoveMachines.
+type recordingMachineGetter struct {
+	*state.State
+	mu      sync.Mutex
+	removes [][]string
+}
+
+func (g *recordingMachineGetter) RemoveMachines(ids ...string) (map[string]error, error) {
+	g.mu.Lock()
+	g.removes = append(g.removes, ids)
+	g.mu.Unlock()
+	return g.State.RemoveMachines(ids...)
+}
+
+func (s *ProvisionerSuite) TestProvisioningRemovesDeadMachinesTogether(c *C) {
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+	m0, err := s.addMachine()
+	c.Assert(err, IsNil)
+	i0 := s.checkStartInstance(c, m0)
+	m1, err := s.addMachine()
+	c.Assert(err, IsNil)
+	i1 := s.checkStartInstance(c, m1)
+	stop(c, p)
+
+	c.Assert(m0.EnsureDead(), IsNil)
+	c.Assert(m1.EnsureDead(), IsNil)
+
+	// A new provisioner task sees both dead machines at once,
+	// and removes them with a single call.
+	getter := &recordingMachineGetter{State: s.State}
+	auth, err := provisioner.NewSimpleAuthenticator(s.Conn.Environ)
+	c.Assert(err, IsNil)
+	task := provisioner.NewProvisionerTask(
+		"0", true, false, getter,
+		s.State.WatchEnvironMachines(),
+		s.State.WatchEnvironMachineRetries(),
+		s.Conn.Environ, auth)
+	defer stop(c, task)
+	s.checkStopInstances(c, i0, i1)
+	s.waitRemoved(c, m0)
+	s.waitRemoved(c, m1)
+
+	getter.mu.Lock()
+	defer getter.mu.Unlock()
+	c.Assert(getter.removes, HasLen, 1)
+	removed := getter.removes[0]
+	sort.Strings(removed)
+	c.Assert(removed, DeepEquals, []string{m0.Id(), m1.Id()})
+}
+
+func (s *ProvisionerSuite) TestProvisioningIgnoresManualMachines(c *C) {
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	m, err := s.State.AddManualMachine(state.AddMachineParams{
+		Series: config.DefaultSeries,
+		Jobs:   []state.MachineJob{state.JobHostUnits},
+	}, instance.Id("ssh:10.0.0.1"), "ssh:nonce")
+	c.Assert(err, IsNil)
+	s.checkNoOperations(c)
+
+	// The dead machine is removed without its host being stopped.
+	c.Assert(m.EnsureDead(), IsNil)
+	s.waitRemoved(c, m)
+	s.checkNoOperations(c)
+}
+
+func (s *ProvisionerSuite) TestProvisioningSafeModeStopsOnlyDeadInstances(c *C) {
+	s.setSafeMode(c, true)
+	p := s.newEnvironProvisioner("0")
+	defer stop(c, p)
+
+	// create a machine
+	m0, err := s.addMachine()
+	c.Assert(err, IsNil)
+	i0 := s.checkStartInstance(c, m0)
+
+	// create a second machine
+	m1, err := s.addMachine()
+	c.Assert(err, IsNil)
+	i1 := s.checkStartInstance(c, m1)
+	stop(c, p)
+
+	// mark the first machine as dead
+	c.Assert(m0.EnsureDead(), IsNil)
+
+	// remove the second machine entirely, leaving its instance unknown
+	c.Assert(m1.EnsureDead(), IsNil)
+	c.Assert(m1.Remove(), IsNil)
+
+	// start a new provisioner; only the dead machine's instance
+	// is stopped
+	p = s.newEnvironProvisioner("0")
+	defer stop(c, p)
+	s.checkStopInstances(c, i0)
+	s.waitRemoved(c, m0)
+	s.checkNoOperations(c)
+	insts, err := s.Conn.Environ.Instances([]instance.Id{i1.Id()})
+	c.Assert(err, IsNil)
+	c.Assert(insts, HasLen, 1)
+}
+
 func (s *ProvisionerSuite) TestDyingMachines(c *C) {
 	p := s.newEnvironProvisioner("0")
 	defer stop(c, p)
@@ -10,12 +10,19 @@ import (
 	"launchpad.net/tomb"
 
 	"launchpad.net/juju-core/log"
+	"launchpad.net/juju-core/utils"
 )
 
 // RestartDelay holds the length of time that a worker
 // will wait between exiting and restarting.
 var RestartDelay = 3 * time.Second
 
+// MaxRestartDelay holds the longest time that a worker will wait
+// between exiting and restarting. Each time a worker fails without
+// having run for at least this long, its restart delay is doubled,
+// starting from RestartDelay, up to this limit.
+var MaxRestartDelay = 1 * time.Minute
+
 // Worker is implemented by a running worker.
 type Worker interface {
 	// Kill asks the worker to stop without necessarily
@@ -127,10 +134,36 @@ func Stop(worker Worker) error {
 }
 
 type workerInfo struct {
-	start        func() (Worker, error)
-	worker       Worker
-	restartDelay time.Duration
-	stopping     bool
+	start      func() (Worker, error)
+	worker     Worker
+	started    time.Time
+	failures   int
+	restartNow bool
+	stopping   bool
+}
+
+// nextRestartDelay records that the worker has exited and returns how
+// long to wait before starting it again.
+func (info *workerInfo) nextRestartDelay() time.Duration {
+	var ran time.Duration
+	if !info.started.IsZero() {
+		ran = time.Since(info.started)
+		info.started = time.Time{}
+	}
+	if info.restartNow {
+		info.restartNow = false
+		info.failures = 0
+		return 0
+	}
+	if ran >= MaxRestartDelay {
+		info.failures = 0
+	}
+	info.failures++
+	strategy := utils.BackoffStrategy{
+		Min: RestartDelay,
+		Max: MaxRestartDelay,
+	}
+	return strategy.Delay(info.failures)
 }
 
 func (runner *Runner) run() error {
@@ -164,8 +197,7 @@ func (runner *Runner) run() error {
 			info := workers[req.id]
 			if info == nil {
 				workers[req.id] = &workerInfo{
-					start:        req.start,
-					restartDelay: RestartDelay,
+					start: req.start,
 				}
 				go runner.runWorker(0, req.id, req.start)
 				break
@@ -179,7 +211,7 @@ func (runner *Runner) run() error {
 			// does stop, we'll restart it immediately with
 			// the new start function.
 			info.start = req.start
-			info.restartDelay = 0
+			info.restartNow = true
 		case id := <-runner.stopc:
 			if info := workers[id]; info != nil {
 				killWorker(id, info)
@@ -187,6 +219,7 @@ func (runner *Runner) run() error {
 		case info := <-runner.startedc:
 			workerInfo := workers[info.id]
 			workerInfo.worker = info.worker
+			workerInfo.started = time.Now()
 			if isDying {
 				killWorker(info.id, workerInfo)
 			}
@@ -217,8 +250,7 @@ func (runner *Runner) run() error {
 				delete(workers, info.id)
 				break
 			}
-			go runner.runWorker(workerInfo.restartDelay, info.id, workerInfo.start)
-			workerInfo.restartDelay = RestartDelay
+			go runner.runWorker(workerInfo.nextRestartDelay(), info.id, workerInfo.start)
 		}
 	}
 	panic("unreachable")
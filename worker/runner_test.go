@@ -18,7 +18,8 @@ import (
 
 type runnerSuite struct {
 	coretesting.LoggingSuite
-	restartDelay time.Duration
+	restartDelay    time.Duration
+	maxRestartDelay time.Duration
 }
 
 var _ = Suite(&runnerSuite{})
@@ -38,11 +39,13 @@ func noImportance(err0, err1 error) bool {
 func (s *runnerSuite) SetUpTest(c *C) {
 	s.LoggingSuite.SetUpTest(c)
 	s.restartDelay = worker.RestartDelay
+	s.maxRestartDelay = worker.MaxRestartDelay
 	worker.RestartDelay = 0
 }
 
 func (s *runnerSuite) TearDownTest(c *C) {
 	worker.RestartDelay = s.restartDelay
+	worker.MaxRestartDelay = s.maxRestartDelay
 	s.LoggingSuite.TearDownTest(c)
 }
 
@@ -167,6 +170,77 @@ func (*runnerSuite) TestOneWorkerRestartDelay(c *C) {
 	}
 }
 
+func (*runnerSuite) TestOneWorkerRestartBackoff(c *C) {
+	worker.RestartDelay = 20 * time.Millisecond
+	worker.MaxRestartDelay = 80 * time.Millisecond
+	runner := worker.NewRunner(noneFatal, noImportance)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", testWorkerStart(starter))
+	c.Assert(err, IsNil)
+	starter.assertStarted(c, true)
+
+	// Each consecutive failure doubles the restart delay,
+	// up to MaxRestartDelay.
+	for _, want := range []time.Duration{20, 40, 80, 80} {
+		want *= time.Millisecond
+		starter.die <- fmt.Errorf("non-fatal error")
+		starter.assertStarted(c, false)
+		t0 := time.Now()
+		starter.assertStarted(c, true)
+		if restartDuration := time.Since(t0); restartDuration < want {
+			c.Fatalf("restart delay too short; got %v want %v", restartDuration, want)
+		}
+	}
+	c.Assert(worker.Stop(runner), IsNil)
+	starter.assertStarted(c, false)
+}
+
+func (*runnerSuite) TestOneWorkerRestartBackoffReset(c *C) {
+	worker.RestartDelay = 20 * time.Millisecond
+	worker.MaxRestartDelay = 200 * time.Millisecond
+	runner := worker.NewRunner(noneFatal, noImportance)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", testWorkerStart(starter))
+	c.Assert(err, IsNil)
+	starter.assertStarted(c, true)
+	for i := 0; i < 3; i++ {
+		starter.die <- fmt.Errorf("non-fatal error")
+		starter.assertStarted(c, false)
+		starter.assertStarted(c, true)
+	}
+
+	// A worker that has run for MaxRestartDelay before
+	// failing is restarted after the initial delay again.
+	time.Sleep(worker.MaxRestartDelay)
+	starter.die <- fmt.Errorf("non-fatal error")
+	starter.assertStarted(c, false)
+	t0 := time.Now()
+	starter.assertStarted(c, true)
+	if restartDuration := time.Since(t0); restartDuration >= worker.MaxRestartDelay {
+		c.Fatalf("restart delay was not reset; got %v", restartDuration)
+	}
+	c.Assert(worker.Stop(runner), IsNil)
+	starter.assertStarted(c, false)
+}
+
+func (*runnerSuite) TestAllWorkersStoppedWhenKilled(c *C) {
+	runner := worker.NewRunner(noneFatal, noImportance)
+	var starters []*testWorkerStarter
+	for i := 0; i < 5; i++ {
+		starter := newTestWorkerStarter()
+		err := runner.StartWorker(fmt.Sprint(i), testWorkerStart(starter))
+		c.Assert(err, IsNil)
+		starters = append(starters, starter)
+	}
+	for _, starter := range starters {
+		starter.assertStarted(c, true)
+	}
+	c.Assert(worker.Stop(runner), IsNil)
+	for _, starter := range starters {
+		starter.assertStarted(c, false)
+	}
+}
+
 type errorLevel int
 
 func (e errorLevel) Error() string {
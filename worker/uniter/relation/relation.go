@@ -60,10 +60,16 @@ func (s *State) Validate(hi hook.Info) (err error) {
 	}
 	unit, kind := hi.RemoteUnit, hi.Kind
 	if kind == hooks.RelationBroken {
-		if len(s.Members) == 0 {
-			return nil
+		if len(s.Members) != 0 {
+			return fmt.Errorf(`cannot run "relation-broken" while units still present`)
 		}
-		return fmt.Errorf(`cannot run "relation-broken" while units still present`)
+		if len(hi.Members) != 0 {
+			return fmt.Errorf(`cannot run "relation-broken" while units still listed as members`)
+		}
+		return nil
+	}
+	if _, listed := hi.Members[unit]; listed && kind == hooks.RelationDeparted {
+		return fmt.Errorf("departing unit is still a member")
 	}
 	if s.ChangedPending != "" {
 		if unit != s.ChangedPending || kind != hooks.RelationChanged {
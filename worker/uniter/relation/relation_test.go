@@ -204,6 +204,24 @@ var writeTests = []struct {
 		},
 		err:     `relation is broken and cannot be changed further`,
 		deleted: true,
+	}, {
+		hooks: []hook.Info{
+			{Kind: hooks.RelationDeparted, RelationId: 123, RemoteUnit: "foo/1", Members: map[string]map[string]interface{}{
+				"foo/1": nil,
+				"foo/2": nil,
+			}},
+		},
+		err: "departing unit is still a member",
+	}, {
+		hooks: []hook.Info{
+			{Kind: hooks.RelationDeparted, RelationId: 123, RemoteUnit: "foo/1"},
+			{Kind: hooks.RelationDeparted, RelationId: 123, RemoteUnit: "foo/2"},
+			{Kind: hooks.RelationBroken, RelationId: 123, Members: map[string]map[string]interface{}{
+				"foo/2": nil,
+			}},
+		},
+		members: msi{},
+		err:     `cannot run "relation-broken" while units still listed as members`,
 	},
 }
 
@@ -19,6 +19,8 @@ type Relationer struct {
 	queue relation.HookQueue
 	hooks chan<- hook.Info
 	dying bool
+	// started records whether hooks have ever been started.
+	started bool
 }
 
 // NewRelationer creates a new Relationer. The unit will not join the
@@ -75,6 +77,25 @@ func (r *Relationer) SetDying() error {
 	return nil
 }
 
+// Depart causes the unit to leave the relation. Like SetDying, it ensures that
+// the only hooks sent henceforth are -departed hooks followed by a -broken
+// hook; committing the -broken hook leaves relation scope and removes the
+// local relation state. If hooks have never been started and no units are
+// known to have joined, no hooks are owed, so Depart leaves relation scope
+// and removes the local relation state at once. It is safe to call Depart
+// when hooks are stopped, and calling it on a relationer that is already
+// departing has no effect.
+func (r *Relationer) Depart() error {
+	if r.dying {
+		return nil
+	}
+	if !r.started && len(r.dir.State().Members) == 0 {
+		r.dying = true
+		return r.die()
+	}
+	return r.SetDying()
+}
+
 // die is run when the relationer has no further responsibilities; it leaves
 // relation scope, and removes the local relation state directory.
 func (r *Relationer) die() error {
@@ -94,6 +115,7 @@ func (r *Relationer) StartHooks() {
 	if r.queue != nil {
 		panic("hooks already started!")
 	}
+	r.started = true
 	if r.dying {
 		r.queue = relation.NewDyingHookQueue(r.dir.State(), r.hooks)
 	} else {
@@ -113,13 +135,17 @@ func (r *Relationer) StopHooks() error {
 }
 
 // PrepareHook checks that the relation is in a state such that it makes
-// sense to execute the supplied hook, and ensures that the relation context
+// sense to execute the supplied hook, and that the hook is consistent with
+// the membership changes it describes, and ensures that the relation context
 // contains the latest relation state as communicated in the hook.Info. It
 // returns the name of the hook that must be run.
 func (r *Relationer) PrepareHook(hi hook.Info) (hookName string, err error) {
 	if r.IsImplicit() {
 		panic("implicit relations must not run hooks")
 	}
+	if !hi.Kind.IsRelation() {
+		return "", fmt.Errorf("%q is not a relation hook", hi.Kind)
+	}
 	if err = r.dir.State().Validate(hi); err != nil {
 		return
 	}
@@ -306,6 +306,67 @@ func (s *RelationerSuite) TestPrepareCommitHooks(c *C) {
 	c.Assert(ctx.UnitNames(), DeepEquals, []string{"u/1", "u/2"})
 }
 
+func (s *RelationerSuite) TestPrepareHookInconsistent(c *C) {
+	r := uniter.NewRelationer(s.ru, s.dir, s.hooks)
+	err := r.Join()
+	c.Assert(err, IsNil)
+	ctx := r.Context()
+	joined := hook.Info{
+		Kind:       hooks.RelationJoined,
+		RemoteUnit: "u/1",
+		Members: map[string]map[string]interface{}{
+			"u/1": {"private-address": "u-1.testing.invalid"},
+		},
+	}
+	_, err = r.PrepareHook(joined)
+	c.Assert(err, IsNil)
+	err = r.CommitHook(joined)
+	c.Assert(err, IsNil)
+
+	for i, t := range []struct {
+		hi  hook.Info
+		err string
+	}{{
+		hook.Info{Kind: hooks.Install},
+		`"install" is not a relation hook`,
+	}, {
+		hook.Info{Kind: hooks.RelationJoined, RemoteUnit: "u/1"},
+		`inappropriate "relation-joined" for "u/1": expected "relation-changed" for "u/1"`,
+	}, {
+		hook.Info{Kind: hooks.RelationDeparted, RemoteUnit: "u/2"},
+		`inappropriate "relation-departed" for "u/2": unit has not joined`,
+	}, {
+		hook.Info{Kind: hooks.RelationDeparted, RemoteUnit: "u/1", Members: map[string]map[string]interface{}{
+			"u/1": nil,
+		}},
+		`inappropriate "relation-departed" for "u/1": .*`,
+	}, {
+		hook.Info{Kind: hooks.RelationBroken},
+		`inappropriate "relation-broken" for "": cannot run "relation-broken" while units still present`,
+	}} {
+		c.Logf("test %d: %#v", i, t.hi)
+		name, err := r.PrepareHook(t.hi)
+		c.Assert(err, ErrorMatches, t.err)
+		c.Assert(name, Equals, "")
+		c.Assert(s.dir.State().Members, DeepEquals, map[string]int64{"u/1": 0})
+		c.Assert(ctx.UnitNames(), DeepEquals, []string{"u/1"})
+	}
+
+	// Once the pending changed hook has run, a departed hook that still
+	// lists its unit as a member is rejected.
+	changed := hook.Info{Kind: hooks.RelationChanged, RemoteUnit: "u/1"}
+	_, err = r.PrepareHook(changed)
+	c.Assert(err, IsNil)
+	err = r.CommitHook(changed)
+	c.Assert(err, IsNil)
+	departed := hook.Info{Kind: hooks.RelationDeparted, RemoteUnit: "u/1", Members: map[string]map[string]interface{}{
+		"u/1": nil,
+	}}
+	_, err = r.PrepareHook(departed)
+	c.Assert(err, ErrorMatches, `inappropriate "relation-departed" for "u/1": departing unit is still a member`)
+	c.Assert(ctx.UnitNames(), DeepEquals, []string{"u/1"})
+}
+
 func (s *RelationerSuite) TestSetDying(c *C) {
 	ru1 := s.AddRelationUnit(c, "u/1")
 	settings := map[string]interface{}{"unit": "settings"}
@@ -346,6 +407,113 @@ func (s *RelationerSuite) TestSetDying(c *C) {
 	c.Assert(err, ErrorMatches, ".*: relation is broken and cannot be changed further")
 }
 
+func (s *RelationerSuite) TestDepart(c *C) {
+	ru1 := s.AddRelationUnit(c, "u/1")
+	settings := map[string]interface{}{"unit": "settings"}
+	err := ru1.EnterScope(settings)
+	c.Assert(err, IsNil)
+	w := ru1.Watch()
+	defer stop(c, w)
+	s.State.StartSync()
+	<-w.Changes()
+
+	r := uniter.NewRelationer(s.ru, s.dir, s.hooks)
+	err = r.Join()
+	c.Assert(err, IsNil)
+	r.StartHooks()
+	defer stopHooks(c, r)
+	joined := hook.Info{
+		Kind:       hooks.RelationJoined,
+		RemoteUnit: "u/1",
+		Members: map[string]map[string]interface{}{
+			"u/1": settings,
+		},
+	}
+	s.assertHook(c, joined)
+
+	// Depart the relation; repeated calls have no further effect.
+	err = r.Depart()
+	c.Assert(err, IsNil)
+	err = r.Depart()
+	c.Assert(err, IsNil)
+
+	// The hook stream delivers the pending changed hook, then departs u/1
+	// and breaks the relation.
+	s.assertHook(c, hook.Info{Kind: hooks.RelationChanged, RemoteUnit: "u/1"})
+	s.assertHook(c, hook.Info{Kind: hooks.RelationDeparted, RemoteUnit: "u/1"})
+	s.assertHook(c, hook.Info{Kind: hooks.RelationBroken})
+	s.assertNoHook(c)
+	err = r.CommitHook(hook.Info{Kind: hooks.RelationBroken})
+	c.Assert(err, IsNil)
+
+	// The local state directory has been removed...
+	_, err = os.Stat(filepath.Join(s.dirPath, strconv.Itoa(s.rel.Id())))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// ...and left relation scope, which u/1 observes.
+	s.State.StartSync()
+	timeout := time.After(worstCase)
+	for {
+		select {
+		case ch, ok := <-w.Changes():
+			c.Assert(ok, Equals, true)
+			if len(ch.Departed) == 0 {
+				continue
+			}
+			c.Assert(ch.Departed, DeepEquals, []string{"u/0"})
+		case <-timeout:
+			c.Fatalf("timed out waiting for absence detection")
+		}
+		break
+	}
+
+	// Departing again is still harmless.
+	err = r.Depart()
+	c.Assert(err, IsNil)
+}
+
+func (s *RelationerSuite) TestDepartBeforeStartHooks(c *C) {
+	ru1 := s.AddRelationUnit(c, "u/1")
+	err := ru1.EnterScope(map[string]interface{}{"unit": "settings"})
+	c.Assert(err, IsNil)
+	w := ru1.Watch()
+	defer stop(c, w)
+	s.State.StartSync()
+	<-w.Changes()
+
+	r := uniter.NewRelationer(s.ru, s.dir, s.hooks)
+	err = r.Join()
+	c.Assert(err, IsNil)
+	err = s.dir.Ensure()
+	c.Assert(err, IsNil)
+
+	// Hooks were never started, so the unit leaves at once.
+	err = r.Depart()
+	c.Assert(err, IsNil)
+	_, err = os.Stat(filepath.Join(s.dirPath, strconv.Itoa(s.rel.Id())))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	s.State.StartSync()
+	timeout := time.After(worstCase)
+	for {
+		select {
+		case ch, ok := <-w.Changes():
+			c.Assert(ok, Equals, true)
+			if len(ch.Departed) == 0 {
+				continue
+			}
+			c.Assert(ch.Departed, DeepEquals, []string{"u/0"})
+		case <-timeout:
+			c.Fatalf("timed out waiting for absence detection")
+		}
+		break
+	}
+	s.assertNoHook(c)
+
+	// Departing again is harmless.
+	err = r.Depart()
+	c.Assert(err, IsNil)
+}
+
 func (s *RelationerSuite) assertNoHook(c *C) {
 	s.State.StartSync()
 	select {